@@ -0,0 +1,46 @@
+// Commande de réconciliation des abonnements locaux avec Stripe.
+// Affiche le rapport des écarts en JSON ; avec -dry-run, aucun abonnement n'est modifié.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/stripe"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "rapporter les écarts sans les corriger")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	dsn := os.Getenv("SUPABASE_DB_URL")
+	if dsn == "" {
+		log.Fatal("SUPABASE_DB_URL manquant")
+	}
+	if os.Getenv("STRIPE_SECRET_KEY") == "" {
+		log.Fatal("STRIPE_SECRET_KEY manquant")
+	}
+	database.Connect(dsn)
+
+	report, err := stripe.Reconcile(*dryRun)
+	if err != nil {
+		log.Fatalf("Réconciliation : %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Écriture du rapport : %v", err)
+	}
+
+	if len(report.Errors) > 0 {
+		os.Exit(1)
+	}
+}
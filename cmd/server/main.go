@@ -13,15 +13,22 @@ import (
 
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/admin"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/auth"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/earnings"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/feed"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/follow"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/gift"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/like"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/message"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/middleware"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/post"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/report"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/scheduler"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/stripe"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/upload"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
 
@@ -38,9 +45,19 @@ func main() {
 		log.Fatalf(" Init S3 : %v", err)
 	}
 
+	// Tâches de fond, exécutées par une seule instance à la fois
+	scheduler.Register("price_changes", "@hourly", billing.ApplyDuePriceChanges)
+	scheduler.Register("gift_expirations", "*/15 * * * *", gift.ExpireGifts)
+	scheduler.Register("message_purge", "0 4 * * *", message.PurgeDeleted)
+	scheduler.Register("stripe_reconciliation", "30 3 * * *", stripe.ReconcileSubscriptions)
+	scheduler.Register("post_publication", "* * * * *", post.PublishScheduled)
+	scheduler.Register("upload_purge", "@hourly", upload.PurgeExpiredUploads)
+	scheduler.Register("job_run_purge", "0 5 * * *", scheduler.PurgeRuns)
+	scheduler.Start()
+
 	r := gin.New()
 
-	// Middleware de logs custom pour ignorer "/"
+	// Middleware de logs custom pour ignorer "/", sans le token d'accès des connexions WebSocket
 	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		if !strings.HasPrefix(param.Path, "/api/") {
 			return ""
@@ -51,7 +68,7 @@ func main() {
 			param.Latency,
 			param.ClientIP,
 			param.Method,
-			param.Path,
+			middleware.LoggedPath(param.Request),
 		)
 	}))
 
@@ -108,6 +125,8 @@ func main() {
 	apiUsersUsername := api.Group("/users/username")
 	apiUsersUsername.GET("/:username", user.GetUserByUsername)
 	apiUsersUsername.GET("/:username/posts", post.GetPostsByUsername)
+	apiUsersUsername.GET("/:username/tiers", billing.GetCreatorTiers)
+	apiUsersUsername.GET("/:username/bundles", billing.GetCreatorBundles)
 
 	// Routes publiques pour les posts
 	api.GET("/posts", like.GetPostsWithLikes)
@@ -115,6 +134,10 @@ func main() {
 	api.GET("/posts/:id/likes", like.GetLikeStatus)
 	api.GET("/posts/:id", like.GetPostByIDWithLikes)
 
+	// WebSocket de messagerie : le token peut être passé en paramètre car le navigateur
+	// ne permet pas d'envoyer l'en-tête Authorization lors de l'ouverture
+	api.GET("/messages/ws", middleware.TokenFromQuery(), middleware.AuthMiddleware(), message.ServeWS)
+
 	// Routes protégées par authentification
 	api.Use(middleware.AuthMiddleware())
 
@@ -124,6 +147,18 @@ func main() {
 	apiMe := api.Group("/me")
 	apiMe.GET("", user.GetMe)
 	apiMe.PUT("", user.UpdateMe)
+	apiMe.GET("/price-history", billing.GetPriceHistory)
+	apiMe.GET("/tiers", billing.GetMyTiers)
+	apiMe.POST("/tiers", billing.CreateMyTier)
+	apiMe.PUT("/tiers/:id", billing.UpdateMyTier)
+	apiMe.DELETE("/tiers/:id", billing.DeleteMyTier)
+	apiMe.GET("/bundles", billing.GetMyBundles)
+	apiMe.POST("/bundles", billing.CreateMyBundle)
+	apiMe.DELETE("/bundles/:id", billing.DeleteMyBundle)
+	apiMe.GET("/promo-codes", billing.GetMyPromoCodes)
+	apiMe.POST("/promo-codes", billing.CreateMyPromoCode)
+	apiMe.DELETE("/promo-codes/:id", billing.DeleteMyPromoCode)
+	apiMe.GET("/promo-codes/:id/redemptions", billing.GetMyPromoCodeRedemptions)
 
 	// /api/users
 	apiUsers := api.Group("/users")
@@ -131,18 +166,35 @@ func main() {
 	apiUsers.PUT("/:id", user.UpdateUser)
 	apiUsers.DELETE("/:id", user.DeleteUser)
 
+	// Fil d'actualité personnalisé (suivis et abonnements)
+	api.GET("/feed", feed.GetFeed)
+
 	// Routes pour les posts nécessitant une authentification
 	apiPosts := api.Group("/posts")
 	apiPosts.POST("", post.CreatePost)
 	apiPosts.GET("/me", post.GetUserPosts)
+	apiPosts.PUT("/:id", post.UpdatePost)
 	apiPosts.DELETE("/:id", post.DeletePost)
+	apiPosts.PUT("/:id/draft", post.UpdateDraft)
+	apiPosts.POST("/:id/publish", post.PublishPost)
+	apiPosts.POST("/:id/archive", post.ArchivePost)
+	apiPosts.GET("/:id/revisions", post.GetPostRevisions)
+	apiPosts.POST("/:id/revisions/:revision_id/restore", post.RestorePostRevision)
 	apiPosts.POST("/:id/like", like.ToggleLike)
 
+	// Envoi direct des fichiers volumineux sur S3 (médias de post, avatar, messages)
+	apiUploads := api.Group("/uploads")
+	apiUploads.POST("", upload.CreateUpload)
+	apiUploads.POST("/:id/finalize", upload.FinalizeUpload)
+
 	// Routes pour les commentaires nécessitant une authentification
 	apiComments := api.Group("/comments")
 	apiComments.POST("", post.CreateComment)
 	apiComments.DELETE("/:id", post.DeleteComment)
 
+	// Pourboires aux créateurs (post, profil ou conversation)
+	api.POST("/tips", stripe.CreateTip)
+
 	// Routes pour les signalements
 	apiReports := api.Group("/reports")
 	apiReports.POST("", report.CreateReport)
@@ -163,10 +215,28 @@ func main() {
 	apiFollow.GET("/", follow.GetFollowing)
 	apiFollow.GET("/followers/:id", follow.GetFollowers)
 
+	// Tableau de bord des revenus du créateur
+	apiCreator := api.Group("/creator")
+	apiCreator.GET("/earnings", earnings.GetEarnings)
+	apiCreator.GET("/earnings/payouts", earnings.GetPayouts)
+
+	// Détail d'un changement de prix annoncé aux abonnés
+	api.GET("/price-changes/:id", billing.GetPriceChange)
+
+	// /api/notifications
+	apiNotifications := api.Group("/notifications")
+	apiNotifications.GET("", notification.GetNotifications)
+	apiNotifications.PUT("/read-all", notification.MarkAllNotificationsAsRead)
+	apiNotifications.PUT("/:id/read", notification.MarkNotificationAsRead)
+
 	stripeGroup := api.Group("/stripe")
 	stripeGroup.POST("/create-account-link", stripe.CreateAccountLink)
 	stripeGroup.GET("/complete-connect", stripe.CompleteConnect)
 	stripeGroup.POST("/create-subscription-session/:creator_id", stripe.CreateSubscriptionSession)
+	stripeGroup.POST("/create-unlock-session/:post_id", stripe.CreateUnlockSession)
+	stripeGroup.POST("/create-message-unlock-session/:message_id", stripe.CreateMessageUnlockSession)
+	stripeGroup.POST("/create-gift-session/:creator_id", stripe.CreateGiftSession)
+	stripeGroup.PUT("/subscription-tier/:creator_id", stripe.ChangeSubscriptionTier)
 	stripeGroup.DELETE("/unsubscribe/:creator_id", stripe.Unsubscribe)
 
 	// Routes d'administration (avec middleware admin)
@@ -178,6 +248,17 @@ func main() {
 	apiAdmin.GET("/charts/:type", admin.GetChartData)
 	apiAdmin.GET("/top-users", admin.GetTopUsers)
 
+	// Événements Stripe (webhooks en échec)
+	apiAdminStripe := apiAdmin.Group("/stripe")
+	apiAdminStripe.GET("/events", stripe.GetStripeEvents)
+	apiAdminStripe.POST("/events/:id/retry", stripe.RetryStripeEvent)
+
+	// Tâches planifiées : état, historique et exécution manuelle
+	apiAdminJobs := apiAdmin.Group("/jobs")
+	apiAdminJobs.GET("", scheduler.GetJobs)
+	apiAdminJobs.GET("/:name/runs", scheduler.GetJobRuns)
+	apiAdminJobs.POST("/:name/run", scheduler.TriggerJob)
+
 	// Gestion des signalements (admin seulement)
 	apiAdminReports := apiAdmin.Group("/reports")
 	apiAdminReports.GET("", report.GetReports)
@@ -0,0 +1,93 @@
+package access
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/unlock"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/utils"
+)
+
+// Statuts de publication d'un post
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled" // publié automatiquement à sa date de publication
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Post contient les informations d'un post nécessaires aux règles d'accès
+type Post struct {
+	ID          string
+	UserID      string
+	Status      string
+	IsPaid      bool
+	MinTierRank int // rang du palier minimum exigé, 0 pour tout abonné
+}
+
+// HiddenFrom indique si le post n'est pas publié et donc invisible pour tout autre que son auteur
+func (p Post) HiddenFrom(viewerID string) bool {
+	return p.Status != StatusPublished && p.UserID != viewerID
+}
+
+// CanViewPost indique si un utilisateur peut voir un post (contenu, commentaires et likes).
+// Un post non publié n'est visible que par son auteur. Un post gratuit est visible par tous,
+// un post payant par son auteur, ses abonnés actifs d'un palier suffisant et les utilisateurs
+// qui l'ont acheté à l'unité.
+func CanViewPost(viewerID string, post Post) (bool, error) {
+	if post.HiddenFrom(viewerID) {
+		return false, nil
+	}
+	if !post.IsPaid {
+		return true, nil
+	}
+	if viewerID == "" {
+		return false, nil
+	}
+	if viewerID == post.UserID {
+		return true, nil
+	}
+
+	isSubscriber, tierRank, err := utils.SubscriptionTierRank(viewerID, post.UserID)
+	if err != nil {
+		return false, err
+	}
+	if isSubscriber && tierRank >= post.MinTierRank {
+		return true, nil
+	}
+
+	return unlock.HasUnlocked(viewerID, post.ID)
+}
+
+// VisiblePosts est un scope GORM qui restreint une requête sur "posts"
+// aux posts que l'utilisateur a le droit de voir
+func VisiblePosts(viewerID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewerID == "" {
+			return db.Where("posts.is_paid = ?", false)
+		}
+		return db.Where(
+			"posts.is_paid = ? OR posts.user_id = ? OR posts.user_id IN (?) OR posts.id IN (?)",
+			false, viewerID,
+			database.DB.Table("subscriptions").
+				Select("creator_id").
+				Scopes(subscription.ActiveFor(viewerID)).
+				Where("tier_rank >= posts.min_tier_rank"),
+			database.DB.Table("post_unlocks").
+				Select("post_id").
+				Where("user_id = ?", viewerID),
+		)
+	}
+}
+
+// PublishedPosts est un scope GORM qui restreint une requête sur "posts" aux posts publiés.
+// Avec un viewerID, l'auteur voit aussi ses brouillons, posts programmés et archivés.
+func PublishedPosts(viewerID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewerID == "" {
+			return db.Where("posts.status = ?", StatusPublished)
+		}
+		return db.Where("posts.status = ? OR posts.user_id = ?", StatusPublished, viewerID)
+	}
+}
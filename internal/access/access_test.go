@@ -0,0 +1,161 @@
+package access
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestCanViewPost(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	subscriptionColumns := []string{"id", "created_at", "subscriber_id", "creator_id", "status", "stripe_subscription_id", "price"}
+	tierColumns := append(subscriptionColumns, "tier_rank")
+
+	tests := []struct {
+		name           string
+		viewerID       string
+		post           Post
+		mockRows       *sqlmock.Rows
+		mockError      error
+		unlockRows     *sqlmock.Rows
+		expectedResult bool
+		expectedError  bool
+	}{
+		{
+			name:           "Free post is visible to anonymous users",
+			viewerID:       "",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: false},
+			expectedResult: true,
+			expectedError:  false,
+		},
+		{
+			name:           "Paid post is hidden from anonymous users",
+			viewerID:       "",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true},
+			expectedResult: false,
+			expectedError:  false,
+		},
+		{
+			name:           "Author can view own paid post",
+			viewerID:       "creator1",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true},
+			expectedResult: true,
+			expectedError:  false,
+		},
+		{
+			name:     "Active subscriber can view paid post",
+			viewerID: "subscriber1",
+			post:     Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true},
+			mockRows: sqlmock.NewRows(subscriptionColumns).
+				AddRow("sub1", time.Now(), "subscriber1", "creator1", "active", "stripe_sub_123", 9.99),
+			expectedResult: true,
+			expectedError:  false,
+		},
+		{
+			name:     "Cancelled subscriber cannot view paid post",
+			viewerID: "subscriber1",
+			post:     Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true},
+			// Un abonnement résilié est exclu par la requête elle-même
+			mockRows:       sqlmock.NewRows(subscriptionColumns),
+			unlockRows:     sqlmock.NewRows([]string{"count"}).AddRow(0),
+			expectedResult: false,
+			expectedError:  false,
+		},
+		{
+			name:     "Subscriber with a lower tier cannot view post",
+			viewerID: "subscriber1",
+			post:     Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true, MinTierRank: 2},
+			mockRows: sqlmock.NewRows(tierColumns).
+				AddRow("sub1", time.Now(), "subscriber1", "creator1", "active", "stripe_sub_123", 9.99, 1),
+			unlockRows:     sqlmock.NewRows([]string{"count"}).AddRow(0),
+			expectedResult: false,
+			expectedError:  false,
+		},
+		{
+			name:     "Subscriber with a higher tier can view post",
+			viewerID: "subscriber1",
+			post:     Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true, MinTierRank: 2},
+			mockRows: sqlmock.NewRows(tierColumns).
+				AddRow("sub1", time.Now(), "subscriber1", "creator1", "active", "stripe_sub_123", 19.99, 3),
+			expectedResult: true,
+			expectedError:  false,
+		},
+		{
+			name:           "Non subscriber cannot view paid post",
+			viewerID:       "user1",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true},
+			mockRows:       sqlmock.NewRows(subscriptionColumns),
+			unlockRows:     sqlmock.NewRows([]string{"count"}).AddRow(0),
+			expectedResult: false,
+			expectedError:  false,
+		},
+		{
+			name:           "Buyer can view unlocked paid post",
+			viewerID:       "user1",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true},
+			mockRows:       sqlmock.NewRows(subscriptionColumns),
+			unlockRows:     sqlmock.NewRows([]string{"count"}).AddRow(1),
+			expectedResult: true,
+			expectedError:  false,
+		},
+		{
+			name:           "Draft is hidden from other users",
+			viewerID:       "user1",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusDraft, IsPaid: false},
+			expectedResult: false,
+			expectedError:  false,
+		},
+		{
+			name:           "Scheduled post is hidden from subscribers",
+			viewerID:       "subscriber1",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusScheduled, IsPaid: true},
+			expectedResult: false,
+			expectedError:  false,
+		},
+		{
+			name:           "Author can view own draft",
+			viewerID:       "creator1",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusDraft, IsPaid: true},
+			expectedResult: true,
+			expectedError:  false,
+		},
+		{
+			name:           "Database error is returned",
+			viewerID:       "user1",
+			post:           Post{ID: "post1", UserID: "creator1", Status: StatusPublished, IsPaid: true},
+			mockError:      errors.New("connection lost"),
+			expectedResult: false,
+			expectedError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := `SELECT \* FROM "subscriptions" WHERE creator_id = \$1 AND \(subscriber_id = \$2 AND status IN`
+			if tt.mockRows != nil {
+				mock.ExpectQuery(query).WillReturnRows(tt.mockRows)
+			} else if tt.mockError != nil {
+				mock.ExpectQuery(query).WillReturnError(tt.mockError)
+			}
+			if tt.unlockRows != nil {
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "post_unlocks"`).WillReturnRows(tt.unlockRows)
+			}
+
+			result, err := CanViewPost(tt.viewerID, tt.post)
+
+			assert.Equal(t, tt.expectedResult, result)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
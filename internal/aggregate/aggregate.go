@@ -0,0 +1,83 @@
+package aggregate
+
+import (
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+)
+
+// PostCounts regroupe les compteurs d'engagement d'un post pour un utilisateur donné
+type PostCounts struct {
+	PostID       string `json:"post_id"`
+	LikeCount    int64  `json:"like_count"`
+	CommentCount int64  `json:"comment_count"`
+	IsLiked      bool   `json:"is_liked"`
+}
+
+// PostStats calcule en une seule requête groupée les likes, commentaires et le statut "liké"
+// d'une page de posts. Les posts sans like ni commentaire sont présents avec des compteurs à zéro.
+func PostStats(postIDs []string, viewerID string) (map[string]PostCounts, error) {
+	stats := make(map[string]PostCounts, len(postIDs))
+	if len(postIDs) == 0 {
+		return stats, nil
+	}
+
+	var rows []PostCounts
+	if err := database.DB.Raw(`
+		SELECT post_id,
+		       SUM(like_count) AS like_count,
+		       SUM(comment_count) AS comment_count,
+		       BOOL_OR(is_liked) AS is_liked
+		FROM (
+			SELECT post_id, COUNT(*) AS like_count, 0 AS comment_count, BOOL_OR(user_id::text = ?) AS is_liked
+			FROM likes
+			WHERE post_id IN ?
+			GROUP BY post_id
+			UNION ALL
+			SELECT post_id, 0 AS like_count, COUNT(*) AS comment_count, FALSE AS is_liked
+			FROM comments
+			WHERE post_id IN ?
+			GROUP BY post_id
+		) AS counts
+		GROUP BY post_id`, viewerID, postIDs, postIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range postIDs {
+		stats[id] = PostCounts{PostID: id}
+	}
+	for _, row := range rows {
+		stats[row.PostID] = row
+	}
+	return stats, nil
+}
+
+// UnreadCounts calcule en une seule requête groupée le nombre de messages non lus
+// de chaque conversation, en ignorant ceux antérieurs à une suppression par l'utilisateur
+func UnreadCounts(conversationIDs []string, userID string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(conversationIDs))
+	if len(conversationIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ConversationID string
+		UnreadCount    int64
+	}
+	if err := database.DB.Table("messages").
+		Select("messages.conversation_id, COUNT(*) AS unread_count").
+		Joins("LEFT JOIN conversation_deletions ON conversation_deletions.conversation_id = messages.conversation_id AND conversation_deletions.user_id = ?", userID).
+		Where("messages.conversation_id IN ? AND messages.receiver_id = ? AND messages.is_read = false AND messages.is_deleted = false", conversationIDs, userID).
+		Where("conversation_deletions.deleted_at IS NULL OR messages.created_at > conversation_deletions.deleted_at").
+		Group("messages.conversation_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range conversationIDs {
+		counts[id] = 0
+	}
+	for _, row := range rows {
+		counts[row.ConversationID] = row.UnreadCount
+	}
+	return counts, nil
+}
@@ -0,0 +1,109 @@
+package aggregate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func makeIDs(prefix string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return ids
+}
+
+func TestPostStats(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	tests := []struct {
+		name           string
+		postIDs        []string
+		mockRows       *sqlmock.Rows
+		expectedCounts map[string]PostCounts
+	}{
+		{
+			name:           "No posts does not query",
+			postIDs:        []string{},
+			mockRows:       nil,
+			expectedCounts: map[string]PostCounts{},
+		},
+		{
+			name:    "Posts without activity get zero counts",
+			postIDs: []string{"post1", "post2"},
+			mockRows: sqlmock.NewRows([]string{"post_id", "like_count", "comment_count", "is_liked"}).
+				AddRow("post1", 3, 2, true),
+			expectedCounts: map[string]PostCounts{
+				"post1": {PostID: "post1", LikeCount: 3, CommentCount: 2, IsLiked: true},
+				"post2": {PostID: "post2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockRows != nil {
+				mock.ExpectQuery(`SELECT post_id`).WillReturnRows(tt.mockRows)
+			}
+
+			counts, err := PostStats(tt.postIDs, "viewer1")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedCounts, counts)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// Le nombre de requêtes doit rester constant quelle que soit la taille de la page :
+// sqlmock échoue sur toute requête non attendue.
+func TestQueryCountIsConstant(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	for _, size := range []int{1, 10, 100, 1000} {
+		t.Run(fmt.Sprintf("page of %d", size), func(t *testing.T) {
+			mock.ExpectQuery(`SELECT post_id`).
+				WillReturnRows(sqlmock.NewRows([]string{"post_id", "like_count", "comment_count", "is_liked"}))
+			_, err := PostStats(makeIDs("post", size), "viewer1")
+			assert.NoError(t, err)
+
+			mock.ExpectQuery(`SELECT messages.conversation_id`).
+				WillReturnRows(sqlmock.NewRows([]string{"conversation_id", "unread_count"}))
+			_, err = UnreadCounts(makeIDs("conv", size), "user1")
+			assert.NoError(t, err)
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// Une seule requête est attendue par itération : toute requête supplémentaire fait échouer le benchmark
+func BenchmarkPostStats(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("page of %d", size), func(b *testing.B) {
+			mock := testutil.SetupMockDB(b)
+			ids := makeIDs("post", size)
+
+			for i := 0; i < b.N; i++ {
+				rows := sqlmock.NewRows([]string{"post_id", "like_count", "comment_count", "is_liked"})
+				for _, id := range ids {
+					rows.AddRow(id, 1, 1, false)
+				}
+				mock.ExpectQuery(`SELECT post_id`).WillReturnRows(rows)
+
+				if _, err := PostStats(ids, "viewer1"); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
@@ -178,7 +178,7 @@ func Signup(c *gin.Context) {
 		filename := fmt.Sprintf("user_%s%s", userID, ext)
 		contentType := header.Header.Get("Content-Type")
 
-		url, err := storage.UploadToS3(file, filename, contentType, "avatars")
+		key, err := storage.UploadToS3(file, filename, contentType, "avatars")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'upload S3", "details": err.Error()})
 			logs.LogJSON("ERROR", "S3 upload error", map[string]interface{}{
@@ -187,7 +187,8 @@ func Signup(c *gin.Context) {
 			})
 			return
 		}
-		avatarURL = url
+		// Les avatars restent publics : on conserve leur URL complète
+		avatarURL = storage.PublicURL(key)
 	}
 
 	// Étape 3 – Enregistrement final en BDD
@@ -0,0 +1,165 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/price"
+	"github.com/stripe/stripe-go/v78/product"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+)
+
+const currency = "eur"
+
+// ErrNoStripeAccount est retournée pour un créateur sans compte Stripe connecté
+var ErrNoStripeAccount = errors.New("le créateur n'a pas de compte Stripe")
+
+// ToCents convertit un prix en euros en centimes
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// CurrentPrice retourne le tarif Stripe actif du créateur.
+// Le produit et le tarif ne sont créés que s'ils n'existent pas encore ou si le prix a changé.
+func CurrentPrice(creator Creator) (*CreatorPrice, error) {
+	if creator.StripeAccountID == "" {
+		return nil, ErrNoStripeAccount
+	}
+
+	var current CreatorPrice
+	err := database.DB.Where("creator_id = ? AND active = true", creator.ID).First(&current).Error
+	if err == nil && current.UnitAmount == ToCents(creator.SubscriptionPrice) {
+		return &current, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("récupération du tarif : %w", err)
+	}
+
+	return ChangePrice(creator, creator.SubscriptionPrice)
+}
+
+// ChangePrice crée un nouveau tarif Stripe pour le créateur et archive le précédent
+func ChangePrice(creator Creator, amount float64) (*CreatorPrice, error) {
+	createdPrice, err := newStripePrice(creator, amount, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	newPrice := CreatorPrice{
+		CreatedAt:     time.Now(),
+		CreatorID:     creator.ID,
+		StripePriceID: createdPrice.ID,
+		UnitAmount:    createdPrice.UnitAmount,
+		Currency:      currency,
+		Active:        true,
+	}
+
+	var previous []CreatorPrice
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("creator_id = ? AND active = true", creator.ID).Find(&previous).Error; err != nil {
+			return err
+		}
+		if len(previous) > 0 {
+			if err := tx.Model(&CreatorPrice{}).
+				Where("creator_id = ? AND active = true", creator.ID).
+				Updates(map[string]interface{}{"active": false, "archived_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&newPrice).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("enregistrement du tarif : %w", err)
+	}
+
+	// Les abonnements en cours gardent leur tarif : l'ancien est seulement retiré des nouveaux paiements
+	for _, old := range previous {
+		archiveStripePrice(creator, old.StripePriceID)
+	}
+
+	return &newPrice, nil
+}
+
+// newStripePrice crée sur le produit du créateur un tarif renouvelé tous les months mois
+func newStripePrice(creator Creator, amount float64, months int) (*stripe.Price, error) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+
+	if creator.StripeAccountID == "" {
+		return nil, ErrNoStripeAccount
+	}
+
+	productID, err := ensureProduct(creator)
+	if err != nil {
+		return nil, err
+	}
+
+	priceParams := &stripe.PriceParams{
+		Product:    stripe.String(productID),
+		Currency:   stripe.String(currency),
+		UnitAmount: stripe.Int64(ToCents(amount)),
+		Recurring: &stripe.PriceRecurringParams{
+			Interval:      stripe.String("month"),
+			IntervalCount: stripe.Int64(int64(months)),
+		},
+	}
+	priceParams.SetStripeAccount(creator.StripeAccountID)
+	createdPrice, err := price.New(priceParams)
+	if err != nil {
+		return nil, fmt.Errorf("création du tarif Stripe : %w", err)
+	}
+	return createdPrice, nil
+}
+
+// archiveStripePrice retire un tarif des nouveaux paiements sur Stripe. Un échec est seulement journalisé.
+func archiveStripePrice(creator Creator, stripePriceID string) {
+	archiveParams := &stripe.PriceParams{Active: stripe.Bool(false)}
+	archiveParams.SetStripeAccount(creator.StripeAccountID)
+	if _, err := price.Update(stripePriceID, archiveParams); err != nil {
+		logs.LogJSON("WARN", "Error archiving Stripe price", map[string]interface{}{
+			"error":   err.Error(),
+			"userID":  creator.ID,
+			"priceID": stripePriceID,
+		})
+	}
+}
+
+// ensureProduct retourne le produit Stripe du créateur, en le créant sur son compte connecté au besoin
+func ensureProduct(creator Creator) (string, error) {
+	var existing CreatorProduct
+	err := database.DB.First(&existing, "creator_id = ?", creator.ID).Error
+	if err == nil && existing.StripeAccountID == creator.StripeAccountID {
+		return existing.StripeProductID, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("récupération du produit : %w", err)
+	}
+
+	productParams := &stripe.ProductParams{
+		Name: stripe.String(fmt.Sprintf("Abonnement OnlyFeed au créateur %s", creator.Username)),
+	}
+	productParams.SetStripeAccount(creator.StripeAccountID)
+	createdProduct, err := product.New(productParams)
+	if err != nil {
+		return "", fmt.Errorf("création du produit Stripe : %w", err)
+	}
+
+	record := CreatorProduct{
+		CreatorID:       creator.ID,
+		CreatedAt:       time.Now(),
+		StripeAccountID: creator.StripeAccountID,
+		StripeProductID: createdProduct.ID,
+	}
+	if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error; err != nil {
+		return "", fmt.Errorf("enregistrement du produit : %w", err)
+	}
+
+	return createdProduct.ID, nil
+}
@@ -0,0 +1,148 @@
+package billing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v78"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+// stubStripe redirige l'API Stripe vers un serveur de test et retourne les appels reçus
+func stubStripe(t *testing.T) func() []string {
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_stub")
+
+	var mu sync.Mutex
+	var calls []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		var response map[string]interface{}
+		switch r.URL.Path {
+		case "/v1/products":
+			response = map[string]interface{}{"id": "prod_new", "object": "product"}
+		case "/v1/prices":
+			response = map[string]interface{}{"id": "price_new", "object": "price", "unit_amount": json.Number(r.PostForm.Get("unit_amount"))}
+		case "/v1/subscriptions/sub_1":
+			response = map[string]interface{}{
+				"id":     "sub_1",
+				"object": "subscription",
+				"items": map[string]interface{}{
+					"object": "list",
+					"data": []map[string]interface{}{
+						{"id": "si_1", "object": "subscription_item", "price": map[string]interface{}{"id": "price_old", "object": "price"}},
+					},
+				},
+			}
+		default:
+			response = map[string]interface{}{"id": "price_old", "object": "price", "active": r.PostForm.Get("active") == "true"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+
+	original := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:           stripe.String(server.URL),
+		LeveledLogger: &stripe.LeveledLogger{Level: stripe.LevelNull},
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, original)
+		server.Close()
+	})
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+var priceColumns = []string{"id", "creator_id", "stripe_price_id", "unit_amount", "currency", "active"}
+
+func TestCurrentPrice(t *testing.T) {
+	creator := Creator{ID: "creator1", Username: "alice", StripeAccountID: "acct_1", SubscriptionPrice: 9.99}
+
+	t.Run("Tarif existant réutilisé", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).
+			WillReturnRows(sqlmock.NewRows(priceColumns).AddRow("p1", "creator1", "price_old", 999, "eur", true))
+
+		current, err := CurrentPrice(creator)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "price_old", current.StripePriceID)
+		assert.Empty(t, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Premier paiement : produit et tarif créés", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).WillReturnRows(sqlmock.NewRows(priceColumns))
+		mock.ExpectQuery(`SELECT \* FROM "creator_products"`).WillReturnRows(sqlmock.NewRows([]string{"creator_id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "creator_products".*ON CONFLICT`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).WillReturnRows(sqlmock.NewRows(priceColumns))
+		mock.ExpectQuery(`INSERT INTO "creator_prices"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+		mock.ExpectCommit()
+
+		current, err := CurrentPrice(creator)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "price_new", current.StripePriceID)
+		assert.Equal(t, int64(999), current.UnitAmount)
+		assert.Equal(t, []string{"POST /v1/products", "POST /v1/prices"}, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Créateur sans compte Stripe", func(t *testing.T) {
+		testutil.SetupMockDB(t)
+
+		_, err := CurrentPrice(Creator{ID: "creator1", SubscriptionPrice: 5})
+
+		assert.ErrorIs(t, err, ErrNoStripeAccount)
+	})
+}
+
+func TestChangePrice(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+	calls := stubStripe(t)
+	creator := Creator{ID: "creator1", Username: "alice", StripeAccountID: "acct_1", SubscriptionPrice: 5}
+
+	mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"creator_id", "stripe_account_id", "stripe_product_id"}).
+			AddRow("creator1", "acct_1", "prod_1"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).
+		WillReturnRows(sqlmock.NewRows(priceColumns).AddRow("p1", "creator1", "price_old", 500, "eur", true))
+	mock.ExpectExec(`UPDATE "creator_prices" SET "active"=\$1,"archived_at"=\$2`).
+		WithArgs(false, sqlmock.AnyArg(), "creator1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "creator_prices"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+	mock.ExpectCommit()
+
+	newPrice, err := ChangePrice(creator, 7.5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(750), newPrice.UnitAmount)
+	// Le produit existant est réutilisé, l'ancien tarif est archivé sur Stripe
+	assert.Equal(t, []string{"POST /v1/prices", "POST /v1/prices/price_old"}, calls())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
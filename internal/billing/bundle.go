@@ -0,0 +1,102 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+)
+
+// BundleMonths liste les durées proposées pour les offres groupées
+var BundleMonths = []int{3, 6, 12}
+
+// ErrBundleNotFound est retournée pour une offre inexistante, archivée ou appartenant à un autre créateur
+var ErrBundleNotFound = errors.New("offre groupée introuvable")
+
+// ErrBundleExists est retournée quand le créateur propose déjà une offre active de la même durée
+var ErrBundleExists = errors.New("offre groupée déjà existante pour cette durée")
+
+// IsValidBundleMonths indique si la durée fait partie des offres groupées proposées
+func IsValidBundleMonths(months int) bool {
+	for _, m := range BundleMonths {
+		if m == months {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveBundles retourne les offres groupées actives d'un créateur, de la plus courte à la plus longue
+func ActiveBundles(creatorID string) ([]Bundle, error) {
+	var bundles []Bundle
+	err := database.DB.Where("creator_id = ? AND active = true", creatorID).Order("months ASC").Find(&bundles).Error
+	return bundles, err
+}
+
+// FindActiveBundle retourne une offre groupée active du créateur
+func FindActiveBundle(creatorID, bundleID string) (*Bundle, error) {
+	return findBundle("id = ? AND creator_id = ? AND active = true", bundleID, creatorID)
+}
+
+// FindBundle retourne une offre groupée du créateur, même archivée
+func FindBundle(creatorID, bundleID string) (*Bundle, error) {
+	return findBundle("id = ? AND creator_id = ?", bundleID, creatorID)
+}
+
+func findBundle(query string, args ...interface{}) (*Bundle, error) {
+	var bundle Bundle
+	if err := database.DB.Where(query, args...).First(&bundle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBundleNotFound
+		}
+		return nil, fmt.Errorf("récupération de l'offre groupée : %w", err)
+	}
+	return &bundle, nil
+}
+
+// CreateBundle crée une offre groupée et son tarif Stripe renouvelé tous les input.Months mois
+func CreateBundle(creator Creator, input BundleInput) (*Bundle, error) {
+	var existing int64
+	if err := database.DB.Model(&Bundle{}).
+		Where("creator_id = ? AND months = ? AND active = true", creator.ID, input.Months).
+		Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("vérification des offres groupées : %w", err)
+	}
+	if existing > 0 {
+		return nil, ErrBundleExists
+	}
+
+	createdPrice, err := newStripePrice(creator, input.Price, input.Months)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := Bundle{
+		CreatedAt:     time.Now(),
+		CreatorID:     creator.ID,
+		Months:        input.Months,
+		Price:         input.Price,
+		StripePriceID: createdPrice.ID,
+		Active:        true,
+	}
+	if err := database.DB.Create(&bundle).Error; err != nil {
+		return nil, fmt.Errorf("enregistrement de l'offre groupée : %w", err)
+	}
+	return &bundle, nil
+}
+
+// ArchiveBundle retire une offre groupée des nouveaux abonnements. Les abonnés actuels la conservent.
+func ArchiveBundle(creator Creator, bundle *Bundle) error {
+	now := time.Now()
+	if err := database.DB.Model(bundle).Updates(map[string]interface{}{
+		"active":      false,
+		"archived_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("archivage de l'offre groupée : %w", err)
+	}
+	archiveStripePrice(creator, bundle.StripePriceID)
+	return nil
+}
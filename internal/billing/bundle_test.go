@@ -0,0 +1,58 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestCreateBundle(t *testing.T) {
+	creator := Creator{ID: "creator1", Username: "alice", StripeAccountID: "acct_1", SubscriptionPrice: 5}
+
+	t.Run("Offre créée avec un tarif Stripe sur plusieurs mois", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "creator_bundles"`).
+			WithArgs("creator1", 6).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+			WillReturnRows(sqlmock.NewRows(productRows).AddRow("creator1", "acct_1", "prod_1"))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "creator_bundles"`).
+			WithArgs(sqlmock.AnyArg(), "creator1", 6, 25.0, "price_new", true, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("bundle1"))
+		mock.ExpectCommit()
+
+		bundle, err := CreateBundle(creator, BundleInput{Months: 6, Price: 25})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "price_new", bundle.StripePriceID)
+		assert.Equal(t, []string{"POST /v1/prices"}, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Offre déjà proposée pour cette durée", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "creator_bundles"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		_, err := CreateBundle(creator, BundleInput{Months: 3, Price: 12})
+
+		assert.ErrorIs(t, err, ErrBundleExists)
+		assert.Empty(t, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIsValidBundleMonths(t *testing.T) {
+	assert.True(t, IsValidBundleMonths(3))
+	assert.True(t, IsValidBundleMonths(12))
+	assert.False(t, IsValidBundleMonths(1))
+	assert.False(t, IsValidBundleMonths(24))
+}
@@ -0,0 +1,583 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+)
+
+// GetPriceHistory GET /api/me/price-history
+func GetPriceHistory(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var prices []CreatorPrice
+	if err := database.DB.Where("creator_id = ?", userID).Order("created_at DESC").Find(&prices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération de l'historique des prix"})
+		logs.LogJSON("ERROR", "Error retrieving price history", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	var changes []PriceChange
+	if err := database.DB.Where("creator_id = ?", userID).Order("created_at DESC").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération de l'historique des prix"})
+		logs.LogJSON("ERROR", "Error retrieving price changes", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prices":  prices,
+		"changes": changes,
+	})
+	logs.LogJSON("INFO", "Price history retrieved successfully", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+	})
+}
+
+// GetPriceChange GET /api/price-changes/:id
+// Détail d'un changement de prix, réservé au créateur et aux abonnés qui en ont été prévenus
+func GetPriceChange(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	changeID := c.Param("id")
+
+	var change PriceChange
+	if err := database.DB.First(&change, "id = ?", changeID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Changement de prix introuvable"})
+		logs.LogJSON("WARN", "Price change not found", map[string]interface{}{
+			"route":         route,
+			"userID":        userID,
+			"priceChangeID": changeID,
+		})
+		return
+	}
+
+	if change.CreatorID != userID {
+		var notified int64
+		if err := database.DB.Model(&notification.Notification{}).
+			Where("user_id = ? AND type = ? AND target_id = ?", userID, notification.TypePriceChange, change.ID).
+			Count(&notified).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du changement de prix"})
+			logs.LogJSON("ERROR", "Error checking price change notification", map[string]interface{}{
+				"error":         err.Error(),
+				"route":         route,
+				"userID":        userID,
+				"priceChangeID": changeID,
+			})
+			return
+		}
+		if notified == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Changement de prix introuvable"})
+			logs.LogJSON("WARN", "Price change not visible to user", map[string]interface{}{
+				"route":         route,
+				"userID":        userID,
+				"priceChangeID": changeID,
+			})
+			return
+		}
+		// Le nombre d'abonnés concernés ne regarde que le créateur
+		change.AffectedCount = 0
+	}
+
+	c.JSON(http.StatusOK, gin.H{"price_change": change})
+}
+
+// GetCreatorTiers GET /api/users/username/:username/tiers
+// Paliers proposés par un créateur, du moins cher au plus complet
+func GetCreatorTiers(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	username := c.Param("username")
+
+	var creator struct{ ID string }
+	if err := database.DB.Table("users").Select("id").Where("username = ? AND is_creator = true", username).Take(&creator).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Créateur introuvable"})
+		return
+	}
+
+	tiers, err := ActiveTiers(creator.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des paliers"})
+		logs.LogJSON("ERROR", "Error retrieving tiers", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     route,
+			"userID":    userID,
+			"creatorID": creator.ID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tiers": tiers})
+}
+
+// GetMyTiers GET /api/me/tiers
+// Paliers du créateur connecté, y compris les paliers archivés
+func GetMyTiers(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var tiers []Tier
+	if err := database.DB.Where("creator_id = ?", userID).Order("rank ASC").Find(&tiers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des paliers"})
+		logs.LogJSON("ERROR", "Error retrieving tiers", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tiers": tiers})
+}
+
+// CreateMyTier POST /api/me/tiers
+func CreateMyTier(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	input, ok := bindTierInput(c)
+	if !ok {
+		return
+	}
+	creator, ok := loadCreator(c)
+	if !ok {
+		return
+	}
+
+	tier, err := CreateTier(creator, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTooManyTiers):
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Vous ne pouvez pas avoir plus de %d paliers", MaxTiers)})
+		case errors.Is(err, ErrNoStripeAccount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vous devez d'abord connecter votre compte Stripe"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du palier"})
+			logs.LogJSON("ERROR", "Error creating tier", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"tier": tier})
+	logs.LogJSON("INFO", "Tier created successfully", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+		"tierID": tier.ID,
+	})
+}
+
+// UpdateMyTier PUT /api/me/tiers/:id
+func UpdateMyTier(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	tierID := c.Param("id")
+
+	input, ok := bindTierInput(c)
+	if !ok {
+		return
+	}
+	creator, ok := loadCreator(c)
+	if !ok {
+		return
+	}
+	tier, ok := loadOwnTier(c, tierID)
+	if !ok {
+		return
+	}
+
+	if err := UpdateTier(creator, tier, input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du palier"})
+		logs.LogJSON("ERROR", "Error updating tier", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"tierID": tierID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tier": tier})
+}
+
+// DeleteMyTier DELETE /api/me/tiers/:id
+// Le palier est archivé : ses abonnés actuels le conservent, il n'est plus proposé
+func DeleteMyTier(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	tierID := c.Param("id")
+
+	creator, ok := loadCreator(c)
+	if !ok {
+		return
+	}
+	tier, ok := loadOwnTier(c, tierID)
+	if !ok {
+		return
+	}
+
+	if err := ArchiveTier(creator, tier); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression du palier"})
+		logs.LogJSON("ERROR", "Error archiving tier", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"tierID": tierID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Palier archivé"})
+}
+
+// bindTierInput lit et valide le corps d'une requête de palier, et répond à sa place si invalide
+func bindTierInput(c *gin.Context) (TierInput, bool) {
+	var input TierInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		return input, false
+	}
+	if input.Price < MinTierPrice {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le prix d'un palier doit être d'au moins %.2f€", MinTierPrice)})
+		return input, false
+	}
+	return input, true
+}
+
+// loadCreator charge le créateur connecté, et répond à sa place s'il n'est pas créateur
+func loadCreator(c *gin.Context) (Creator, bool) {
+	var creator Creator
+	if err := database.DB.Table("users").
+		Select("id, username, stripe_account_id, subscription_price, trial_days").
+		Where("id = ? AND is_creator = true", c.GetString("user_id")).
+		Take(&creator).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Réservé aux créateurs"})
+		logs.LogJSON("WARN", "Creator required", map[string]interface{}{
+			"route":  c.FullPath(),
+			"userID": c.GetString("user_id"),
+		})
+		return creator, false
+	}
+	return creator, true
+}
+
+// loadOwnTier charge un palier actif du créateur connecté, et répond à sa place s'il est introuvable
+func loadOwnTier(c *gin.Context, tierID string) (*Tier, bool) {
+	tier, err := FindActiveTier(c.GetString("user_id"), tierID)
+	if err != nil {
+		if errors.Is(err, ErrTierNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Palier introuvable"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du palier"})
+		logs.LogJSON("ERROR", "Error retrieving tier", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  c.FullPath(),
+			"userID": c.GetString("user_id"),
+			"tierID": tierID,
+		})
+		return nil, false
+	}
+	return tier, true
+}
+
+// GetCreatorBundles GET /api/users/username/:username/bundles
+// Offres groupées de plusieurs mois proposées par un créateur
+func GetCreatorBundles(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	username := c.Param("username")
+
+	var creator struct{ ID string }
+	if err := database.DB.Table("users").Select("id").Where("username = ? AND is_creator = true", username).Take(&creator).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Créateur introuvable"})
+		return
+	}
+
+	bundles, err := ActiveBundles(creator.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des offres groupées"})
+		logs.LogJSON("ERROR", "Error retrieving bundles", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     route,
+			"userID":    userID,
+			"creatorID": creator.ID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+// GetMyBundles GET /api/me/bundles
+// Offres groupées du créateur connecté, y compris les offres archivées
+func GetMyBundles(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var bundles []Bundle
+	if err := database.DB.Where("creator_id = ?", userID).Order("months ASC, created_at DESC").Find(&bundles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des offres groupées"})
+		logs.LogJSON("ERROR", "Error retrieving bundles", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+// CreateMyBundle POST /api/me/bundles
+func CreateMyBundle(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var input BundleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		return
+	}
+	if !IsValidBundleMonths(input.Months) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Durée d'offre groupée invalide"})
+		return
+	}
+	if input.Price < MinTierPrice {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le prix d'une offre groupée doit être d'au moins %.2f€", MinTierPrice)})
+		return
+	}
+	creator, ok := loadCreator(c)
+	if !ok {
+		return
+	}
+
+	bundle, err := CreateBundle(creator, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrBundleExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Vous proposez déjà une offre groupée de cette durée"})
+		case errors.Is(err, ErrNoStripeAccount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vous devez d'abord connecter votre compte Stripe"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de l'offre groupée"})
+			logs.LogJSON("ERROR", "Error creating bundle", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"bundle": bundle})
+	logs.LogJSON("INFO", "Bundle created successfully", map[string]interface{}{
+		"route":    route,
+		"userID":   userID,
+		"bundleID": bundle.ID,
+	})
+}
+
+// DeleteMyBundle DELETE /api/me/bundles/:id
+// L'offre est archivée : ses abonnés actuels la conservent, elle n'est plus proposée
+func DeleteMyBundle(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	bundleID := c.Param("id")
+
+	creator, ok := loadCreator(c)
+	if !ok {
+		return
+	}
+	bundle, err := FindActiveBundle(userID, bundleID)
+	if err != nil {
+		if errors.Is(err, ErrBundleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Offre groupée introuvable"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération de l'offre groupée"})
+		logs.LogJSON("ERROR", "Error retrieving bundle", map[string]interface{}{
+			"error":    err.Error(),
+			"route":    route,
+			"userID":   userID,
+			"bundleID": bundleID,
+		})
+		return
+	}
+
+	if err := ArchiveBundle(creator, bundle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression de l'offre groupée"})
+		logs.LogJSON("ERROR", "Error archiving bundle", map[string]interface{}{
+			"error":    err.Error(),
+			"route":    route,
+			"userID":   userID,
+			"bundleID": bundleID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Offre groupée archivée"})
+}
+
+// GetMyPromoCodes GET /api/me/promo-codes
+// Codes promo du créateur connecté avec leur nombre d'utilisations
+func GetMyPromoCodes(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var codes []PromoCode
+	if err := database.DB.Where("creator_id = ?", userID).Order("created_at DESC").Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des codes promo"})
+		logs.LogJSON("ERROR", "Error retrieving promo codes", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promo_codes": codes})
+}
+
+// CreateMyPromoCode POST /api/me/promo-codes
+func CreateMyPromoCode(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var input PromoCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		return
+	}
+	if msg := ValidatePromoCodeInput(input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	creator, ok := loadCreator(c)
+	if !ok {
+		return
+	}
+
+	promo, err := CreatePromoCode(creator, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPromoCodeDuplicate):
+			c.JSON(http.StatusConflict, gin.H{"error": "Ce code promo existe déjà"})
+		case errors.Is(err, ErrNoStripeAccount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vous devez d'abord connecter votre compte Stripe"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du code promo"})
+			logs.LogJSON("ERROR", "Error creating promo code", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"promo_code": promo})
+	logs.LogJSON("INFO", "Promo code created successfully", map[string]interface{}{
+		"route":       route,
+		"userID":      userID,
+		"promoCodeID": promo.ID,
+	})
+}
+
+// DeleteMyPromoCode DELETE /api/me/promo-codes/:id
+// Le code est désactivé et conservé pour l'historique des utilisations
+func DeleteMyPromoCode(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	promoCodeID := c.Param("id")
+
+	creator, ok := loadCreator(c)
+	if !ok {
+		return
+	}
+	promo, ok := loadOwnPromoCode(c, promoCodeID)
+	if !ok {
+		return
+	}
+
+	if err := DeactivatePromoCode(creator, promo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la désactivation du code promo"})
+		logs.LogJSON("ERROR", "Error deactivating promo code", map[string]interface{}{
+			"error":       err.Error(),
+			"route":       route,
+			"userID":      userID,
+			"promoCodeID": promoCodeID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Code promo désactivé"})
+}
+
+// GetMyPromoCodeRedemptions GET /api/me/promo-codes/:id/redemptions
+func GetMyPromoCodeRedemptions(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	promoCodeID := c.Param("id")
+
+	promo, ok := loadOwnPromoCode(c, promoCodeID)
+	if !ok {
+		return
+	}
+
+	var redemptions []PromoRedemption
+	if err := database.DB.Where("promo_code_id = ?", promo.ID).Order("created_at DESC").Find(&redemptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des utilisations"})
+		logs.LogJSON("ERROR", "Error retrieving promo code redemptions", map[string]interface{}{
+			"error":       err.Error(),
+			"route":       route,
+			"userID":      userID,
+			"promoCodeID": promoCodeID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"promo_code":  promo,
+		"redemptions": redemptions,
+	})
+}
+
+// loadOwnPromoCode charge un code promo du créateur connecté, et répond à sa place s'il est introuvable
+func loadOwnPromoCode(c *gin.Context, promoCodeID string) (*PromoCode, bool) {
+	var promo PromoCode
+	if err := database.DB.Where("id = ? AND creator_id = ?", promoCodeID, c.GetString("user_id")).Take(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Code promo introuvable"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du code promo"})
+		logs.LogJSON("ERROR", "Error retrieving promo code", map[string]interface{}{
+			"error":       err.Error(),
+			"route":       c.FullPath(),
+			"userID":      c.GetString("user_id"),
+			"promoCodeID": promoCodeID,
+		})
+		return nil, false
+	}
+	return &promo, true
+}
@@ -0,0 +1,156 @@
+package billing
+
+import "time"
+
+// Creator contient les informations d'un créateur nécessaires à la facturation
+type Creator struct {
+	ID                string
+	Username          string
+	StripeAccountID   string
+	SubscriptionPrice float64
+	TrialDays         int
+}
+
+// CreatorProduct est le produit Stripe d'abonnement d'un créateur, créé une seule fois sur son compte connecté
+type CreatorProduct struct {
+	CreatorID       string `gorm:"primaryKey"`
+	CreatedAt       time.Time
+	StripeAccountID string
+	StripeProductID string
+}
+
+// CreatorPrice est un tarif Stripe du créateur. Un seul tarif est actif à la fois,
+// les précédents sont archivés et conservés pour l'historique.
+type CreatorPrice struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CreatorID     string     `json:"creator_id" gorm:"index"`
+	StripePriceID string     `json:"stripe_price_id"`
+	UnitAmount    int64      `json:"unit_amount"` // en centimes
+	Currency      string     `json:"currency"`
+	Active        bool       `json:"active"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+}
+
+// Modes de changement de prix choisis par le créateur
+const (
+	PriceChangeGrandfather = "grandfather" // les abonnés actuels gardent leur prix
+	PriceChangeMigrate     = "migrate"     // les abonnés actuels passent au nouveau prix au renouvellement
+)
+
+// Statuts d'un changement de prix
+const (
+	PriceChangePending    = "pending"
+	PriceChangeApplied    = "applied"
+	PriceChangeSuperseded = "superseded"
+)
+
+// PriceChange historise les changements de prix d'un créateur
+type PriceChange struct {
+	ID               string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt        time.Time  `json:"created_at"`
+	CreatorID        string     `json:"creator_id" gorm:"index"`
+	OldAmount        int64      `json:"old_amount"` // en centimes
+	NewAmount        int64      `json:"new_amount"` // en centimes
+	OldStripePriceID string     `json:"-"`
+	NewStripePriceID string     `json:"-"`
+	Mode             string     `json:"mode"`
+	Status           string     `json:"status"`
+	EffectiveAt      time.Time  `json:"effective_at"`
+	AppliedAt        *time.Time `json:"applied_at,omitempty"`
+	AffectedCount    int        `json:"affected_count,omitempty"`
+}
+
+// Tier est un palier d'abonnement d'un créateur (par exemple Basic, VIP, Ultimate).
+// Le rang suit le prix : les paliers sont classés du moins cher au plus cher, paliers archivés compris.
+// Un post exige un rang minimum et un abonné y a accès si le rang de son palier est au moins égal.
+// L'abonnement de base au prix SubscriptionPrice du créateur a le rang 0.
+type Tier struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CreatorID     string     `json:"creator_id" gorm:"index"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Rank          int        `json:"rank"`
+	Price         float64    `json:"price"`
+	StripePriceID string     `json:"-"`
+	Active        bool       `json:"active"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+}
+
+func (Tier) TableName() string {
+	return "creator_tiers"
+}
+
+// TierInput est le corps des requêtes de création et de modification d'un palier
+type TierInput struct {
+	Name        string  `json:"name" binding:"required"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" binding:"required"`
+}
+
+// Bundle est une offre groupée de plusieurs mois de l'abonnement de base, payée en une fois à chaque renouvellement
+type Bundle struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CreatorID     string     `json:"creator_id" gorm:"index"`
+	Months        int        `json:"months"`
+	Price         float64    `json:"price"` // prix total de la période
+	StripePriceID string     `json:"-"`
+	Active        bool       `json:"active"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+}
+
+func (Bundle) TableName() string {
+	return "creator_bundles"
+}
+
+// BundleInput est le corps de la requête de création d'une offre groupée
+type BundleInput struct {
+	Months int     `json:"months" binding:"required"`
+	Price  float64 `json:"price" binding:"required"`
+}
+
+// Types de réduction d'un code promo
+const (
+	DiscountPercent = "percent" // pourcentage du prix
+	DiscountFixed   = "fixed"   // montant fixe en centimes
+)
+
+// PromoCode est un code promo d'un créateur, appliqué au premier paiement de l'abonnement
+// via un coupon Stripe créé sur son compte connecté
+type PromoCode struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt       time.Time  `json:"created_at"`
+	CreatorID       string     `json:"creator_id" gorm:"index"`
+	Code            string     `json:"code"`
+	DiscountType    string     `json:"discount_type"`
+	PercentOff      float64    `json:"percent_off,omitempty"`
+	AmountOff       int64      `json:"amount_off,omitempty"` // en centimes
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	MaxRedemptions  *int       `json:"max_redemptions,omitempty"`
+	RedemptionCount int        `json:"redemption_count"`
+	Active          bool       `json:"active"`
+	StripeCouponID  string     `json:"-"`
+}
+
+// PromoRedemption est l'utilisation d'un code promo lors d'une souscription
+type PromoRedemption struct {
+	ID              string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt       time.Time `json:"created_at"`
+	PromoCodeID     string    `json:"promo_code_id" gorm:"index"`
+	CreatorID       string    `json:"creator_id"`
+	SubscriberID    string    `json:"subscriber_id"`
+	StripeSessionID string    `json:"-" gorm:"uniqueIndex"`
+}
+
+// PromoCodeInput est le corps de la requête de création d'un code promo
+type PromoCodeInput struct {
+	Code           string     `json:"code" binding:"required"`
+	DiscountType   string     `json:"discount_type" binding:"required"`
+	PercentOff     float64    `json:"percent_off"`
+	AmountOff      float64    `json:"amount_off"` // en euros
+	ExpiresAt      *time.Time `json:"expires_at"`
+	MaxRedemptions *int       `json:"max_redemptions"`
+}
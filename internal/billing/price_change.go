@@ -0,0 +1,204 @@
+package billing
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/stripe/stripe-go/v78"
+	stripesub "github.com/stripe/stripe-go/v78/subscription"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+)
+
+// NoticePeriod est le délai de prévenance des abonnés avant qu'un nouveau prix leur soit appliqué
+const NoticePeriod = 7 * 24 * time.Hour
+
+// IsValidPriceChangeMode indique si le mode de changement de prix est connu
+func IsValidPriceChangeMode(mode string) bool {
+	return mode == PriceChangeGrandfather || mode == PriceChangeMigrate
+}
+
+// UpdateCreatorPrice change le prix d'abonnement de base d'un créateur.
+// creator.SubscriptionPrice est le nouveau prix, previousPrice l'ancien. Les abonnés à un palier ou à une offre groupée ne sont pas concernés.
+// En mode migrate, les abonnés actuels sont prévenus et passeront au nouveau prix
+// au premier renouvellement après la fin du délai de prévenance.
+func UpdateCreatorPrice(creator Creator, previousPrice float64, mode string) (*PriceChange, error) {
+	var previous CreatorPrice
+	if err := database.DB.Where("creator_id = ? AND active = true", creator.ID).Limit(1).Find(&previous).Error; err != nil {
+		return nil, fmt.Errorf("récupération du prix actuel : %w", err)
+	}
+
+	newPrice, err := ChangePrice(creator, creator.SubscriptionPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	change := PriceChange{
+		CreatedAt:        now,
+		CreatorID:        creator.ID,
+		OldAmount:        ToCents(previousPrice),
+		NewAmount:        newPrice.UnitAmount,
+		OldStripePriceID: previous.StripePriceID,
+		NewStripePriceID: newPrice.StripePriceID,
+		Mode:             mode,
+		Status:           PriceChangeApplied,
+		EffectiveAt:      now,
+		AppliedAt:        &now,
+	}
+
+	var subscriberIDs []string
+	if mode == PriceChangeMigrate {
+		if err := database.DB.Model(&subscription.Subscription{}).
+			Scopes(migratedSubscriptions(creator.ID)).
+			Pluck("subscriber_id", &subscriberIDs).Error; err != nil {
+			return nil, fmt.Errorf("récupération des abonnés : %w", err)
+		}
+
+		change.Status = PriceChangePending
+		change.EffectiveAt = now.Add(NoticePeriod)
+		change.AppliedAt = nil
+		change.AffectedCount = len(subscriberIDs)
+	}
+
+	// Un changement encore en attente est remplacé par le nouveau, quel que soit son mode :
+	// son prix vient d'être archivé et le choix le plus récent du créateur prévaut
+	if err := database.DB.Model(&PriceChange{}).
+		Where("creator_id = ? AND status = ?", creator.ID, PriceChangePending).
+		Update("status", PriceChangeSuperseded).Error; err != nil {
+		return nil, fmt.Errorf("remplacement du changement en attente : %w", err)
+	}
+
+	if err := database.DB.Create(&change).Error; err != nil {
+		return nil, fmt.Errorf("enregistrement du changement de prix : %w", err)
+	}
+
+	for _, subscriberID := range subscriberIDs {
+		notification.Emit(notification.Event{
+			RecipientID: subscriberID,
+			ActorID:     creator.ID,
+			Type:        notification.TypePriceChange,
+			TargetID:    change.ID,
+		})
+	}
+
+	return &change, nil
+}
+
+// ApplyDuePriceChanges bascule sur le nouveau tarif les abonnements Stripe
+// des changements de prix dont le délai de prévenance est écoulé
+func ApplyDuePriceChanges() error {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+
+	var changes []PriceChange
+	if err := database.DB.
+		Where("status = ? AND mode = ? AND effective_at <= ?", PriceChangePending, PriceChangeMigrate, time.Now()).
+		Find(&changes).Error; err != nil {
+		return fmt.Errorf("récupération des changements de prix : %w", err)
+	}
+
+	for _, change := range changes {
+		if err := applyPriceChange(change); err != nil {
+			logs.LogJSON("ERROR", "Error applying price change", map[string]interface{}{
+				"error":         err.Error(),
+				"userID":        change.CreatorID,
+				"priceChangeID": change.ID,
+			})
+		}
+	}
+	return nil
+}
+
+// migratedSubscriptions est un scope GORM qui restreint une requête sur "subscriptions" aux abonnements
+// qu'un changement de prix en mode migrate fait passer au nouveau tarif : abonnements Stripe récurrents
+// au prix de base, hors paliers, offres groupées et abonnements offerts. Les abonnés prévenus sont ceux migrés.
+func migratedSubscriptions(creatorID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("creator_id = ? AND status NOT IN ? AND stripe_subscription_id <> '' AND tier_id IS NULL AND bundle_id IS NULL",
+			creatorID, []string{subscription.StatusCancelled, subscription.StatusGifted})
+	}
+}
+
+// applyPriceChange met à jour les abonnements Stripe d'un créateur sans prorata :
+// le nouveau prix est facturé au prochain renouvellement
+func applyPriceChange(change PriceChange) error {
+	var product CreatorProduct
+	if err := database.DB.First(&product, "creator_id = ?", change.CreatorID).Error; err != nil {
+		return fmt.Errorf("récupération du compte Stripe : %w", err)
+	}
+
+	var subs []subscription.Subscription
+	if err := database.DB.
+		Scopes(migratedSubscriptions(change.CreatorID)).
+		Find(&subs).Error; err != nil {
+		return fmt.Errorf("récupération des abonnements : %w", err)
+	}
+
+	failed := 0
+	for _, sub := range subs {
+		if err := SwitchSubscriptionPrice(product.StripeAccountID, sub.StripeSubscriptionID, change.NewStripePriceID, ProrationNone); err != nil {
+			failed++
+			logs.LogJSON("ERROR", "Error migrating Stripe subscription price", map[string]interface{}{
+				"error":          err.Error(),
+				"userID":         sub.SubscriberID,
+				"creatorID":      change.CreatorID,
+				"subscriptionID": sub.StripeSubscriptionID,
+			})
+		}
+	}
+	// Les abonnements déjà migrés le seront à nouveau sans effet au prochain passage
+	if failed > 0 {
+		return fmt.Errorf("%d abonnement(s) non migré(s)", failed)
+	}
+
+	now := time.Now()
+	return database.DB.Model(&change).Updates(map[string]interface{}{
+		"status":     PriceChangeApplied,
+		"applied_at": now,
+	}).Error
+}
+
+// Comportements de prorata lors d'un changement de tarif d'abonnement
+const (
+	ProrationNone          = "none"              // nouveau prix au prochain renouvellement
+	ProrationCreate        = "create_prorations" // crédit ou débit reporté sur la prochaine facture
+	ProrationAlwaysInvoice = "always_invoice"    // différence facturée immédiatement
+)
+
+// SwitchSubscriptionPrice fait passer un abonnement Stripe sur un autre tarif du même créateur
+func SwitchSubscriptionPrice(stripeAccountID, stripeSubscriptionID, priceID, proration string) error {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+
+	getParams := &stripe.SubscriptionParams{}
+	getParams.SetStripeAccount(stripeAccountID)
+	current, err := stripesub.Get(stripeSubscriptionID, getParams)
+	if err != nil {
+		return err
+	}
+	if current.Items == nil || len(current.Items.Data) == 0 {
+		return fmt.Errorf("abonnement Stripe sans ligne")
+	}
+
+	item := current.Items.Data[0]
+	if item.Price != nil && item.Price.ID == priceID {
+		return nil
+	}
+
+	updateParams := &stripe.SubscriptionParams{
+		Items: []*stripe.SubscriptionItemsParams{
+			{
+				ID:    stripe.String(item.ID),
+				Price: stripe.String(priceID),
+			},
+		},
+		ProrationBehavior: stripe.String(proration),
+	}
+	updateParams.SetStripeAccount(stripeAccountID)
+	_, err = stripesub.Update(stripeSubscriptionID, updateParams)
+	return err
+}
@@ -0,0 +1,163 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestUpdateCreatorPrice(t *testing.T) {
+	creator := Creator{ID: "creator1", Username: "alice", StripeAccountID: "acct_1", SubscriptionPrice: 7.5}
+
+	tests := []struct {
+		name            string
+		mode            string
+		expectedStatus  string
+		expectedNotices int
+	}{
+		{
+			name:            "Abonnés actuels conservés à l'ancien prix",
+			mode:            PriceChangeGrandfather,
+			expectedStatus:  PriceChangeApplied,
+			expectedNotices: 0,
+		},
+		{
+			name:            "Abonnés actuels migrés après préavis",
+			mode:            PriceChangeMigrate,
+			expectedStatus:  PriceChangePending,
+			expectedNotices: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := testutil.SetupMockDB(t)
+			stubStripe(t)
+			recorder := &notification.Recorder{}
+			previous := notification.SetEmitter(recorder)
+			defer notification.SetEmitter(previous)
+
+			mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).
+				WillReturnRows(sqlmock.NewRows(priceColumns).AddRow("p1", "creator1", "price_old", 500, "eur", true))
+			mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+				WillReturnRows(sqlmock.NewRows([]string{"creator_id", "stripe_account_id", "stripe_product_id"}).
+					AddRow("creator1", "acct_1", "prod_1"))
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).
+				WillReturnRows(sqlmock.NewRows(priceColumns).AddRow("p1", "creator1", "price_old", 500, "eur", true))
+			mock.ExpectExec(`UPDATE "creator_prices"`).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(`INSERT INTO "creator_prices"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+			mock.ExpectCommit()
+			if tt.mode == PriceChangeMigrate {
+				mock.ExpectQuery(`SELECT "subscriber_id" FROM "subscriptions" WHERE .*stripe_subscription_id <> ''`).
+					WithArgs("creator1", subscription.StatusCancelled, subscription.StatusGifted).
+					WillReturnRows(sqlmock.NewRows([]string{"subscriber_id"}).AddRow("fan1").AddRow("fan2"))
+			}
+			// Le changement en attente est remplacé dans les deux modes
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "price_changes" SET "status"`).
+				WithArgs(PriceChangeSuperseded, "creator1", PriceChangePending).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "price_changes"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("change1"))
+			mock.ExpectCommit()
+
+			change, err := UpdateCreatorPrice(creator, 5, tt.mode)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, change.Status)
+			assert.Equal(t, int64(500), change.OldAmount)
+			assert.Equal(t, int64(750), change.NewAmount)
+			assert.Equal(t, "price_old", change.OldStripePriceID)
+			assert.Len(t, recorder.Events, tt.expectedNotices)
+			if tt.mode == PriceChangeMigrate {
+				assert.WithinDuration(t, time.Now().Add(NoticePeriod), change.EffectiveAt, time.Minute)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// Un abonné offert n'a pas d'abonnement Stripe à migrer : il n'est ni prévenu ni compté parmi les abonnés concernés
+func TestUpdateCreatorPriceSkipsGiftedSubscribers(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+	stubStripe(t)
+	recorder := &notification.Recorder{}
+	previous := notification.SetEmitter(recorder)
+	defer notification.SetEmitter(previous)
+
+	mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).
+		WillReturnRows(sqlmock.NewRows(priceColumns).AddRow("p1", "creator1", "price_old", 500, "eur", true))
+	mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"creator_id", "stripe_account_id", "stripe_product_id"}).
+			AddRow("creator1", "acct_1", "prod_1"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).
+		WillReturnRows(sqlmock.NewRows(priceColumns).AddRow("p1", "creator1", "price_old", 500, "eur", true))
+	mock.ExpectExec(`UPDATE "creator_prices"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "creator_prices"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+	mock.ExpectCommit()
+	// fan2 a reçu un abonnement offert : seul fan1, abonné par Stripe, est retenu
+	mock.ExpectQuery(`SELECT "subscriber_id" FROM "subscriptions" WHERE creator_id = \$1 AND status NOT IN \(\$2,\$3\) AND stripe_subscription_id <> ''`).
+		WithArgs("creator1", subscription.StatusCancelled, subscription.StatusGifted).
+		WillReturnRows(sqlmock.NewRows([]string{"subscriber_id"}).AddRow("fan1"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "price_changes" SET "status"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "price_changes"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("change1"))
+	mock.ExpectCommit()
+
+	change, err := UpdateCreatorPrice(Creator{ID: "creator1", StripeAccountID: "acct_1", SubscriptionPrice: 7.5}, 5, PriceChangeMigrate)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, change.AffectedCount)
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, "fan1", recorder.Events[0].RecipientID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateCreatorPriceLookupError(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+	stubStripe(t)
+
+	mock.ExpectQuery(`SELECT \* FROM "creator_prices"`).
+		WillReturnError(errors.New("connexion perdue"))
+
+	_, err := UpdateCreatorPrice(Creator{ID: "creator1", StripeAccountID: "acct_1", SubscriptionPrice: 7.5}, 5, PriceChangeGrandfather)
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyPriceChange(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+	calls := stubStripe(t)
+
+	mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"creator_id", "stripe_account_id", "stripe_product_id"}).
+			AddRow("creator1", "acct_1", "prod_1"))
+	mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE .*stripe_subscription_id <> ''`).
+		WithArgs("creator1", subscription.StatusCancelled, subscription.StatusGifted).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscriber_id", "creator_id", "status", "stripe_subscription_id", "price"}).
+			AddRow("s1", "fan1", "creator1", "active", "sub_1", 5.0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "price_changes" SET "applied_at"=\$1,"status"=\$2`).
+		WithArgs(sqlmock.AnyArg(), PriceChangeApplied, "change1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := applyPriceChange(PriceChange{ID: "change1", CreatorID: "creator1", NewStripePriceID: "price_new"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GET /v1/subscriptions/sub_1", "POST /v1/subscriptions/sub_1"}, calls())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,203 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/coupon"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+)
+
+// MaxTrialDays est la durée maximale d'un essai gratuit
+const MaxTrialDays = 30
+
+// Erreurs de validation d'un code promo
+var (
+	ErrPromoCodeInvalid     = errors.New("code promo invalide")
+	ErrPromoCodeExpired     = errors.New("code promo expiré")
+	ErrPromoCodeExhausted   = errors.New("code promo épuisé")
+	ErrPromoCodeAlreadyUsed = errors.New("code promo déjà utilisé")
+	ErrPromoCodeDuplicate   = errors.New("code promo déjà existant")
+)
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// NormalizePromoCode met un code promo au format stocké : majuscules, sans espaces autour
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidatePromoCodeInput vérifie les règles d'un nouveau code promo et retourne un message d'erreur sinon
+func ValidatePromoCodeInput(input PromoCodeInput) string {
+	if !promoCodePattern.MatchString(NormalizePromoCode(input.Code)) {
+		return "Le code doit contenir de 3 à 32 lettres, chiffres, tirets ou underscores"
+	}
+	switch input.DiscountType {
+	case DiscountPercent:
+		if input.PercentOff <= 0 || input.PercentOff > 100 {
+			return "Le pourcentage de réduction doit être compris entre 0 et 100"
+		}
+	case DiscountFixed:
+		if input.AmountOff <= 0 {
+			return "Le montant de la réduction doit être positif"
+		}
+	default:
+		return "Type de réduction invalide"
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return "La date d'expiration doit être dans le futur"
+	}
+	if input.MaxRedemptions != nil && *input.MaxRedemptions <= 0 {
+		return "Le nombre maximum d'utilisations doit être positif"
+	}
+	return ""
+}
+
+// CreatePromoCode crée un code promo et son coupon Stripe sur le compte connecté du créateur.
+// La réduction s'applique au premier paiement de l'abonnement.
+func CreatePromoCode(creator Creator, input PromoCodeInput) (*PromoCode, error) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+
+	if creator.StripeAccountID == "" {
+		return nil, ErrNoStripeAccount
+	}
+
+	code := NormalizePromoCode(input.Code)
+	var existing int64
+	if err := database.DB.Model(&PromoCode{}).Where("creator_id = ? AND code = ?", creator.ID, code).Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("vérification du code promo : %w", err)
+	}
+	if existing > 0 {
+		return nil, ErrPromoCodeDuplicate
+	}
+
+	couponParams := &stripe.CouponParams{
+		Duration: stripe.String(string(stripe.CouponDurationOnce)),
+		Name:     stripe.String(code),
+	}
+	if input.DiscountType == DiscountPercent {
+		couponParams.PercentOff = stripe.Float64(input.PercentOff)
+	} else {
+		couponParams.AmountOff = stripe.Int64(ToCents(input.AmountOff))
+		couponParams.Currency = stripe.String(currency)
+	}
+	// Les limites sont aussi portées par le coupon Stripe, en plus de la validation locale
+	if input.ExpiresAt != nil {
+		couponParams.RedeemBy = stripe.Int64(input.ExpiresAt.Unix())
+	}
+	if input.MaxRedemptions != nil {
+		couponParams.MaxRedemptions = stripe.Int64(int64(*input.MaxRedemptions))
+	}
+	couponParams.SetStripeAccount(creator.StripeAccountID)
+	createdCoupon, err := coupon.New(couponParams)
+	if err != nil {
+		return nil, fmt.Errorf("création du coupon Stripe : %w", err)
+	}
+
+	promo := PromoCode{
+		CreatedAt:      time.Now(),
+		CreatorID:      creator.ID,
+		Code:           code,
+		DiscountType:   input.DiscountType,
+		ExpiresAt:      input.ExpiresAt,
+		MaxRedemptions: input.MaxRedemptions,
+		Active:         true,
+		StripeCouponID: createdCoupon.ID,
+	}
+	if input.DiscountType == DiscountPercent {
+		promo.PercentOff = input.PercentOff
+	} else {
+		promo.AmountOff = ToCents(input.AmountOff)
+	}
+	if err := database.DB.Create(&promo).Error; err != nil {
+		return nil, fmt.Errorf("enregistrement du code promo : %w", err)
+	}
+	return &promo, nil
+}
+
+// DeactivatePromoCode désactive un code promo et supprime son coupon Stripe.
+// Les abonnements qui en ont déjà bénéficié ne sont pas modifiés.
+func DeactivatePromoCode(creator Creator, promo *PromoCode) error {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+
+	if err := database.DB.Model(promo).Update("active", false).Error; err != nil {
+		return fmt.Errorf("désactivation du code promo : %w", err)
+	}
+
+	params := &stripe.CouponParams{}
+	params.SetStripeAccount(creator.StripeAccountID)
+	if _, err := coupon.Del(promo.StripeCouponID, params); err != nil {
+		logs.LogJSON("WARN", "Error deleting Stripe coupon", map[string]interface{}{
+			"error":    err.Error(),
+			"userID":   creator.ID,
+			"couponID": promo.StripeCouponID,
+		})
+	}
+	return nil
+}
+
+// ValidatePromoCode vérifie qu'un code promo du créateur est utilisable par cet abonné
+func ValidatePromoCode(creatorID, code, subscriberID string) (*PromoCode, error) {
+	var promo PromoCode
+	if err := database.DB.
+		Where("creator_id = ? AND code = ? AND active = true", creatorID, NormalizePromoCode(code)).
+		First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromoCodeInvalid
+		}
+		return nil, fmt.Errorf("récupération du code promo : %w", err)
+	}
+
+	if promo.ExpiresAt != nil && !promo.ExpiresAt.After(time.Now()) {
+		return nil, ErrPromoCodeExpired
+	}
+	if promo.MaxRedemptions != nil && promo.RedemptionCount >= *promo.MaxRedemptions {
+		return nil, ErrPromoCodeExhausted
+	}
+
+	var used int64
+	if err := database.DB.Model(&PromoRedemption{}).
+		Where("promo_code_id = ? AND subscriber_id = ?", promo.ID, subscriberID).
+		Count(&used).Error; err != nil {
+		return nil, fmt.Errorf("vérification des utilisations : %w", err)
+	}
+	if used > 0 {
+		return nil, ErrPromoCodeAlreadyUsed
+	}
+
+	return &promo, nil
+}
+
+// RecordRedemption enregistre l'utilisation d'un code promo à la confirmation du paiement.
+// Une même session Checkout n'est comptée qu'une fois.
+func RecordRedemption(promoCodeID, creatorID, subscriberID, stripeSessionID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		redemption := PromoRedemption{
+			CreatedAt:       time.Now(),
+			PromoCodeID:     promoCodeID,
+			CreatorID:       creatorID,
+			SubscriberID:    subscriberID,
+			StripeSessionID: stripeSessionID,
+		}
+		result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "stripe_session_id"}}, DoNothing: true}).
+			Create(&redemption)
+		if result.Error != nil {
+			return fmt.Errorf("enregistrement de l'utilisation du code promo : %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return tx.Model(&PromoCode{}).
+			Where("id = ?", promoCodeID).
+			Update("redemption_count", gorm.Expr("redemption_count + 1")).Error
+	})
+}
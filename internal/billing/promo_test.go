@@ -0,0 +1,110 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+var promoRows = []string{"id", "creator_id", "code", "discount_type", "percent_off", "expires_at", "max_redemptions", "redemption_count", "active", "stripe_coupon_id"}
+
+func TestValidatePromoCode(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+	ten, two := 10, 2
+
+	tests := []struct {
+		name           string
+		expiresAt      *time.Time
+		maxRedemptions *int
+		count          int
+		used           int
+		expectedErr    error
+	}{
+		{name: "Code valide", expiresAt: &future, maxRedemptions: &ten, count: 3},
+		{name: "Code expiré", expiresAt: &past, expectedErr: ErrPromoCodeExpired},
+		{name: "Code épuisé", maxRedemptions: &two, count: 2, expectedErr: ErrPromoCodeExhausted},
+		{name: "Code déjà utilisé par l'abonné", used: 1, expectedErr: ErrPromoCodeAlreadyUsed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := testutil.SetupMockDB(t)
+
+			mock.ExpectQuery(`SELECT \* FROM "promo_codes" WHERE creator_id = \$1 AND code = \$2 AND active = true`).
+				WithArgs("creator1", "BIENVENUE", 1).
+				WillReturnRows(sqlmock.NewRows(promoRows).
+					AddRow("promo1", "creator1", "BIENVENUE", DiscountPercent, 20.0, tt.expiresAt, tt.maxRedemptions, tt.count, true, "coupon_1"))
+			if tt.expectedErr != ErrPromoCodeExpired && tt.expectedErr != ErrPromoCodeExhausted {
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "promo_redemptions"`).
+					WithArgs("promo1", "sub1").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.used))
+			}
+
+			// Le code saisi est normalisé avant la recherche
+			promo, err := ValidatePromoCode("creator1", " bienvenue ", "sub1")
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "coupon_1", promo.StripeCouponID)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("Code inconnu", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "promo_codes"`).
+			WillReturnRows(sqlmock.NewRows(promoRows))
+
+		_, err := ValidatePromoCode("creator1", "INCONNU", "sub1")
+
+		assert.ErrorIs(t, err, ErrPromoCodeInvalid)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRecordRedemption(t *testing.T) {
+	t.Run("Première utilisation : compteur incrémenté", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "promo_redemptions" .* ON CONFLICT \("stripe_session_id"\) DO NOTHING`).
+			WithArgs(sqlmock.AnyArg(), "promo1", "creator1", "sub1", "cs_1").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("red1"))
+		mock.ExpectExec(`UPDATE "promo_codes" SET "redemption_count"=redemption_count \+ 1 WHERE id = \$1`).
+			WithArgs("promo1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, RecordRedemption("promo1", "creator1", "sub1", "cs_1"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Session déjà comptée : compteur inchangé", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "promo_redemptions"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
+
+		assert.NoError(t, RecordRedemption("promo1", "creator1", "sub1", "cs_1"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestValidatePromoCodeInput(t *testing.T) {
+	assert.Empty(t, ValidatePromoCodeInput(PromoCodeInput{Code: "ete-2026", DiscountType: DiscountPercent, PercentOff: 50}))
+	assert.Empty(t, ValidatePromoCodeInput(PromoCodeInput{Code: "MOINS2", DiscountType: DiscountFixed, AmountOff: 2}))
+	assert.NotEmpty(t, ValidatePromoCodeInput(PromoCodeInput{Code: "A", DiscountType: DiscountPercent, PercentOff: 10}))
+	assert.NotEmpty(t, ValidatePromoCodeInput(PromoCodeInput{Code: "TROPFORT", DiscountType: DiscountPercent, PercentOff: 150}))
+	assert.NotEmpty(t, ValidatePromoCodeInput(PromoCodeInput{Code: "GRATUIT", DiscountType: "free"}))
+}
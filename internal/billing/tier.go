@@ -0,0 +1,218 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+)
+
+// MaxTiers est le nombre maximum de paliers actifs par créateur
+const MaxTiers = 5
+
+// MinTierPrice est le prix minimum d'un palier, en euros
+const MinTierPrice = 1.0
+
+// ErrTierNotFound est retournée pour un palier inexistant, archivé ou appartenant à un autre créateur
+var ErrTierNotFound = errors.New("palier introuvable")
+
+// ErrTooManyTiers est retournée quand le créateur a déjà MaxTiers paliers actifs
+var ErrTooManyTiers = errors.New("nombre maximum de paliers atteint")
+
+// ActiveTiers retourne les paliers actifs d'un créateur, du rang le plus bas au plus élevé
+func ActiveTiers(creatorID string) ([]Tier, error) {
+	var tiers []Tier
+	err := database.DB.Where("creator_id = ? AND active = true", creatorID).Order("rank ASC").Find(&tiers).Error
+	return tiers, err
+}
+
+// FindActiveTier retourne un palier actif du créateur
+func FindActiveTier(creatorID, tierID string) (*Tier, error) {
+	var tier Tier
+	if err := database.DB.First(&tier, "id = ? AND creator_id = ? AND active = true", tierID, creatorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTierNotFound
+		}
+		return nil, fmt.Errorf("récupération du palier : %w", err)
+	}
+	return &tier, nil
+}
+
+// FindTier retourne un palier du créateur, même archivé
+func FindTier(creatorID, tierID string) (*Tier, error) {
+	var tier Tier
+	if err := database.DB.First(&tier, "id = ? AND creator_id = ?", tierID, creatorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTierNotFound
+		}
+		return nil, fmt.Errorf("récupération du palier : %w", err)
+	}
+	return &tier, nil
+}
+
+// CreateTier ajoute un palier au créateur avec son tarif Stripe, classé parmi les paliers existants selon son prix
+func CreateTier(creator Creator, input TierInput) (*Tier, error) {
+	var activeCount int64
+	if err := database.DB.Model(&Tier{}).Where("creator_id = ? AND active = true", creator.ID).Count(&activeCount).Error; err != nil {
+		return nil, fmt.Errorf("comptage des paliers : %w", err)
+	}
+	if activeCount >= MaxTiers {
+		return nil, ErrTooManyTiers
+	}
+
+	// Rang provisoire au-dessus de tous les paliers, archivés compris, avant le classement par prix
+	var maxRank int
+	if err := database.DB.Model(&Tier{}).Where("creator_id = ?", creator.ID).
+		Select("COALESCE(MAX(rank), 0)").Scan(&maxRank).Error; err != nil {
+		return nil, fmt.Errorf("récupération du rang : %w", err)
+	}
+
+	createdPrice, err := newStripePrice(creator, input.Price, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	tier := Tier{
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		CreatorID:     creator.ID,
+		Name:          input.Name,
+		Description:   input.Description,
+		Rank:          maxRank + 1,
+		Price:         input.Price,
+		StripePriceID: createdPrice.ID,
+		Active:        true,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tier).Error; err != nil {
+			return fmt.Errorf("enregistrement du palier : %w", err)
+		}
+		ranks, err := rankTiers(tx, creator.ID)
+		if err != nil {
+			return err
+		}
+		tier.Rank = ranks[tier.ID]
+		return nil
+	})
+	if err != nil {
+		archiveStripePrice(creator, createdPrice.ID)
+		return nil, err
+	}
+	return &tier, nil
+}
+
+// UpdateTier modifie un palier. Un nouveau prix crée un nouveau tarif Stripe ;
+// les abonnés actuels du palier conservent leur tarif.
+func UpdateTier(creator Creator, tier *Tier, input TierInput) error {
+	updates := map[string]interface{}{
+		"name":        input.Name,
+		"description": input.Description,
+		"updated_at":  time.Now(),
+	}
+
+	previousPriceID := ""
+	if ToCents(input.Price) != ToCents(tier.Price) {
+		createdPrice, err := newStripePrice(creator, input.Price, 1)
+		if err != nil {
+			return err
+		}
+		previousPriceID = tier.StripePriceID
+		updates["price"] = input.Price
+		updates["stripe_price_id"] = createdPrice.ID
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(tier).Updates(updates).Error; err != nil {
+			return fmt.Errorf("mise à jour du palier : %w", err)
+		}
+		if previousPriceID == "" {
+			return nil
+		}
+		// Un nouveau prix peut déplacer le palier parmi les autres
+		ranks, err := rankTiers(tx, creator.ID)
+		if err != nil {
+			return err
+		}
+		tier.Rank = ranks[tier.ID]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if previousPriceID != "" {
+		archiveStripePrice(creator, previousPriceID)
+	}
+	return nil
+}
+
+// ArchiveTier retire un palier des nouvelles souscriptions. Ses abonnés conservent leur accès.
+func ArchiveTier(creator Creator, tier *Tier) error {
+	now := time.Now()
+	if err := database.DB.Model(tier).Updates(map[string]interface{}{
+		"active":      false,
+		"archived_at": now,
+		"updated_at":  now,
+	}).Error; err != nil {
+		return fmt.Errorf("archivage du palier : %w", err)
+	}
+	archiveStripePrice(creator, tier.StripePriceID)
+	return nil
+}
+
+// rankTiers classe les paliers du créateur par prix croissant, paliers archivés compris, et reporte
+// les rangs modifiés sur les abonnements du palier et sur les posts qui exigeaient l'ancien rang.
+// Retourne le rang de chaque palier.
+func rankTiers(tx *gorm.DB, creatorID string) (map[string]int, error) {
+	var tiers []Tier
+	if err := tx.Where("creator_id = ?", creatorID).Order("price ASC, created_at ASC").Find(&tiers).Error; err != nil {
+		return nil, fmt.Errorf("récupération des paliers : %w", err)
+	}
+
+	ranks := make(map[string]int, len(tiers))
+	var moved []Tier
+	for i, tier := range tiers {
+		ranks[tier.ID] = i + 1
+		if tier.Rank != i+1 {
+			moved = append(moved, tier)
+		}
+	}
+	if len(moved) == 0 {
+		return ranks, nil
+	}
+
+	// Passage par des rangs négatifs : l'unicité de (creator_id, rank) est vérifiée ligne par ligne
+	for _, tier := range moved {
+		if err := tx.Model(&Tier{}).Where("id = ?", tier.ID).Update("rank", -ranks[tier.ID]).Error; err != nil {
+			return nil, fmt.Errorf("classement du palier %s : %w", tier.ID, err)
+		}
+	}
+	if err := tx.Model(&Tier{}).Where("creator_id = ? AND rank < 0", creatorID).
+		Update("rank", gorm.Expr("-rank")).Error; err != nil {
+		return nil, fmt.Errorf("classement des paliers : %w", err)
+	}
+
+	// Les posts exigent un rang : chaque ancien rang est remplacé par le nouveau en une seule requête
+	caseExpr := "CASE min_tier_rank"
+	var args []interface{}
+	var previous []int
+	for _, tier := range moved {
+		caseExpr += " WHEN ? THEN ?"
+		args = append(args, tier.Rank, ranks[tier.ID])
+		previous = append(previous, tier.Rank)
+
+		if err := tx.Model(&subscription.Subscription{}).Where("tier_id = ?", tier.ID).
+			Update("tier_rank", ranks[tier.ID]).Error; err != nil {
+			return nil, fmt.Errorf("rang des abonnés du palier %s : %w", tier.ID, err)
+		}
+	}
+	caseExpr += " END"
+	if err := tx.Table("posts").Where("user_id = ? AND min_tier_rank IN ?", creatorID, previous).
+		Update("min_tier_rank", gorm.Expr(caseExpr, args...)).Error; err != nil {
+		return nil, fmt.Errorf("rang minimum des posts : %w", err)
+	}
+	return ranks, nil
+}
@@ -0,0 +1,156 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+var productRows = []string{"creator_id", "stripe_account_id", "stripe_product_id"}
+
+var tierRows = []string{"id", "rank", "price"}
+
+func TestCreateTier(t *testing.T) {
+	creator := Creator{ID: "creator1", Username: "alice", StripeAccountID: "acct_1", SubscriptionPrice: 5}
+
+	t.Run("Palier ajouté au-dessus des paliers existants", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "creator_tiers"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		// Le rang suit le plus haut rang, y compris celui d'un palier archivé
+		mock.ExpectQuery(`SELECT COALESCE\(MAX\(rank\), 0\) FROM "creator_tiers"`).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+		mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+			WillReturnRows(sqlmock.NewRows(productRows).AddRow("creator1", "acct_1", "prod_1"))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "creator_tiers"`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "creator1", "VIP", "Contenus exclusifs", 3, 15.0, "price_new", true, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("tier3"))
+		// Le plus cher des paliers : aucun rang ne change
+		mock.ExpectQuery(`SELECT \* FROM "creator_tiers" WHERE creator_id = \$1 ORDER BY price ASC, created_at ASC`).
+			WithArgs("creator1").
+			WillReturnRows(sqlmock.NewRows(tierRows).
+				AddRow("tier1", 1, 5.0).
+				AddRow("tier2", 2, 10.0).
+				AddRow("tier3", 3, 15.0))
+		mock.ExpectCommit()
+
+		tier, err := CreateTier(creator, TierInput{Name: "VIP", Description: "Contenus exclusifs", Price: 15})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, tier.Rank)
+		assert.Equal(t, "price_new", tier.StripePriceID)
+		assert.Equal(t, []string{"POST /v1/prices"}, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Palier moins cher qu'un palier existant : classé par prix", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		stubStripe(t)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "creator_tiers"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(`SELECT COALESCE\(MAX\(rank\), 0\) FROM "creator_tiers"`).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+		mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+			WillReturnRows(sqlmock.NewRows(productRows).AddRow("creator1", "acct_1", "prod_1"))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "creator_tiers"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("tier3"))
+		mock.ExpectQuery(`SELECT \* FROM "creator_tiers" WHERE creator_id = \$1 ORDER BY price ASC, created_at ASC`).
+			WillReturnRows(sqlmock.NewRows(tierRows).
+				AddRow("tier1", 1, 10.0).
+				AddRow("tier3", 3, 15.0).
+				AddRow("tier2", 2, 20.0))
+		mock.ExpectExec(`UPDATE "creator_tiers" SET "rank"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+			WithArgs(-2, sqlmock.AnyArg(), "tier3").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE "creator_tiers" SET "rank"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+			WithArgs(-3, sqlmock.AnyArg(), "tier2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE "creator_tiers" SET "rank"=-rank,"updated_at"=\$1 WHERE creator_id = \$2 AND rank < 0`).
+			WithArgs(sqlmock.AnyArg(), "creator1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`UPDATE "subscriptions" SET "tier_rank"=\$1 WHERE tier_id = \$2`).
+			WithArgs(2, "tier3").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		// Les abonnés du palier le plus cher gardent l'accès à ses posts
+		mock.ExpectExec(`UPDATE "subscriptions" SET "tier_rank"=\$1 WHERE tier_id = \$2`).
+			WithArgs(3, "tier2").
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec(`UPDATE "posts" SET "min_tier_rank"=CASE min_tier_rank WHEN \$1 THEN \$2 WHEN \$3 THEN \$4 END WHERE user_id = \$5 AND min_tier_rank IN \(\$6,\$7\)`).
+			WithArgs(3, 2, 2, 3, "creator1", 3, 2).
+			WillReturnResult(sqlmock.NewResult(0, 6))
+		mock.ExpectCommit()
+
+		tier, err := CreateTier(creator, TierInput{Name: "Silver", Price: 15})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, tier.Rank)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nombre maximum de paliers atteint", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "creator_tiers"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(MaxTiers))
+
+		_, err := CreateTier(creator, TierInput{Name: "Ultimate", Price: 30})
+
+		assert.ErrorIs(t, err, ErrTooManyTiers)
+		assert.Empty(t, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateTier(t *testing.T) {
+	creator := Creator{ID: "creator1", Username: "alice", StripeAccountID: "acct_1", SubscriptionPrice: 5}
+
+	t.Run("Nouveau prix : nouveau tarif Stripe et ancien archivé", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+		tier := &Tier{ID: "tier1", CreatorID: "creator1", Name: "VIP", Rank: 1, Price: 10, StripePriceID: "price_old", Active: true}
+
+		mock.ExpectQuery(`SELECT \* FROM "creator_products"`).
+			WillReturnRows(sqlmock.NewRows(productRows).AddRow("creator1", "acct_1", "prod_1"))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "creator_tiers" SET "description"=\$1,"name"=\$2,"price"=\$3,"stripe_price_id"=\$4,"updated_at"=\$5`).
+			WithArgs("", "VIP+", 12.0, "price_new", sqlmock.AnyArg(), "tier1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT \* FROM "creator_tiers" WHERE creator_id = \$1 ORDER BY price ASC, created_at ASC`).
+			WillReturnRows(sqlmock.NewRows(tierRows).AddRow("tier1", 1, 12.0).AddRow("tier2", 2, 20.0))
+		mock.ExpectCommit()
+
+		err := UpdateTier(creator, tier, TierInput{Name: "VIP+", Price: 12})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 12.0, tier.Price)
+		assert.Equal(t, []string{"POST /v1/prices", "POST /v1/prices/price_old"}, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Même prix : aucun appel Stripe", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := stubStripe(t)
+		tier := &Tier{ID: "tier1", CreatorID: "creator1", Name: "VIP", Rank: 1, Price: 10, StripePriceID: "price_old", Active: true}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "creator_tiers" SET "description"=\$1,"name"=\$2,"updated_at"=\$3`).
+			WithArgs("Tout le contenu", "VIP", sqlmock.AnyArg(), "tier1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := UpdateTier(creator, tier, TierInput{Name: "VIP", Description: "Tout le contenu", Price: 10})
+
+		assert.NoError(t, err)
+		assert.Empty(t, calls())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,260 @@
+package earnings
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/balance"
+	"github.com/stripe/stripe-go/v78/payout"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// payoutsLimit est le nombre de virements Stripe renvoyés
+const payoutsLimit = 10
+
+// GetEarnings GET /api/creator/earnings
+// Revenus, commission, MRR, churn et abonnés du créateur regroupés par jour, semaine ou mois
+func GetEarnings(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	if _, ok := requireCreator(c, userID); !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", IntervalDay)
+	if !IsValidInterval(interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Intervalle non supporté"})
+		logs.LogJSON("WARN", "Invalid earnings interval", map[string]interface{}{
+			"route":    route,
+			"userID":   userID,
+			"interval": interval,
+		})
+		return
+	}
+
+	startDate, endDate := parseDateRange(c)
+	rangeStart := truncate(startDate, interval)
+	rangeEnd := next(truncate(endDate, interval), interval)
+
+	// Paiements agrégés par période
+	var totals []paymentTotals
+	if err := database.DB.Model(&Payment{}).
+		Select("date_trunc(?, paid_at AT TIME ZONE 'UTC') AS bucket, SUM(amount) AS gross, SUM(platform_fee) AS platform_fee, COUNT(*) AS payments", interval).
+		Where("creator_id = ? AND paid_at >= ? AND paid_at < ?", userID, rangeStart, rangeEnd).
+		Group("bucket").
+		Scan(&totals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des revenus"})
+		logs.LogJSON("ERROR", "Error retrieving earnings", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	// Périodes d'abonnement enregistrées pour les abonnés, le MRR et le churn : contrairement aux
+	// abonnements eux-mêmes, elles conservent les résiliations suivies d'une réactivation
+	var periods []subscriptionPeriod
+	if err := database.DB.Model(&subscription.Period{}).
+		Select("started_at, ended_at, billed_from, monthly_price, continued, replaced").
+		Where("creator_id = ? AND started_at < ?", userID, rangeEnd).
+		Scan(&periods).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des abonnements"})
+		logs.LogJSON("ERROR", "Error retrieving subscriptions", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	series := buildSeries(startDate, endDate, interval, totals, periods)
+
+	// État actuel des abonnements, les formules groupées comptant pour leur prix mensuel.
+	// Un abonné en essai gratuit n'a encore rien payé et ne compte pas dans le MRR.
+	var current struct {
+		Count int64
+		MRR   float64
+	}
+	if err := database.DB.Model(&subscription.Subscription{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(price / GREATEST(interval_months, 1)) FILTER (WHERE status <> ?), 0) AS mrr", subscription.StatusTrialing).
+		Where("creator_id = ? AND status IN ?", userID, subscription.AccessStatuses).
+		Scan(&current).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des abonnements"})
+		logs.LogJSON("ERROR", "Error retrieving active subscriptions", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary":  summarize(series, periods, rangeStart, current.Count, current.MRR),
+		"data":     series,
+		"interval": interval,
+	})
+	logs.LogJSON("INFO", "Earnings retrieved successfully", map[string]interface{}{
+		"route":     route,
+		"userID":    userID,
+		"interval":  interval,
+		"startDate": startDate.Format("2006-01-02"),
+		"endDate":   endDate.Format("2006-01-02"),
+	})
+}
+
+// GetPayouts GET /api/creator/earnings/payouts
+// Solde et derniers virements du compte Stripe Connect du créateur
+func GetPayouts(c *gin.Context) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	creator, ok := requireCreator(c, userID)
+	if !ok {
+		return
+	}
+	if creator.StripeAccountID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le créateur n'a pas de compte Stripe"})
+		logs.LogJSON("WARN", "Creator without Stripe account", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	balanceParams := &stripe.BalanceParams{}
+	balanceParams.SetStripeAccount(creator.StripeAccountID)
+	accountBalance, err := balance.Get(balanceParams)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Erreur lors de la récupération du solde Stripe"})
+		logs.LogJSON("ERROR", "Error retrieving Stripe balance", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	payoutParams := &stripe.PayoutListParams{}
+	payoutParams.Limit = stripe.Int64(payoutsLimit)
+	payoutParams.SetStripeAccount(creator.StripeAccountID)
+	payoutParams.Single = true
+
+	payouts := make([]gin.H, 0, payoutsLimit)
+	iter := payout.List(payoutParams)
+	for iter.Next() {
+		p := iter.Payout()
+		payouts = append(payouts, gin.H{
+			"id":           p.ID,
+			"amount":       toEuros(p.Amount),
+			"currency":     p.Currency,
+			"status":       p.Status,
+			"arrival_date": time.Unix(p.ArrivalDate, 0).UTC(),
+			"created_at":   time.Unix(p.Created, 0).UTC(),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Erreur lors de la récupération des virements Stripe"})
+		logs.LogJSON("ERROR", "Error retrieving Stripe payouts", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balance": gin.H{
+			"available": balanceAmounts(accountBalance.Available),
+			"pending":   balanceAmounts(accountBalance.Pending),
+		},
+		"payouts": payouts,
+	})
+	logs.LogJSON("INFO", "Payouts retrieved successfully", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+	})
+}
+
+// requireCreator charge l'utilisateur et vérifie qu'il est créateur, et répond à sa place sinon
+func requireCreator(c *gin.Context, userID string) (user.User, bool) {
+	route := c.FullPath()
+
+	var creator user.User
+	if err := database.DB.First(&creator, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Utilisateur non trouvé"})
+		logs.LogJSON("WARN", "User not found", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return creator, false
+	}
+	if !creator.IsCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Réservé aux créateurs"})
+		logs.LogJSON("WARN", "User is not a creator", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return creator, false
+	}
+	return creator, true
+}
+
+// parseDateRange lit start_date et end_date (30 derniers jours par défaut), comme admin.GetChartData
+func parseDateRange(c *gin.Context) (time.Time, time.Time) {
+	startDate, err := time.Parse("2006-01-02", c.Query("start_date"))
+	if err != nil {
+		startDate = time.Now().AddDate(0, 0, -30)
+	}
+	endDate, err := time.Parse("2006-01-02", c.Query("end_date"))
+	if err != nil {
+		endDate = time.Now()
+	}
+	return startDate, endDate
+}
+
+// summarize calcule les totaux de la période et le churn depuis son début
+func summarize(series []Bucket, periods []subscriptionPeriod, rangeStart time.Time, activeSubscribers int64, mrr float64) Summary {
+	summary := Summary{
+		MRR:               roundCents(mrr),
+		ActiveSubscribers: activeSubscribers,
+	}
+	for _, b := range series {
+		summary.Gross += b.Gross
+		summary.PlatformFee += b.PlatformFee
+		summary.Cancellations += b.Cancellations
+	}
+	summary.Gross = roundCents(summary.Gross)
+	summary.PlatformFee = roundCents(summary.PlatformFee)
+	summary.Net = roundCents(summary.Gross - summary.PlatformFee)
+
+	var subscribersAtStart int64
+	for _, p := range periods {
+		if p.activeAt(rangeStart) {
+			subscribersAtStart++
+		}
+	}
+	summary.Churn = churn(summary.Cancellations, subscribersAtStart)
+
+	return summary
+}
+
+func balanceAmounts(amounts []*stripe.Amount) []gin.H {
+	result := make([]gin.H, 0, len(amounts))
+	for _, a := range amounts {
+		result = append(result, gin.H{
+			"amount":   toEuros(a.Amount),
+			"currency": a.Currency,
+		})
+	}
+	return result
+}
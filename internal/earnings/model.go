@@ -0,0 +1,51 @@
+package earnings
+
+import "time"
+
+// Types de paiements reçus par un créateur
+const (
+	KindSubscription  = "subscription"
+	KindPostUnlock    = "post_unlock"
+	KindTip           = "tip"
+	KindMessageUnlock = "message_unlock"
+	KindGift          = "gift"
+)
+
+// Payment est un paiement encaissé pour un créateur, enregistré à partir des événements Stripe
+type Payment struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt      time.Time `json:"created_at"`
+	StripeObjectID string    `json:"stripe_object_id" gorm:"uniqueIndex"` // facture ou paiement Stripe
+	Kind           string    `json:"kind"`
+	CreatorID      string    `json:"creator_id" gorm:"index"`
+	PayerID        string    `json:"payer_id"`
+	Amount         int64     `json:"amount"`       // montant brut en centimes
+	PlatformFee    int64     `json:"platform_fee"` // commission de la plateforme en centimes
+	Currency       string    `json:"currency"`
+	PaidAt         time.Time `json:"paid_at"`
+}
+
+// Bucket regroupe les revenus et abonnés d'un créateur sur une période
+type Bucket struct {
+	Date           string  `json:"date"`
+	Gross          float64 `json:"gross"`
+	PlatformFee    float64 `json:"platform_fee"`
+	Net            float64 `json:"net"`
+	Payments       int64   `json:"payments"`
+	Subscribers    int64   `json:"subscribers"`
+	NewSubscribers int64   `json:"new_subscribers"`
+	Cancellations  int64   `json:"cancellations"`
+	Churn          float64 `json:"churn"` // part des abonnés du début de période partis pendant la période
+	MRR            float64 `json:"mrr"`
+}
+
+// Summary résume les revenus d'un créateur sur la période demandée
+type Summary struct {
+	Gross             float64 `json:"gross"`
+	PlatformFee       float64 `json:"platform_fee"`
+	Net               float64 `json:"net"`
+	MRR               float64 `json:"mrr"`
+	ActiveSubscribers int64   `json:"active_subscribers"`
+	Cancellations     int64   `json:"cancellations"`
+	Churn             float64 `json:"churn"`
+}
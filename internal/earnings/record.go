@@ -0,0 +1,25 @@
+package earnings
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+)
+
+// Record enregistre un paiement. Un même objet Stripe n'est compté qu'une fois.
+func Record(payment Payment) error {
+	return RecordTx(database.DB, payment)
+}
+
+// RecordTx enregistre un paiement dans une transaction en cours, avec le reste de sa confirmation
+func RecordTx(tx *gorm.DB, payment Payment) error {
+	if err := tx.
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "stripe_object_id"}}, DoNothing: true}).
+		Create(&payment).Error; err != nil {
+		return fmt.Errorf("enregistrement du paiement %s : %w", payment.StripeObjectID, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package earnings
+
+import (
+	"time"
+)
+
+// Intervalles de regroupement
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// maxBuckets limite la taille des séries renvoyées
+const maxBuckets = 400
+
+// IsValidInterval indique si l'intervalle de regroupement est supporté
+func IsValidInterval(interval string) bool {
+	return interval == IntervalDay || interval == IntervalWeek || interval == IntervalMonth
+}
+
+// truncate ramène une date au début de sa période, comme date_trunc côté Postgres
+func truncate(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalWeek:
+		// Les semaines commencent le lundi
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// next retourne le début de la période suivante
+func next(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// subscriptionPeriod est une période enregistrée pendant laquelle un abonné a été abonné
+type subscriptionPeriod struct {
+	StartedAt    time.Time
+	EndedAt      *time.Time
+	BilledFrom   time.Time
+	MonthlyPrice float64
+	Continued    bool // suite d'une période précédente : pas un nouvel abonné
+	Replaced     bool // remplacée par la période suivante : pas une résiliation
+}
+
+func (s subscriptionPeriod) activeAt(t time.Time) bool {
+	return s.StartedAt.Before(t) && (s.EndedAt == nil || !s.EndedAt.Before(t))
+}
+
+// billedAt indique si la période compte dans le MRR : un essai gratuit n'est pas facturé
+func (s subscriptionPeriod) billedAt(t time.Time) bool {
+	return s.activeAt(t) && s.BilledFrom.Before(t)
+}
+
+// paymentTotals sont les paiements agrégés d'une période, en centimes
+type paymentTotals struct {
+	Bucket      time.Time
+	Gross       int64
+	PlatformFee int64
+	Payments    int64
+}
+
+// buildSeries calcule la série des revenus et abonnés entre start et end
+func buildSeries(start, end time.Time, interval string, payments []paymentTotals, subs []subscriptionPeriod) []Bucket {
+	byBucket := make(map[string]paymentTotals, len(payments))
+	for _, p := range payments {
+		byBucket[truncate(p.Bucket, interval).Format("2006-01-02")] = p
+	}
+
+	var series []Bucket
+	for b := truncate(start, interval); !b.After(end) && len(series) < maxBuckets; b = next(b, interval) {
+		bucketEnd := next(b, interval)
+		key := b.Format("2006-01-02")
+		totals := byBucket[key]
+
+		bucket := Bucket{
+			Date:        key,
+			Gross:       toEuros(totals.Gross),
+			PlatformFee: toEuros(totals.PlatformFee),
+			Net:         toEuros(totals.Gross - totals.PlatformFee),
+			Payments:    totals.Payments,
+		}
+
+		var subscribersAtStart int64
+		for _, s := range subs {
+			if s.activeAt(b) {
+				subscribersAtStart++
+			}
+			if s.activeAt(bucketEnd) {
+				bucket.Subscribers++
+			}
+			if s.billedAt(bucketEnd) {
+				bucket.MRR += s.MonthlyPrice
+			}
+			if !s.Continued && !s.StartedAt.Before(b) && s.StartedAt.Before(bucketEnd) {
+				bucket.NewSubscribers++
+			}
+			if !s.Replaced && s.EndedAt != nil && !s.EndedAt.Before(b) && s.EndedAt.Before(bucketEnd) {
+				bucket.Cancellations++
+			}
+		}
+		bucket.Churn = churn(bucket.Cancellations, subscribersAtStart)
+		bucket.MRR = roundCents(bucket.MRR)
+
+		series = append(series, bucket)
+	}
+
+	return series
+}
+
+func churn(cancellations, subscribersAtStart int64) float64 {
+	if subscribersAtStart == 0 {
+		return 0
+	}
+	return float64(cancellations) / float64(subscribersAtStart)
+}
+
+func toEuros(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+func roundCents(amount float64) float64 {
+	return float64(int64(amount*100+0.5)) / 100
+}
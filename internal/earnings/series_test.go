@@ -0,0 +1,102 @@
+package earnings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		date     time.Time
+		interval string
+		expected string
+	}{
+		{"Jour", time.Date(2024, 5, 15, 18, 30, 0, 0, time.UTC), IntervalDay, "2024-05-15"},
+		{"Semaine commençant le lundi", date("2024-05-15"), IntervalWeek, "2024-05-13"},
+		{"Dimanche rattaché à la semaine précédente", date("2024-05-19"), IntervalWeek, "2024-05-13"},
+		{"Mois", date("2024-05-15"), IntervalMonth, "2024-05-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, truncate(tt.date, tt.interval).Format("2006-01-02"))
+		})
+	}
+}
+
+func TestBuildSeries(t *testing.T) {
+	cancelledAt := date("2024-02-10")
+	resubscribedAt := date("2024-03-10")
+	giftEndedAt := date("2024-03-15")
+	trialEndsAt := date("2024-04-05")
+	subs := []subscriptionPeriod{
+		{StartedAt: date("2023-12-01"), BilledFrom: date("2023-12-01"), MonthlyPrice: 5},
+		// fan2 résilie en février puis se réabonne en mars : les deux périodes restent distinctes
+		{StartedAt: date("2024-01-15"), BilledFrom: date("2024-01-15"), EndedAt: &cancelledAt, MonthlyPrice: 10},
+		{StartedAt: resubscribedAt, BilledFrom: resubscribedAt, MonthlyPrice: 10},
+		{StartedAt: date("2024-02-20"), BilledFrom: date("2024-02-20"), MonthlyPrice: 7.5},
+		// Formule annuelle offerte puis devenue payante : ni résiliation ni nouvel abonné au changement
+		{StartedAt: date("2024-03-05"), BilledFrom: date("2024-03-05"), EndedAt: &giftEndedAt, MonthlyPrice: 50.0 / 12, Replaced: true},
+		{StartedAt: giftEndedAt, BilledFrom: giftEndedAt, MonthlyPrice: 50.0 / 12, Continued: true},
+		// Essai gratuit en cours : abonné, mais pas encore dans le MRR
+		{StartedAt: date("2024-03-29"), BilledFrom: trialEndsAt, MonthlyPrice: 20},
+	}
+	payments := []paymentTotals{
+		{Bucket: date("2024-01-01"), Gross: 1500, PlatformFee: 300, Payments: 2},
+		{Bucket: date("2024-02-01"), Gross: 1250, PlatformFee: 250, Payments: 2},
+	}
+
+	series := buildSeries(date("2024-01-01"), date("2024-03-31"), IntervalMonth, payments, subs)
+
+	assert.Len(t, series, 3)
+
+	// Janvier : un abonné existant, un nouveau
+	assert.Equal(t, "2024-01-01", series[0].Date)
+	assert.Equal(t, 15.0, series[0].Gross)
+	assert.Equal(t, 12.0, series[0].Net)
+	assert.Equal(t, int64(2), series[0].Subscribers)
+	assert.Equal(t, int64(1), series[0].NewSubscribers)
+	assert.Equal(t, 15.0, series[0].MRR)
+	assert.Equal(t, 0.0, series[0].Churn)
+
+	// Février : un départ sur deux abonnés en début de mois, une arrivée
+	assert.Equal(t, int64(2), series[1].Subscribers)
+	assert.Equal(t, int64(1), series[1].Cancellations)
+	assert.Equal(t, 0.5, series[1].Churn)
+	assert.Equal(t, 12.5, series[1].MRR)
+
+	// Mars : aucun paiement, un retour, une formule annuelle comptée pour un douzième et un essai non facturé
+	assert.Equal(t, 0.0, series[2].Gross)
+	assert.Equal(t, int64(5), series[2].Subscribers)
+	assert.Equal(t, int64(3), series[2].NewSubscribers)
+	assert.Equal(t, int64(0), series[2].Cancellations)
+	assert.Equal(t, 26.67, series[2].MRR)
+}
+
+func TestSummarize(t *testing.T) {
+	cancelledAt := date("2024-02-10")
+	periods := []subscriptionPeriod{
+		{StartedAt: date("2023-12-01"), BilledFrom: date("2023-12-01"), MonthlyPrice: 5},
+		{StartedAt: date("2023-12-15"), BilledFrom: date("2023-12-15"), EndedAt: &cancelledAt, MonthlyPrice: 10},
+	}
+	series := []Bucket{
+		{Gross: 15, PlatformFee: 3},
+		{Gross: 5, PlatformFee: 1, Cancellations: 1},
+	}
+
+	summary := summarize(series, periods, date("2024-01-01"), 1, 5)
+
+	assert.Equal(t, 20.0, summary.Gross)
+	assert.Equal(t, 4.0, summary.PlatformFee)
+	assert.Equal(t, 16.0, summary.Net)
+	assert.Equal(t, 0.5, summary.Churn)
+	assert.Equal(t, int64(1), summary.ActiveSubscribers)
+}
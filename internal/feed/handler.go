@@ -0,0 +1,196 @@
+package feed
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/aggregate"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/post"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/unlock"
+)
+
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 50
+	// Nombre de posts récents parmi lesquels le classement par engagement choisit
+	engagementCandidateWindow = 200
+)
+
+// GetFeed GET /api/feed
+func GetFeed(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	rankingName, ranker, ok := GetRanker(c.Query("ranking"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stratégie de classement non supportée"})
+		logs.LogJSON("WARN", "Unsupported feed ranking", map[string]interface{}{
+			"route":   route,
+			"userID":  userID,
+			"ranking": rankingName,
+		})
+		return
+	}
+
+	page, err := pagination.FromRequest(c, defaultFeedLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+	if page.Limit > maxFeedLimit {
+		page.Limit = maxFeedLimit
+	}
+
+	// Créateurs auxquels l'utilisateur est activement abonné, avec le rang de son palier
+	var activeSubscriptions []struct {
+		CreatorID string
+		TierRank  int
+	}
+	if err := database.DB.Table("subscriptions").
+		Select("creator_id, tier_rank").
+		Scopes(subscription.ActiveFor(userID)).
+		Scan(&activeSubscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des abonnements"})
+		logs.LogJSON("ERROR", "Error retrieving subscriptions", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	tierRanks := make(map[string]int, len(activeSubscriptions))
+	for _, sub := range activeSubscriptions {
+		tierRanks[sub.CreatorID] = sub.TierRank
+	}
+
+	// Posts des créateurs suivis et des créateurs auxquels l'utilisateur est abonné
+	query := database.DB.Table("posts").
+		Select(`posts.id, posts.created_at, posts.published_at, posts.user_id, posts.title, posts.description,
+		        posts.media_url, posts.is_paid, posts.unlock_price, posts.min_tier_rank,
+		        users.username, users.avatar_url, users.is_creator`).
+		Joins("LEFT JOIN users ON posts.user_id = users.id").
+		Where("posts.user_id IN (?) OR posts.user_id IN (?)",
+			database.DB.Table("follows").Select("creator_id").Where("follower_id = ?", userID),
+			database.DB.Table("subscriptions").Select("creator_id").Scopes(subscription.ActiveFor(userID))).
+		Scopes(access.PublishedPosts(""))
+
+	// Le classement chronologique se pagine par curseur. Le classement par engagement ne renvoie
+	// que les meilleurs posts parmi les plus récents : son ordre dépend de l'instant de la requête,
+	// il n'a donc pas de page suivante et la réponse l'indique par "paginated": false
+	if rankingName == RankingChronological {
+		query = query.Scopes(page.Scope("posts.published_at", "posts.id"))
+	} else {
+		query = query.Order("posts.published_at DESC").Limit(engagementCandidateWindow)
+	}
+
+	var items []FeedItem
+	if err := query.Scan(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du fil d'actualité"})
+		logs.LogJSON("ERROR", "Error retrieving feed", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	envelope := pagination.Envelope{Limit: page.Limit}
+	if rankingName == RankingChronological {
+		items, envelope = pagination.Paginate(page, items, func(item FeedItem) (time.Time, string) {
+			return item.PublishedAt, item.ID
+		})
+	}
+
+	// Compteurs d'engagement de tous les posts candidats en une seule requête
+	postIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		postIDs = append(postIDs, item.ID)
+	}
+	stats, err := aggregate.PostStats(postIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du fil d'actualité"})
+		logs.LogJSON("ERROR", "Error during feed aggregation", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+	for i := range items {
+		counts := stats[items[i].ID]
+		items[i].LikeCount = counts.LikeCount
+		items[i].CommentCount = counts.CommentCount
+		items[i].IsLiked = counts.IsLiked
+	}
+
+	ranker.Rank(items, time.Now())
+	if len(items) > page.Limit {
+		items = items[:page.Limit]
+	}
+
+	// Posts payants achetés à l'unité
+	pagePostIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		pagePostIDs = append(pagePostIDs, item.ID)
+	}
+	unlocked, err := unlock.UnlockedPostIDs(userID, pagePostIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du fil d'actualité"})
+		logs.LogJSON("ERROR", "Error retrieving post unlocks", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	media, err := post.MediaByPost(pagePostIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du fil d'actualité"})
+		logs.LogJSON("ERROR", "Error retrieving post media", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	// Marquer les posts débloqués et masquer les médias des posts verrouillés, hors aperçus.
+	// Les médias visibles sont servis par des URL signées temporaires.
+	for i := range items {
+		tierRank, subscribed := tierRanks[items[i].UserID]
+		items[i].IsUnlocked = !items[i].IsPaid || items[i].UserID == userID ||
+			(subscribed && tierRank >= items[i].MinTierRank) || unlocked[items[i].ID]
+		items[i].Media = post.SignMedia(post.RedactMedia(media[items[i].ID], items[i].IsUnlocked))
+		if items[i].IsUnlocked {
+			items[i].MediaURL = storage.SignedURL(items[i].MediaURL)
+		} else {
+			items[i].MediaURL = ""
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"posts":      items,
+		"ranking":    rankingName,
+		"paginated":  rankingName == RankingChronological,
+		"pagination": envelope,
+	})
+	logs.LogJSON("INFO", "Feed retrieved successfully", map[string]interface{}{
+		"route":   route,
+		"userID":  userID,
+		"ranking": rankingName,
+	})
+}
@@ -0,0 +1,30 @@
+package feed
+
+import (
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/post"
+)
+
+// FeedItem représente un post du fil d'actualité personnalisé
+type FeedItem struct {
+	ID           string       `json:"id"`
+	CreatedAt    time.Time    `json:"created_at"`
+	PublishedAt  time.Time    `json:"published_at"`
+	UserID       string       `json:"user_id"`
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	MediaURL     string       `json:"media_url"`
+	Media        []post.Media `json:"media" gorm:"-"`
+	IsPaid       bool         `json:"is_paid"`
+	UnlockPrice  *float64     `json:"unlock_price"`
+	MinTierRank  int          `json:"min_tier_rank"`
+	Username     string       `json:"username"`
+	AvatarURL    string       `json:"avatar_url"`
+	IsCreator    bool         `json:"is_creator"`
+	LikeCount    int64        `json:"like_count"`
+	CommentCount int64        `json:"comment_count"`
+	IsLiked      bool         `json:"is_liked"`
+	IsUnlocked   bool         `json:"is_unlocked"`
+	Score        float64      `json:"-" gorm:"-"`
+}
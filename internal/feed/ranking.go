@@ -0,0 +1,73 @@
+package feed
+
+import (
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Stratégies de classement disponibles pour le fil d'actualité
+const (
+	RankingChronological = "chronological"
+	RankingEngagement    = "engagement"
+)
+
+// Ranker trie les éléments du fil selon une stratégie donnée
+type Ranker interface {
+	Rank(items []FeedItem, now time.Time)
+}
+
+// ChronologicalRanker classe les posts du plus récemment mis en ligne au plus ancien
+type ChronologicalRanker struct{}
+
+func (ChronologicalRanker) Rank(items []FeedItem, _ time.Time) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PublishedAt.After(items[j].PublishedAt)
+	})
+}
+
+// EngagementRanker pondère les likes et commentaires et les atténue avec le temps écoulé depuis la mise en ligne du post
+type EngagementRanker struct {
+	LikeWeight    float64
+	CommentWeight float64
+	Gravity       float64
+}
+
+func (r EngagementRanker) Rank(items []FeedItem, now time.Time) {
+	for i := range items {
+		items[i].Score = r.score(items[i], now)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Score == items[j].Score {
+			return items[i].PublishedAt.After(items[j].PublishedAt)
+		}
+		return items[i].Score > items[j].Score
+	})
+}
+
+func (r EngagementRanker) score(item FeedItem, now time.Time) float64 {
+	ageHours := now.Sub(item.PublishedAt).Hours()
+	if ageHours < 0 {
+		ageHours = 0
+	}
+	engagement := 1 + float64(item.LikeCount)*r.LikeWeight + float64(item.CommentCount)*r.CommentWeight
+	return engagement / math.Pow(ageHours+2, r.Gravity)
+}
+
+var rankers = map[string]Ranker{
+	RankingChronological: ChronologicalRanker{},
+	RankingEngagement:    EngagementRanker{LikeWeight: 1, CommentWeight: 2, Gravity: 1.5},
+}
+
+// GetRanker retourne la stratégie demandée, ou celle configurée par FEED_RANKING par défaut
+func GetRanker(name string) (string, Ranker, bool) {
+	if name == "" {
+		name = os.Getenv("FEED_RANKING")
+	}
+	if name == "" {
+		name = RankingChronological
+	}
+	ranker, ok := rankers[name]
+	return name, ranker, ok
+}
@@ -0,0 +1,53 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankers(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	items := func() []FeedItem {
+		return []FeedItem{
+			{ID: "old-popular", PublishedAt: now.Add(-6 * time.Hour), LikeCount: 50, CommentCount: 10},
+			{ID: "recent-quiet", PublishedAt: now.Add(-1 * time.Hour)},
+			{ID: "very-old-popular", PublishedAt: now.Add(-30 * 24 * time.Hour), LikeCount: 100, CommentCount: 20},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		ranking       string
+		expectedOrder []string
+	}{
+		{
+			name:          "Chronological ranking",
+			ranking:       RankingChronological,
+			expectedOrder: []string{"recent-quiet", "old-popular", "very-old-popular"},
+		},
+		{
+			name:          "Engagement ranking",
+			ranking:       RankingEngagement,
+			expectedOrder: []string{"old-popular", "recent-quiet", "very-old-popular"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ranker, ok := GetRanker(tt.ranking)
+			assert.True(t, ok)
+
+			feedItems := items()
+			ranker.Rank(feedItems, now)
+
+			var order []string
+			for _, item := range feedItems {
+				order = append(order, item.ID)
+			}
+			assert.Equal(t, tt.expectedOrder, order)
+		})
+	}
+}
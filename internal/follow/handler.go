@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 	"github.com/gin-gonic/gin"
 )
@@ -58,6 +61,13 @@ func FollowUser(c *gin.Context) {
 		return
 	}
 
+	notification.Emit(notification.Event{
+		RecipientID: followingID,
+		ActorID:     followerID,
+		Type:        notification.TypeFollow,
+		TargetID:    followingID,
+	})
+
 	c.JSON(http.StatusCreated, gin.H{"message": "Utilisateur suivi"})
 	logs.LogJSON("INFO", "Followed user", map[string]interface{}{
 		"route":  route,
@@ -101,9 +111,20 @@ func GetFollowing(c *gin.Context) {
 
 	followerID := c.GetString("user_id")
 
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": followerID,
+		})
+		return
+	}
+
 	var follows []Follow
 	if err := database.DB.
 		Where("follower_id = ?", followerID).
+		Scopes(page.Scope("follows.created_at", "follows.id")).
 		Find(&follows).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur récupération creator"})
 		logs.LogJSON("ERROR", "Error recovery creator", map[string]interface{}{
@@ -114,6 +135,8 @@ func GetFollowing(c *gin.Context) {
 		return
 	}
 
+	follows, envelope := pagination.Paginate(page, follows, followCursorKey)
+
 	var usersFollowed []user.User
 	var ids []string
 	for _, f := range follows {
@@ -130,7 +153,7 @@ func GetFollowing(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"following": usersFollowed})
+	c.JSON(http.StatusOK, gin.H{"following": orderUsers(usersFollowed, ids), "pagination": envelope})
 	logs.LogJSON("INFO", "Recovering the list of users followed", map[string]interface{}{
 		"route":  route,
 		"userID": followerID,
@@ -143,9 +166,20 @@ func GetFollowers(c *gin.Context) {
 
 	userID := c.Param("id")
 
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
 	var follows []Follow
 	if err := database.DB.
 		Where("creator_id = ?", userID).
+		Scopes(page.Scope("follows.created_at", "follows.id")).
 		Find(&follows).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur récupération followers"})
 		logs.LogJSON("ERROR", "Error recovery followers", map[string]interface{}{
@@ -156,6 +190,8 @@ func GetFollowers(c *gin.Context) {
 		return
 	}
 
+	follows, envelope := pagination.Paginate(page, follows, followCursorKey)
+
 	var followers []user.User
 	var ids []string
 	for _, f := range follows {
@@ -172,9 +208,30 @@ func GetFollowers(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"followers": followers})
+	c.JSON(http.StatusOK, gin.H{"followers": orderUsers(followers, ids), "pagination": envelope})
 	logs.LogJSON("INFO", "Recovering the list of user followers", map[string]interface{}{
 		"route":  route,
 		"userID": userID,
 	})
 }
+
+// followCursorKey retourne la clé de pagination (created_at, id) d'un follow
+func followCursorKey(f Follow) (time.Time, string) {
+	return f.CreatedAt, f.ID
+}
+
+// orderUsers remet les utilisateurs dans l'ordre des ids de la page
+func orderUsers(users []user.User, ids []string) []user.User {
+	byID := make(map[string]user.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	ordered := make([]user.User, 0, len(users))
+	for _, id := range ids {
+		if u, ok := byID[id]; ok {
+			ordered = append(ordered, u)
+		}
+	}
+	return ordered
+}
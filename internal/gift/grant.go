@@ -0,0 +1,139 @@
+package gift
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+)
+
+// ErrAlreadySubscribed est retournée quand le bénéficiaire a déjà un abonnement récurrent au créateur,
+// y compris en défaut de paiement : son abonnement Stripe est toujours facturé
+var ErrAlreadySubscribed = errors.New("le bénéficiaire est déjà abonné à ce créateur")
+
+// Grant donne au bénéficiaire l'abonnement offert, jusqu'à une date de fin fixe.
+// Un abonnement offert encore en cours est prolongé, un ancien abonnement terminé est réutilisé.
+func Grant(tx *gorm.DB, g Gift) (time.Time, error) {
+	now := time.Now()
+	monthlyPrice := g.Amount / float64(g.Months)
+
+	var existing subscription.Subscription
+	err := tx.Where("subscriber_id = ? AND creator_id = ?", g.RecipientID, g.CreatorID).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, fmt.Errorf("récupération de l'abonnement : %w", err)
+	}
+
+	if err == nil {
+		start := now
+		extended := existing.Status == subscription.StatusGifted && !subscription.Expired(existing.ExpiresAt)
+		if extended {
+			start = *existing.ExpiresAt
+		} else if subscription.GrantsAccess(existing.Status) || existing.Recurring() {
+			return time.Time{}, ErrAlreadySubscribed
+		}
+		expiresAt := start.AddDate(0, g.Months, 0)
+
+		if err := tx.Model(&subscription.Subscription{}).
+			Where("id = ?", existing.ID).
+			Updates(map[string]interface{}{
+				"status":                 subscription.StatusGifted,
+				"stripe_subscription_id": "",
+				"price":                  monthlyPrice,
+				"tier_id":                nil,
+				"tier_rank":              0,
+				"bundle_id":              nil,
+				"interval_months":        1,
+				"gifted_by_id":           g.BuyerID,
+				"expires_at":             expiresAt,
+				"current_period_end":     expiresAt,
+				"cancelled_at":           nil,
+			}).Error; err != nil {
+			return time.Time{}, fmt.Errorf("mise à jour de l'abonnement offert : %w", err)
+		}
+
+		// Un abonnement offert prolongé reste dans sa période, un ancien abonnement en commence une nouvelle
+		if !extended {
+			existing.Price = monthlyPrice
+			existing.IntervalMonths = 1
+			if err := subscription.OpenPeriod(tx, existing, now, now); err != nil {
+				return time.Time{}, err
+			}
+		}
+		return expiresAt, nil
+	}
+
+	expiresAt := now.AddDate(0, g.Months, 0)
+	sub := subscription.Subscription{
+		CreatedAt:        now,
+		SubscriberID:     g.RecipientID,
+		CreatorID:        g.CreatorID,
+		Status:           subscription.StatusGifted,
+		Price:            monthlyPrice,
+		IntervalMonths:   1,
+		GiftedByID:       &g.BuyerID,
+		ExpiresAt:        &expiresAt,
+		CurrentPeriodEnd: &expiresAt,
+	}
+	if err := tx.Create(&sub).Error; err != nil {
+		return time.Time{}, fmt.Errorf("création de l'abonnement offert : %w", err)
+	}
+	if err := subscription.OpenPeriod(tx, sub, now, now); err != nil {
+		return time.Time{}, err
+	}
+	return expiresAt, nil
+}
+
+// ExpireGifts met fin aux abonnements offerts arrivés à échéance et prévient leurs bénéficiaires
+func ExpireGifts() error {
+	var due []subscription.Subscription
+	if err := database.DB.
+		Where("status = ? AND expires_at <= ?", subscription.StatusGifted, time.Now()).
+		Find(&due).Error; err != nil {
+		return fmt.Errorf("récupération des abonnements offerts échus : %w", err)
+	}
+
+	for _, sub := range due {
+		expired := false
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&subscription.Subscription{}).
+				Where("id = ? AND status = ?", sub.ID, subscription.StatusGifted).
+				Updates(map[string]interface{}{
+					"status":       subscription.StatusCancelled,
+					"cancelled_at": sub.ExpiresAt,
+				})
+			if result.Error != nil {
+				return fmt.Errorf("expiration de l'abonnement offert %s : %w", sub.ID, result.Error)
+			}
+			// Déjà expiré ou converti par une autre instance
+			if result.RowsAffected == 0 {
+				return nil
+			}
+			expired = true
+			return subscription.ClosePeriod(tx, sub.SubscriberID, sub.CreatorID, *sub.ExpiresAt)
+		})
+		if err != nil {
+			return err
+		}
+		if !expired {
+			continue
+		}
+
+		logs.LogJSON("INFO", "Gifted subscription expired", map[string]interface{}{
+			"userID":    sub.SubscriberID,
+			"creatorID": sub.CreatorID,
+		})
+		notification.Emit(notification.Event{
+			RecipientID: sub.SubscriberID,
+			ActorID:     sub.CreatorID,
+			Type:        notification.TypeGiftExpired,
+			TargetID:    sub.CreatorID,
+		})
+	}
+	return nil
+}
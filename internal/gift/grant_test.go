@@ -0,0 +1,122 @@
+package gift
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+var subscriptionColumns = []string{"id", "subscriber_id", "creator_id", "status", "stripe_subscription_id", "expires_at"}
+
+func TestGrant(t *testing.T) {
+	g := Gift{ID: "gift1", BuyerID: "fan1", RecipientID: "fan2", CreatorID: "creator1", Months: 3, Amount: 15}
+
+	t.Run("Abonnement offert en cours : prolongé à partir de sa fin", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		currentEnd := time.Now().Add(10 * 24 * time.Hour)
+
+		mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WithArgs("fan2", "creator1", 1).
+			WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow("sub1", "fan2", "creator1", "gifted", "", currentEnd))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "subscriptions" SET`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		expiresAt, err := Grant(database.DB, g)
+
+		assert.NoError(t, err)
+		assert.True(t, expiresAt.Equal(currentEnd.AddDate(0, 3, 0)))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Ancien abonnement résilié : nouvelle période d'abonnement", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow("sub1", "fan2", "creator1", "cancelled", "", nil))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "subscriptions" SET`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "subscription_periods" SET "ended_at"=\$1,"replaced"=\$2 WHERE subscriber_id = \$3 AND creator_id = \$4 AND ended_at IS NULL`).
+			WithArgs(sqlmock.AnyArg(), true, "fan2", "creator1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "subscription_periods"`).
+			WithArgs(sqlmock.AnyArg(), "fan2", "creator1", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 5.0, false, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("period1"))
+		mock.ExpectCommit()
+
+		_, err := Grant(database.DB, g)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Abonnement récurrent actif : refusé", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow("sub1", "fan2", "creator1", "active", "sub_1Stripe", nil))
+
+		_, err := Grant(database.DB, g)
+
+		assert.ErrorIs(t, err, ErrAlreadySubscribed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Abonnement récurrent impayé : refusé tant que Stripe le facture", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+			WillReturnRows(sqlmock.NewRows(subscriptionColumns).AddRow("sub1", "fan2", "creator1", "past_due", "sub_1Stripe", nil))
+
+		_, err := Grant(database.DB, g)
+
+		assert.ErrorIs(t, err, ErrAlreadySubscribed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestExpireGifts(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+	recorder := &notification.Recorder{}
+	previous := notification.SetEmitter(recorder)
+	defer notification.SetEmitter(previous)
+
+	past := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE status = \$1 AND expires_at <= \$2`).
+		WithArgs("gifted", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(subscriptionColumns).
+			AddRow("sub1", "fan2", "creator1", "gifted", "", past).
+			AddRow("sub2", "fan3", "creator1", "gifted", "", past))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "subscriptions" SET "cancelled_at"=\$1,"status"=\$2 WHERE id = \$3 AND status = \$4`).
+		WithArgs(sqlmock.AnyArg(), "cancelled", "sub1", "gifted").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "subscription_periods" SET "ended_at"=\$1 WHERE subscriber_id = \$2 AND creator_id = \$3 AND ended_at IS NULL`).
+		WithArgs(past, "fan2", "creator1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// Déjà expiré par une autre instance : pas de seconde notification
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "subscriptions" SET "cancelled_at"=\$1,"status"=\$2 WHERE id = \$3 AND status = \$4`).
+		WithArgs(sqlmock.AnyArg(), "cancelled", "sub2", "gifted").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, ExpireGifts())
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, notification.TypeGiftExpired, recorder.Events[0].Type)
+	assert.Equal(t, "fan2", recorder.Events[0].RecipientID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,46 @@
+package gift
+
+import "time"
+
+// Statuts d'un cadeau : il n'est confirmé qu'à la réception du webhook Stripe
+const (
+	StatusPending       = "pending"
+	StatusPaid          = "paid"
+	StatusRefundPending = "refund_pending" // payé mais refusé, le bénéficiaire ayant un abonnement récurrent : à rembourser
+)
+
+// Bornes de la durée d'un abonnement offert, en mois
+const (
+	MinMonths = 1
+	MaxMonths = 12
+)
+
+// MaxMessageLength est la longueur maximale du mot accompagnant un cadeau
+const MaxMessageLength = 280
+
+// Gift est un abonnement de plusieurs mois à un créateur, payé en une fois par un utilisateur pour un autre
+type Gift struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	BuyerID         string     `json:"buyer_id" gorm:"index"`
+	RecipientID     string     `json:"recipient_id" gorm:"index"`
+	CreatorID       string     `json:"creator_id"`
+	Months          int        `json:"months"`
+	Amount          float64    `json:"amount"`
+	Message         string     `json:"message"`
+	Status          string     `json:"status"`
+	StripeSessionID string     `json:"-"`
+	PaidAt          *time.Time `json:"paid_at"`
+}
+
+func (Gift) TableName() string {
+	return "subscription_gifts"
+}
+
+// CreateGiftInput est le corps de la requête d'achat d'un abonnement offert
+type CreateGiftInput struct {
+	RecipientUsername string `json:"recipient_username" binding:"required"`
+	Months            int    `json:"months" binding:"required"`
+	Message           string `json:"message"`
+}
@@ -2,10 +2,17 @@
 package like
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/aggregate"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
+	postmodel "github.com/ArthurDelaporte/OnlyFeed-Back/internal/post"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -29,26 +36,9 @@ func ToggleLike(c *gin.Context) {
 		return
 	}
 
-	//  Vérifier si le post existe (CORRECTION)
-	var postCount int64
-	if err := database.DB.Table("posts").Where("id = ?", postID).Count(&postCount).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
-		logs.LogJSON("ERROR", "Database error", map[string]interface{}{
-			"error":  err.Error(),
-			"route":  route,
-			"userID": userID,
-			"postID": postID,
-		})
-
-		return
-	}
-	if postCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
-		logs.LogJSON("WARN", "Post not found", map[string]interface{}{
-			"route":  route,
-			"userID": userID,
-			"postID": postID,
-		})
+	// Vérifier si le post existe et si l'utilisateur y a accès
+	post, ok := checkPostAccess(c, postID, userID)
+	if !ok {
 		return
 	}
 
@@ -84,6 +74,13 @@ func ToggleLike(c *gin.Context) {
 			})
 			return
 		}
+
+		notification.Emit(notification.Event{
+			RecipientID: post.UserID,
+			ActorID:     userID,
+			Type:        notification.TypeLike,
+			TargetID:    postID,
+		})
 	} else {
 		// Erreur de base de données
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
@@ -103,29 +100,11 @@ func ToggleLike(c *gin.Context) {
 
 // GetLikeStatus GET /api/posts/:id/likes
 func GetLikeStatus(c *gin.Context) {
-	route := c.FullPath()
 	postID := c.Param("id")
 	userID := c.GetString("user_id") // Peut être vide si non connecté
 
-	//  Vérifier si le post existe (CORRECTION)
-	var postCount int64
-	if err := database.DB.Table("posts").Where("id = ?", postID).Count(&postCount).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
-		logs.LogJSON("ERROR", "Database error", map[string]interface{}{
-			"error":  err.Error(),
-			"route":  route,
-			"userID": userID,
-			"postID": postID,
-		})
-		return
-	}
-	if postCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
-		logs.LogJSON("WARN", "Post not found", map[string]interface{}{
-			"route":  route,
-			"userID": userID,
-			"postID": postID,
-		})
+	// Vérifier si le post existe et si l'utilisateur y a accès
+	if _, ok := checkPostAccess(c, postID, userID); !ok {
 		return
 	}
 
@@ -148,6 +127,9 @@ func GetPostByIDWithLikes(c *gin.Context) {
 		Description string    `json:"description"`
 		MediaURL    string    `json:"media_url"`
 		IsPaid      bool      `json:"is_paid"`
+		UnlockPrice *float64  `json:"unlock_price"`
+		MinTierRank int       `json:"min_tier_rank"`
+		Status      string    `json:"status"`
 	}
 
 	if err := database.DB.Table("posts").Where("id = ?", postID).First(&post).Error; err != nil {
@@ -161,18 +143,21 @@ func GetPostByIDWithLikes(c *gin.Context) {
 	}
 
 	// Vérification des permissions pour les posts payants
-	if post.IsPaid {
-		if userID == "" || post.UserID != userID {
-			// Ici vous pourriez implémenter une vérification d'abonnement
-			// Pour l'instant, seul le créateur peut voir son propre post payant
-			c.JSON(http.StatusForbidden, gin.H{"error": "Accès non autorisé à ce contenu premium"})
-			logs.LogJSON("WARN", "Unauthorized access to premium content", map[string]interface{}{
-				"route":  route,
-				"userID": userID,
-				"postID": postID,
-			})
-			return
-		}
+	if !canViewPost(c, access.Post{ID: post.ID, UserID: post.UserID, Status: post.Status, IsPaid: post.IsPaid, MinTierRank: post.MinTierRank}, userID) {
+		return
+	}
+
+	// Médias du carrousel, tous visibles et signés une fois l'accès vérifié
+	media, err := postmodel.MediaByPost([]string{post.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des médias"})
+		logs.LogJSON("ERROR", "Error retrieving post media", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
 	}
 
 	// Ajouter les informations de likes
@@ -183,8 +168,12 @@ func GetPostByIDWithLikes(c *gin.Context) {
 		"ID":          post.ID,
 		"Title":       post.Title,
 		"Description": post.Description,
-		"MediaURL":    post.MediaURL,
+		"MediaURL":    storage.SignedURL(post.MediaURL),
+		"Media":       postmodel.SignMedia(media[post.ID]),
 		"IsPaid":      post.IsPaid,
+		"UnlockPrice": post.UnlockPrice,
+		"MinTierRank": post.MinTierRank,
+		"Status":      post.Status,
 		"CreatedAt":   post.CreatedAt,
 		"UserID":      post.UserID,
 		"like_count":  likeStatus.LikeCount,
@@ -200,13 +189,23 @@ func GetPostsWithLikes(c *gin.Context) {
 	userID := c.GetString("user_id")
 	showPaywalled := c.Query("paywalled") == "true"
 
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
 	// 🔧 CORRECTION: Construire la requête avec JOIN pour récupérer les infos utilisateur
 	query := database.DB.Table("posts").
-		Select(`posts.id, posts.created_at, posts.user_id, posts.title, posts.description, 
-		        posts.media_url, posts.is_paid,
+		Select(`posts.id, posts.created_at, posts.published_at, posts.user_id, posts.title, posts.description, 
+		        posts.media_url, posts.is_paid, posts.unlock_price, posts.min_tier_rank,
 		        users.username, users.avatar_url, users.is_creator`).
 		Joins("LEFT JOIN users ON posts.user_id = users.id").
-		Order("posts.created_at DESC")
+		Scopes(access.PublishedPosts(""), page.Scope("posts.published_at", "posts.id"))
 
 	// Filtrer les posts selon les règles d'accès
 	if !showPaywalled || userID == "" {
@@ -214,24 +213,12 @@ func GetPostsWithLikes(c *gin.Context) {
 		query = query.Where("posts.is_paid = ?", false)
 	} else {
 		// Utilisateur connecté qui veut voir du contenu payant:
-		// Montrer les posts gratuits et ses propres posts payants
-		query = query.Where("posts.is_paid = ? OR (posts.is_paid = ? AND posts.user_id = ?)", false, true, userID)
+		// Montrer les posts gratuits, ses propres posts payants et ceux des créateurs auxquels il est abonné
+		query = query.Scopes(access.VisiblePosts(userID))
 	}
 
 	// 🔧 CORRECTION: Structure pour récupérer les posts avec infos utilisateur
-	var posts []struct {
-		ID          string    `json:"id"`
-		CreatedAt   time.Time `json:"created_at"`
-		UserID      string    `json:"user_id"`
-		Title       string    `json:"title"`
-		Description string    `json:"description"`
-		MediaURL    string    `json:"media_url"`
-		IsPaid      bool      `json:"is_paid"`
-		// 🆕 NOUVEAUX champs utilisateur
-		Username  string `json:"username"`
-		AvatarURL string `json:"avatar_url"`
-		IsCreator bool   `json:"is_creator"`
-	}
+	var posts []PostWithUser
 
 	if err := query.Find(&posts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des posts"})
@@ -244,21 +231,56 @@ func GetPostsWithLikes(c *gin.Context) {
 
 	}
 
+	posts, envelope := pagination.Paginate(page, posts, func(p PostWithUser) (time.Time, string) {
+		return p.PublishedAt, p.ID
+	})
+
+	// Compteurs de likes et commentaires de toute la page en une seule requête
+	postIDs := make([]string, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	stats, err := aggregate.PostStats(postIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des likes"})
+		logs.LogJSON("ERROR", "Error during likes aggregation", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+	media, err := postmodel.MediaByPost(postIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des médias"})
+		logs.LogJSON("ERROR", "Error retrieving post media", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
 	// 🔧 CORRECTION: Construire la réponse avec likes ET infos utilisateur
 	var postsWithLikes []gin.H
 	for _, post := range posts {
-		likeStatus := getLikeStatus(post.ID, userID)
+		likeStatus := stats[post.ID]
 
 		postWithLikes := gin.H{
-			"id":          post.ID,
-			"created_at":  post.CreatedAt,
-			"user_id":     post.UserID,
-			"title":       post.Title,
-			"description": post.Description,
-			"media_url":   post.MediaURL,
-			"is_paid":     post.IsPaid,
-			"like_count":  likeStatus.LikeCount,
-			"is_liked":    likeStatus.IsLiked,
+			"id":            post.ID,
+			"created_at":    post.CreatedAt,
+			"published_at":  post.PublishedAt,
+			"user_id":       post.UserID,
+			"title":         post.Title,
+			"description":   post.Description,
+			"media_url":     storage.SignedURL(post.MediaURL),
+			"media":         postmodel.SignMedia(media[post.ID]),
+			"is_paid":       post.IsPaid,
+			"unlock_price":  post.UnlockPrice,
+			"min_tier_rank": post.MinTierRank,
+			"like_count":    likeStatus.LikeCount,
+			"is_liked":      likeStatus.IsLiked,
+			"comment_count": likeStatus.CommentCount,
 			// 🆕 NOUVELLES infos utilisateur
 			"username":   post.Username,
 			"avatar_url": post.AvatarURL,
@@ -267,13 +289,79 @@ func GetPostsWithLikes(c *gin.Context) {
 		postsWithLikes = append(postsWithLikes, postWithLikes)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"posts": postsWithLikes})
+	c.JSON(http.StatusOK, gin.H{"posts": postsWithLikes, "pagination": envelope})
 	logs.LogJSON("INFO", "Posts retrieved successfully", map[string]interface{}{
 		"route":  route,
 		"userID": userID,
 	})
 }
 
+// checkPostAccess vérifie que le post existe et que l'utilisateur peut y accéder, et répond à sa place sinon
+func checkPostAccess(c *gin.Context, postID, userID string) (access.Post, bool) {
+	route := c.FullPath()
+
+	var post access.Post
+	if err := database.DB.Table("posts").Select("id, user_id, status, is_paid, min_tier_rank").Where("id = ?", postID).Take(&post).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+			logs.LogJSON("WARN", "Post not found", map[string]interface{}{
+				"route":  route,
+				"userID": userID,
+				"postID": postID,
+			})
+			return post, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
+		logs.LogJSON("ERROR", "Database error", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return post, false
+	}
+
+	return post, canViewPost(c, post, userID)
+}
+
+// canViewPost applique les règles d'accès à un post déjà chargé et répond à sa place en cas de refus
+func canViewPost(c *gin.Context, post access.Post, userID string) bool {
+	route := c.FullPath()
+
+	// Un post non publié n'existe pas pour les autres utilisateurs que son auteur
+	if post.HiddenFrom(userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+		logs.LogJSON("WARN", "Unpublished post", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": post.ID,
+		})
+		return false
+	}
+
+	canView, err := access.CanViewPost(userID, post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification de l'abonnement"})
+		logs.LogJSON("ERROR", "Subscription verification error", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": post.ID,
+		})
+		return false
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Accès non autorisé à ce contenu premium"})
+		logs.LogJSON("WARN", "Unauthorized access to premium content", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": post.ID,
+		})
+		return false
+	}
+	return true
+}
+
 // Fonction utilitaire pour obtenir le statut des likes
 func getLikeStatus(postID, userID string) LikeResponse {
 	var likeCount int64
@@ -17,6 +17,23 @@ type LikeResponse struct {
 	IsLiked   bool   `json:"is_liked"`
 }
 
+// PostWithUser représente un post avec les informations publiques de son auteur
+type PostWithUser struct {
+	ID          string    `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	PublishedAt time.Time `json:"published_at"`
+	UserID      string    `json:"user_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	MediaURL    string    `json:"media_url"`
+	IsPaid      bool      `json:"is_paid"`
+	UnlockPrice *float64  `json:"unlock_price"`
+	MinTierRank int       `json:"min_tier_rank"`
+	Username    string    `json:"username"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsCreator   bool      `json:"is_creator"`
+}
+
 func (Like) TableName() string {
 	return "likes"
 }
@@ -1,6 +1,7 @@
 package message
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
@@ -13,9 +14,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/aggregate"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/realtime"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/upload"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
 
@@ -24,7 +30,21 @@ func GetConversations(c *gin.Context) {
 	userID := c.GetString("user_id")
 	route := c.FullPath()
 
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
 	// Récupérer les conversations où l'utilisateur n'a pas créé de suppression
+	// (triées par date d'activité : dernier message, ou création si aucun message).
+	// Cette clé change à chaque message : une conversation qui reçoit un message pendant le parcours
+	// des pages remonte en tête et n'apparaît pas dans les pages suivantes. Le client la reçoit déjà
+	// par l'événement temps réel du message ; l'ordre par activité est préféré à une clé immuable.
 	var conversations []Conversation
 	if err := database.DB.
 		Where("(user1_id = ? OR user2_id = ?) AND id NOT IN (?)",
@@ -34,7 +54,7 @@ func GetConversations(c *gin.Context) {
 				Where("user_id = ?", userID)).
 		Preload("User1").
 		Preload("User2").
-		Order("last_message_at DESC NULLS LAST, created_at DESC").
+		Scopes(page.Scope("COALESCE(conversations.last_message_at, conversations.created_at)", "conversations.id")).
 		Find(&conversations).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des conversations"})
 		logs.LogJSON("ERROR", "Error during conversation retrieval", map[string]interface{}{
@@ -45,6 +65,53 @@ func GetConversations(c *gin.Context) {
 		return
 	}
 
+	conversations, envelope := pagination.Paginate(page, conversations, func(conv Conversation) (time.Time, string) {
+		if conv.LastMessageAt != nil {
+			return *conv.LastMessageAt, conv.ID
+		}
+		return conv.CreatedAt, conv.ID
+	})
+
+	// Récupérer les dates de suppression, les non lus et les derniers messages de toute la page
+	// en un nombre constant de requêtes
+	conversationIDs := make([]string, 0, len(conversations))
+	for _, conv := range conversations {
+		conversationIDs = append(conversationIDs, conv.ID)
+	}
+
+	deletionTimes, err := getDeletionTimes(conversationIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des conversations"})
+		logs.LogJSON("ERROR", "Error during conversation deletions retrieval", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	unreadCounts, err := aggregate.UnreadCounts(conversationIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des conversations"})
+		logs.LogJSON("ERROR", "Error during unread count aggregation", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	lastMessages, err := getLastMessages(conversationIDs, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des conversations"})
+		logs.LogJSON("ERROR", "Error during last messages retrieval", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
 	var response []ConversationResponse
 	for _, conv := range conversations {
 		// Déterminer l'autre utilisateur
@@ -55,62 +122,15 @@ func GetConversations(c *gin.Context) {
 			otherUser = conv.User1
 		}
 
-		// Récupérer la date de suppression pour cet utilisateur (si elle existe)
-		var deletionTime *time.Time
-		var deletion ConversationDeletion
-		if err := database.DB.
-			Where("user_id = ? AND conversation_id = ?", userID, conv.ID).
-			First(&deletion).Error; err == nil {
-			deletionTime = &deletion.DeletedAt
-		}
-
-		// Compter les messages non lus postérieurs à la suppression
-		var unreadCount int64
-		unreadQuery := database.DB.Model(&Message{}).
-			Where("conversation_id = ? AND receiver_id = ? AND is_read = false AND is_deleted = false", conv.ID, userID)
-
-		if deletionTime != nil {
-			unreadQuery = unreadQuery.Where("created_at > ?", *deletionTime)
-		}
-
-		unreadQuery.Count(&unreadCount)
-
-		// Récupérer le dernier message postérieur à la suppression
+		// Dernier message postérieur à la suppression
 		var lastMessage *MessageResponse
-		if conv.LastMessageAt != nil {
-			var msg Message
-			lastMsgQuery := database.DB.
-				Where("conversation_id = ?", conv.ID).
-				Preload("Sender").
-				Order("created_at DESC")
-
-			if deletionTime != nil {
-				lastMsgQuery = lastMsgQuery.Where("created_at > ?", *deletionTime)
-			}
-
-			if err := lastMsgQuery.First(&msg).Error; err == nil {
-				lastMessage = &MessageResponse{
-					ID:             msg.ID,
-					CreatedAt:      msg.CreatedAt,
-					ConversationID: msg.ConversationID,
-					Sender: ConversationUser{
-						ID:        msg.Sender.ID,
-						Username:  msg.Sender.Username,
-						AvatarURL: msg.Sender.AvatarURL,
-						IsCreator: msg.Sender.IsCreator,
-					},
-					Content:     msg.Content,
-					MessageType: msg.MessageType,
-					MediaURL:    msg.MediaURL,
-					IsRead:      msg.IsRead,
-					ReadAt:      msg.ReadAt,
-					IsDeleted:   msg.IsDeleted,
-				}
-			}
+		if msg, ok := lastMessages[conv.ID]; ok {
+			msgResponse := toMessageResponse(msg, userID)
+			lastMessage = &msgResponse
 		}
 
 		// Ne pas inclure les conversations sans messages après suppression
-		if deletionTime != nil && lastMessage == nil {
+		if _, deleted := deletionTimes[conv.ID]; deleted && lastMessage == nil {
 			continue
 		}
 
@@ -126,13 +146,13 @@ func GetConversations(c *gin.Context) {
 			},
 			LastMessage:   lastMessage,
 			LastMessageAt: conv.LastMessageAt,
-			UnreadCount:   unreadCount,
+			UnreadCount:   unreadCounts[conv.ID],
 		}
 
 		response = append(response, convResponse)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"conversations": response})
+	c.JSON(http.StatusOK, gin.H{"conversations": response, "pagination": envelope})
 	logs.LogJSON("INFO", "Conversations retrieved successfully", map[string]interface{}{
 		"route":  route,
 		"userID": userID,
@@ -179,9 +199,16 @@ func GetConversationMessages(c *gin.Context) {
 	}
 
 	// Pagination
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
-	offset := (page - 1) * limit
+	page, err := pagination.FromRequest(c, 50)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":          route,
+			"userID":         userID,
+			"conversationID": conversationID,
+		})
+		return
+	}
 
 	// Récupérer les messages postérieurs à la suppression
 	var messages []Message
@@ -194,9 +221,7 @@ func GetConversationMessages(c *gin.Context) {
 
 	if err := msgQuery.
 		Preload("Sender").
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
+		Scopes(page.Scope("messages.created_at", "messages.id")).
 		Find(&messages).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des messages"})
 		logs.LogJSON("ERROR", "Error during data retrieval", map[string]interface{}{
@@ -208,45 +233,49 @@ func GetConversationMessages(c *gin.Context) {
 		return
 	}
 
+	messages, envelope := pagination.Paginate(page, messages, func(msg Message) (time.Time, string) {
+		return msg.CreatedAt, msg.ID
+	})
+
 	// Marquer les messages comme lus (seulement ceux postérieurs à la suppression)
-	go func() {
-		markAsReadQuery := database.DB.Model(&Message{}).
-			Where("conversation_id = ? AND receiver_id = ? AND is_read = false", conversationID, userID)
+	readAt := time.Now()
+	markAsReadQuery := database.DB.Model(&Message{}).
+		Where("conversation_id = ? AND receiver_id = ? AND is_read = false", conversationID, userID)
 
-		if deletionTime != nil {
-			markAsReadQuery = markAsReadQuery.Where("created_at > ?", *deletionTime)
-		}
+	if deletionTime != nil {
+		markAsReadQuery = markAsReadQuery.Where("created_at > ?", *deletionTime)
+	}
 
-		markAsReadQuery.Updates(map[string]interface{}{
-			"is_read": true,
-			"read_at": time.Now(),
+	markAsRead := markAsReadQuery.Updates(map[string]interface{}{
+		"is_read": true,
+		"read_at": readAt,
+	})
+	if markAsRead.Error != nil {
+		logs.LogJSON("ERROR", "Error during messages read update", map[string]interface{}{
+			"error":          markAsRead.Error.Error(),
+			"route":          route,
+			"userID":         userID,
+			"conversationID": conversationID,
 		})
-	}()
+	} else if markAsRead.RowsAffected > 0 {
+		// Accusé de lecture pour l'autre participant
+		otherUserID := conversation.User1ID
+		if otherUserID == userID {
+			otherUserID = conversation.User2ID
+		}
+		realtime.Publish(realtime.Event{
+			Type: realtime.EventConversationRead,
+			Data: ReadEvent{ConversationID: conversationID, ReaderID: userID, ReadAt: readAt},
+		}, otherUserID)
+	}
 
 	// Convertir en response format
 	var response []MessageResponse
 	for _, msg := range messages {
-		msgResponse := MessageResponse{
-			ID:             msg.ID,
-			CreatedAt:      msg.CreatedAt,
-			ConversationID: msg.ConversationID,
-			Sender: ConversationUser{
-				ID:        msg.Sender.ID,
-				Username:  msg.Sender.Username,
-				AvatarURL: msg.Sender.AvatarURL,
-				IsCreator: msg.Sender.IsCreator,
-			},
-			Content:     msg.Content,
-			MessageType: msg.MessageType,
-			MediaURL:    msg.MediaURL,
-			IsRead:      msg.IsRead,
-			ReadAt:      msg.ReadAt,
-			IsDeleted:   msg.IsDeleted,
-		}
-		response = append(response, msgResponse)
+		response = append(response, toMessageResponse(msg, userID))
 	}
 
-	c.JSON(http.StatusOK, gin.H{"messages": response})
+	c.JSON(http.StatusOK, gin.H{"messages": response, "pagination": envelope})
 	logs.LogJSON("INFO", "Conversation messages retrieved successfully", map[string]interface{}{
 		"route":          route,
 		"userID":         userID,
@@ -264,6 +293,7 @@ func SendMessage(c *gin.Context) {
 	var mediaURL string
 
 	// Tentative de parsing JSON pour message texte
+	isForm := false
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// Si erreur JSON, alors c'est probablement un form-data avec média
 		receiverID := c.PostForm("receiver_id")
@@ -285,54 +315,98 @@ func SendMessage(c *gin.Context) {
 			ReceiverID:  receiverID,
 			Content:     content,
 			MessageType: MessageType(messageTypeStr),
+			UploadID:    c.PostForm("upload_id"),
 		}
 
-		// Traitement du fichier média si présent
-		if input.MessageType != MessageTypeText {
-			file, header, err := c.Request.FormFile("media")
+		if priceStr := c.PostForm("price"); priceStr != "" && input.MessageType != MessageTypeText {
+			value, err := strconv.ParseFloat(priceStr, 64)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Fichier média requis pour ce type de message"})
-				logs.LogJSON("ERROR", "Media file required for message type", map[string]interface{}{
-					"route":       route,
-					"userID":      userID,
-					"receiverID":  input.ReceiverID,
-					"messageType": input.MessageType,
-				})
-				return
-			}
-			defer file.Close()
-
-			// Validation du type de fichier
-			ext := strings.ToLower(filepath.Ext(header.Filename))
-			validExtensions := getValidExtensions(input.MessageType)
-
-			if !validExtensions[ext] {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Extension de fichier invalide"})
-				logs.LogJSON("ERROR", "Invalid file extension", map[string]interface{}{
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Prix invalide"})
+				logs.LogJSON("WARN", "Invalid message price", map[string]interface{}{
 					"route":  route,
 					"userID": userID,
-					"extra":  fmt.Sprintf("Invalid file extension : %s", ext),
+					"extra":  fmt.Sprintf("price : %s", priceStr),
 				})
 				return
 			}
+			input.Price = &value
+		}
+		isForm = true
+	}
+
+	// Les messages de pourboire ne sont créés qu'après confirmation du paiement par Stripe,
+	// et sont refusés avant tout envoi de fichier sur S3
+	if input.MessageType == MessageTypeTip {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Un pourboire doit passer par le paiement dédié"})
+		logs.LogJSON("WARN", "Tip message sent directly", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
 
-			// Upload du fichier
-			messageID := uuid.New().String()
-			filename := fmt.Sprintf("message_%s%s", messageID, ext)
-			contentType := header.Header.Get("Content-Type")
+	// Le prix est vérifié avant l'envoi du fichier sur S3
+	price, ok := messagePrice(c, input)
+	if !ok {
+		return
+	}
 
-			url, err := storage.UploadToS3(file, filename, contentType, "messages")
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'upload du fichier"})
-				logs.LogJSON("ERROR", "Error during file upload", map[string]interface{}{
-					"error":  err.Error(),
-					"route":  route,
-					"userID": userID,
-				})
-				return
-			}
-			mediaURL = url
+	// Un média doit être joint au formulaire, ou avoir été envoyé directement sur S3
+	if input.MessageType != MessageTypeText && input.UploadID == "" {
+		if !isForm {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fichier média requis pour ce type de message"})
+			logs.LogJSON("WARN", "Media upload required for message type", map[string]interface{}{
+				"route":       route,
+				"userID":      userID,
+				"receiverID":  input.ReceiverID,
+				"messageType": input.MessageType,
+			})
+			return
+		}
+
+		file, header, err := c.Request.FormFile("media")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fichier média requis pour ce type de message"})
+			logs.LogJSON("ERROR", "Media file required for message type", map[string]interface{}{
+				"route":       route,
+				"userID":      userID,
+				"receiverID":  input.ReceiverID,
+				"messageType": input.MessageType,
+			})
+			return
 		}
+		defer file.Close()
+
+		// Validation du type de fichier
+		ext := strings.ToLower(filepath.Ext(header.Filename))
+		validExtensions := getValidExtensions(input.MessageType)
+
+		if !validExtensions[ext] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Extension de fichier invalide"})
+			logs.LogJSON("ERROR", "Invalid file extension", map[string]interface{}{
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("Invalid file extension : %s", ext),
+			})
+			return
+		}
+
+		// Upload du fichier
+		messageID := uuid.New().String()
+		filename := fmt.Sprintf("message_%s%s", messageID, ext)
+		contentType := header.Header.Get("Content-Type")
+
+		key, err := storage.UploadToS3(file, filename, contentType, "messages")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'upload du fichier"})
+			logs.LogJSON("ERROR", "Error during file upload", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+			})
+			return
+		}
+		mediaURL = key
 	}
 
 	// Vérifier que l'utilisateur destinataire existe
@@ -357,6 +431,15 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
+	// Média envoyé directement sur S3 et déjà finalisé
+	if input.UploadID != "" && input.MessageType != MessageTypeText {
+		url, ok := mediaFromUpload(c, input.UploadID, input.MessageType)
+		if !ok {
+			return
+		}
+		mediaURL = url
+	}
+
 	// Trouver ou créer la conversation
 	conversation, err := findOrCreateConversation(userID, input.ReceiverID)
 	if err != nil {
@@ -378,6 +461,7 @@ func SendMessage(c *gin.Context) {
 		Content:        input.Content,
 		MessageType:    input.MessageType,
 		MediaURL:       mediaURL,
+		Price:          price,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
@@ -385,10 +469,7 @@ func SendMessage(c *gin.Context) {
 	if err := database.DB.Create(&message).Error; err != nil {
 		// Si création échoue et qu'on a uploadé un fichier, le supprimer
 		if mediaURL != "" {
-			urlParts := strings.Split(mediaURL, ".amazonaws.com/")
-			if len(urlParts) > 1 {
-				_ = storage.DeleteFromS3(urlParts[1])
-			}
+			_ = storage.DeleteFromS3(mediaURL)
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'envoi du message"})
 		logs.LogJSON("ERROR", "Error during message sending", map[string]interface{}{
@@ -399,43 +480,15 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Si l'utilisateur destinataire avait supprimé la conversation,
-	// supprimer l'enregistrement de suppression pour lui permettre de voir les nouveaux messages
-	database.DB.Where("user_id = ? AND conversation_id = ?", input.ReceiverID, conversation.ID).
-		Delete(&ConversationDeletion{})
-
-	// Faire de même pour l'expéditeur au cas où
-	database.DB.Where("user_id = ? AND conversation_id = ?", userID, conversation.ID).
-		Delete(&ConversationDeletion{})
+	response := deliver(conversation, &message)
 
-	// Mettre à jour la conversation avec le dernier message
-	now := time.Now()
-	database.DB.Model(&conversation).Updates(map[string]interface{}{
-		"last_message_at": now,
-		"updated_at":      now,
+	notification.Emit(notification.Event{
+		RecipientID: input.ReceiverID,
+		ActorID:     userID,
+		Type:        notification.TypeMessage,
+		TargetID:    conversation.ID,
 	})
 
-	// Récupérer le message avec les relations pour la réponse
-	database.DB.Preload("Sender").First(&message, message.ID)
-
-	response := MessageResponse{
-		ID:             message.ID,
-		CreatedAt:      message.CreatedAt,
-		ConversationID: message.ConversationID,
-		Sender: ConversationUser{
-			ID:        message.Sender.ID,
-			Username:  message.Sender.Username,
-			AvatarURL: message.Sender.AvatarURL,
-			IsCreator: message.Sender.IsCreator,
-		},
-		Content:     message.Content,
-		MessageType: message.MessageType,
-		MediaURL:    message.MediaURL,
-		IsRead:      message.IsRead,
-		ReadAt:      message.ReadAt,
-		IsDeleted:   message.IsDeleted,
-	}
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message":         response,
 		"conversation_id": conversation.ID,
@@ -476,6 +529,12 @@ func MarkMessageAsRead(c *gin.Context) {
 			})
 			return
 		}
+
+		// Accusé de lecture pour l'expéditeur
+		realtime.Publish(realtime.Event{
+			Type: realtime.EventMessageRead,
+			Data: ReadEvent{ConversationID: message.ConversationID, MessageID: message.ID, ReaderID: userID, ReadAt: now},
+		}, message.SenderID, userID)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Message marqué comme lu"})
@@ -521,6 +580,11 @@ func DeleteMessage(c *gin.Context) {
 		return
 	}
 
+	realtime.Publish(realtime.Event{
+		Type: realtime.EventMessageDeleted,
+		Data: DeletedEvent{ConversationID: message.ConversationID, MessageID: message.ID, DeletedAt: now},
+	}, message.SenderID, message.ReceiverID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Message supprimé"})
 	logs.LogJSON("INFO", "Message deleted successfully", map[string]interface{}{
 		"route":     route,
@@ -650,6 +714,194 @@ func findOrCreateConversation(user1ID, user2ID string) (*Conversation, error) {
 	return &conversation, nil
 }
 
+// getDeletionTimes retourne la date de suppression de chaque conversation supprimée par l'utilisateur
+func getDeletionTimes(conversationIDs []string, userID string) (map[string]time.Time, error) {
+	deletionTimes := make(map[string]time.Time)
+	if len(conversationIDs) == 0 {
+		return deletionTimes, nil
+	}
+
+	var deletions []ConversationDeletion
+	if err := database.DB.
+		Where("user_id = ? AND conversation_id IN ?", userID, conversationIDs).
+		Find(&deletions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, deletion := range deletions {
+		deletionTimes[deletion.ConversationID] = deletion.DeletedAt
+	}
+	return deletionTimes, nil
+}
+
+// getLastMessages retourne le dernier message de chaque conversation, postérieur à une éventuelle suppression
+func getLastMessages(conversationIDs []string, userID string) (map[string]Message, error) {
+	lastMessages := make(map[string]Message)
+	if len(conversationIDs) == 0 {
+		return lastMessages, nil
+	}
+
+	lastIDs := database.DB.Table("messages").
+		Select("DISTINCT ON (messages.conversation_id) messages.id").
+		Joins("LEFT JOIN conversation_deletions ON conversation_deletions.conversation_id = messages.conversation_id AND conversation_deletions.user_id = ?", userID).
+		Where("messages.conversation_id IN ?", conversationIDs).
+		Where("conversation_deletions.deleted_at IS NULL OR messages.created_at > conversation_deletions.deleted_at").
+		Order("messages.conversation_id, messages.created_at DESC")
+
+	var messages []Message
+	if err := database.DB.
+		Where("id IN (?)", lastIDs).
+		Preload("Sender").
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	for _, msg := range messages {
+		lastMessages[msg.ConversationID] = msg
+	}
+	return lastMessages, nil
+}
+
+// deliver rend visible un message qui vient d'être enregistré : la conversation réapparaît
+// pour les deux participants, remonte en tête de liste et le message leur est poussé en temps réel
+func deliver(conversation *Conversation, message *Message) MessageResponse {
+	// Si l'utilisateur destinataire avait supprimé la conversation,
+	// supprimer l'enregistrement de suppression pour lui permettre de voir les nouveaux messages
+	database.DB.Where("user_id = ? AND conversation_id = ?", message.ReceiverID, conversation.ID).
+		Delete(&ConversationDeletion{})
+
+	// Faire de même pour l'expéditeur au cas où
+	database.DB.Where("user_id = ? AND conversation_id = ?", message.SenderID, conversation.ID).
+		Delete(&ConversationDeletion{})
+
+	// Mettre à jour la conversation avec le dernier message
+	now := time.Now()
+	database.DB.Model(conversation).Updates(map[string]interface{}{
+		"last_message_at": now,
+		"updated_at":      now,
+	})
+
+	// Récupérer le message avec les relations pour la réponse
+	database.DB.Preload("Sender").First(message, "id = ?", message.ID)
+
+	response := toMessageResponse(*message, message.SenderID)
+
+	// Pousser le nouveau message aux deux participants, chacun avec sa vue du média
+	realtime.Publish(realtime.Event{Type: realtime.EventMessageNew, Data: response}, message.SenderID)
+	realtime.Publish(realtime.Event{Type: realtime.EventMessageNew, Data: toMessageResponse(*message, message.ReceiverID)}, message.ReceiverID)
+
+	return response
+}
+
+// toMessageResponse convertit un message (avec son expéditeur chargé) au format de réponse,
+// vu par l'utilisateur donné : un média payant non acheté n'est pas exposé
+func toMessageResponse(msg Message, viewerID string) MessageResponse {
+	response := MessageResponse{
+		ID:             msg.ID,
+		CreatedAt:      msg.CreatedAt,
+		ConversationID: msg.ConversationID,
+		Sender: ConversationUser{
+			ID:        msg.Sender.ID,
+			Username:  msg.Sender.Username,
+			AvatarURL: msg.Sender.AvatarURL,
+			IsCreator: msg.Sender.IsCreator,
+		},
+		Content:     msg.Content,
+		MessageType: msg.MessageType,
+		MediaURL:    msg.MediaURL,
+		TipAmount:   msg.TipAmount,
+		Price:       msg.Price,
+		UnlockedAt:  msg.UnlockedAt,
+		IsRead:      msg.IsRead,
+		ReadAt:      msg.ReadAt,
+		IsDeleted:   msg.IsDeleted,
+	}
+	// Le média n'est signé que pour les participants qui y ont accès, media_url contient sa clé S3
+	if msg.IsLockedFor(viewerID) {
+		response.MediaURL = ""
+		response.IsLocked = true
+	} else {
+		response.MediaURL = storage.SignedURL(msg.MediaURL)
+	}
+	return response
+}
+
+// messagePrice vérifie le prix de déblocage d'un média payant, et répond en cas d'échec :
+// seul un créateur relié à Stripe peut en fixer un, et un message texte n'a jamais de prix
+func messagePrice(c *gin.Context, input CreateMessageInput) (*float64, bool) {
+	if input.Price == nil || input.MessageType == MessageTypeText {
+		return nil, true
+	}
+
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	if *input.Price < minMessagePrice {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le prix doit être d'au moins %.2f€", minMessagePrice)})
+		logs.LogJSON("WARN", "Invalid message price", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"extra":  fmt.Sprintf("price : %.2f", *input.Price),
+		})
+		return nil, false
+	}
+
+	var sender user.User
+	if err := database.DB.First(&sender, "id = ?", userID).Error; err != nil || !sender.IsCreator || sender.StripeAccountID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Seuls les créateurs reliés à Stripe peuvent vendre un média"})
+		logs.LogJSON("WARN", "Paid message from non-creator", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return nil, false
+	}
+	return input.Price, true
+}
+
+// mediaFromUpload joint au message un fichier finalisé de l'expéditeur, et répond en cas d'échec
+func mediaFromUpload(c *gin.Context, uploadID string, messageType MessageType) (string, bool) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	file, err := upload.FindReady(uploadID, userID, upload.PurposeMessage)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fichier envoyé introuvable ou non finalisé"})
+		logs.LogJSON("WARN", "Message upload not ready", map[string]interface{}{
+			"route":    route,
+			"userID":   userID,
+			"uploadID": uploadID,
+		})
+		return "", false
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Key))
+	if !getValidExtensions(messageType)[ext] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Extension de fichier invalide"})
+		logs.LogJSON("ERROR", "Invalid file extension", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"extra":  fmt.Sprintf("Invalid file extension : %s", ext),
+		})
+		return "", false
+	}
+
+	if err := upload.Attach(file); err != nil {
+		if errors.Is(err, upload.ErrUploadNotReady) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ce fichier a déjà été utilisé"})
+			return "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'envoi du message"})
+		logs.LogJSON("ERROR", "Error attaching upload to message", map[string]interface{}{
+			"error":    err.Error(),
+			"route":    route,
+			"userID":   userID,
+			"uploadID": uploadID,
+		})
+		return "", false
+	}
+	return file.Key, true
+}
+
 func getValidExtensions(messageType MessageType) map[string]bool {
 	switch messageType {
 	case MessageTypeImage:
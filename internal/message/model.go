@@ -32,7 +32,10 @@ type Message struct {
 	Receiver       user.User    `json:"receiver" gorm:"foreignKey:ReceiverID"`
 	Content        string       `json:"content" gorm:"type:text"`
 	MessageType    MessageType  `json:"message_type" gorm:"default:'text'"`
-	MediaURL       string       `json:"media_url,omitempty"`
+	MediaURL       string       `json:"media_url,omitempty"`  // clé S3, signée dans les réponses
+	TipAmount      *float64     `json:"tip_amount,omitempty"` // montant du pourboire pour les messages de type tip
+	Price          *float64     `json:"price,omitempty"`      // prix de déblocage du média, nil si le média est gratuit
+	UnlockedAt     *time.Time   `json:"unlocked_at,omitempty"`
 	IsRead         bool         `json:"is_read" gorm:"default:false"`
 	ReadAt         *time.Time   `json:"read_at,omitempty"`
 	IsDeleted      bool         `json:"is_deleted" gorm:"default:false"`
@@ -48,13 +51,22 @@ const (
 	MessageTypeVideo MessageType = "video"
 	MessageTypeAudio MessageType = "audio"
 	MessageTypeFile  MessageType = "file"
+	MessageTypeTip   MessageType = "tip" // créé à la confirmation d'un pourboire, jamais envoyé directement
 )
 
+// IsLockedFor indique si le média du message est masqué pour cet utilisateur.
+// L'expéditeur voit toujours son média ; le destinataire doit l'acheter s'il est payant.
+func (m Message) IsLockedFor(viewerID string) bool {
+	return m.Price != nil && m.UnlockedAt == nil && viewerID != m.SenderID
+}
+
 // CreateMessageInput structure pour créer un nouveau message
 type CreateMessageInput struct {
 	ReceiverID  string      `json:"receiver_id" binding:"required"`
 	Content     string      `json:"content"`
 	MessageType MessageType `json:"message_type" binding:"required"`
+	UploadID    string      `json:"upload_id"` // fichier déjà envoyé directement sur S3
+	Price       *float64    `json:"price"`     // prix de déblocage d'un média payant
 }
 
 // ConversationResponse structure pour la réponse d'une conversation
@@ -85,6 +97,10 @@ type MessageResponse struct {
 	Content        string           `json:"content"`
 	MessageType    MessageType      `json:"message_type"`
 	MediaURL       string           `json:"media_url,omitempty"`
+	TipAmount      *float64         `json:"tip_amount,omitempty"`
+	Price          *float64         `json:"price,omitempty"`
+	IsLocked       bool             `json:"is_locked"` // média payant pas encore acheté : media_url est masquée
+	UnlockedAt     *time.Time       `json:"unlocked_at,omitempty"`
 	IsRead         bool             `json:"is_read"`
 	ReadAt         *time.Time       `json:"read_at,omitempty"`
 	IsDeleted      bool             `json:"is_deleted"`
@@ -0,0 +1,64 @@
+package message
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
+)
+
+// DeletedRetention est la durée de conservation d'un message supprimé avant sa purge définitive
+const DeletedRetention = 30 * 24 * time.Hour
+
+// purgeBatchSize limite le nombre de messages purgés par requête
+const purgeBatchSize = 500
+
+// PurgeDeleted supprime définitivement les messages supprimés depuis plus de DeletedRetention, avec leur média
+func PurgeDeleted() error {
+	cutoff := time.Now().Add(-DeletedRetention)
+	purged := 0
+
+	for {
+		var batch []Message
+		if err := database.DB.Select("id, media_url").
+			Where("is_deleted = true AND deleted_at < ?", cutoff).
+			Limit(purgeBatchSize).
+			Find(&batch).Error; err != nil {
+			return fmt.Errorf("récupération des messages supprimés : %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		ids := make([]string, 0, len(batch))
+		for _, msg := range batch {
+			ids = append(ids, msg.ID)
+			if msg.MediaURL != "" {
+				if err := storage.DeleteFromS3(msg.MediaURL); err != nil {
+					logs.LogJSON("WARN", "Error deleting purged message media", map[string]interface{}{
+						"error":     err.Error(),
+						"messageID": msg.ID,
+					})
+				}
+			}
+		}
+
+		if err := database.DB.Where("id IN ?", ids).Delete(&Message{}).Error; err != nil {
+			return fmt.Errorf("purge des messages supprimés : %w", err)
+		}
+		purged += len(ids)
+
+		if len(batch) < purgeBatchSize {
+			break
+		}
+	}
+
+	if purged > 0 {
+		logs.LogJSON("INFO", "Deleted messages purged", map[string]interface{}{
+			"count": purged,
+		})
+	}
+	return nil
+}
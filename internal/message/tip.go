@@ -0,0 +1,33 @@
+package message
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+)
+
+// SendTipMessage ajoute à la conversation entre le fan et le créateur le message signalant un pourboire confirmé
+func SendTipMessage(senderID, receiverID, content string, amount float64) (*MessageResponse, error) {
+	conversation, err := findOrCreateConversation(senderID, receiverID)
+	if err != nil {
+		return nil, fmt.Errorf("récupération de la conversation : %w", err)
+	}
+
+	message := Message{
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		ReceiverID:     receiverID,
+		Content:        content,
+		MessageType:    MessageTypeTip,
+		TipAmount:      &amount,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if err := database.DB.Create(&message).Error; err != nil {
+		return nil, fmt.Errorf("création du message de pourboire : %w", err)
+	}
+
+	response := deliver(conversation, &message)
+	return &response, nil
+}
@@ -0,0 +1,37 @@
+package message
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/realtime"
+)
+
+// minMessagePrice est le prix minimum d'un média payant envoyé en message privé
+const minMessagePrice = 1.0
+
+// UnlockMessage débloque le média payant d'un message pour son destinataire et le lui pousse en temps réel.
+// Retourne false si le message était déjà débloqué.
+func UnlockMessage(messageID, buyerID string) (bool, error) {
+	result := database.DB.Model(&Message{}).
+		Where("id = ? AND receiver_id = ? AND price IS NOT NULL AND unlocked_at IS NULL", messageID, buyerID).
+		Update("unlocked_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("déblocage du message %s : %w", messageID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	var message Message
+	if err := database.DB.Preload("Sender").First(&message, "id = ?", messageID).Error; err != nil {
+		return true, fmt.Errorf("récupération du message %s : %w", messageID, err)
+	}
+
+	realtime.Publish(realtime.Event{
+		Type: realtime.EventMessageUnlocked,
+		Data: toMessageResponse(message, buyerID),
+	}, message.SenderID, buyerID)
+	return true, nil
+}
@@ -0,0 +1,175 @@
+package message
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/realtime"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// L'authentification se fait par token et non par cookie : l'origine n'a pas besoin d'être restreinte
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// clientEvent est un événement envoyé par le client sur la WebSocket
+type clientEvent struct {
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id"`
+	IsTyping       bool   `json:"is_typing"`
+}
+
+// TypingEvent est poussé à l'autre participant quand un utilisateur écrit
+type TypingEvent struct {
+	ConversationID string `json:"conversation_id"`
+	UserID         string `json:"user_id"`
+	IsTyping       bool   `json:"is_typing"`
+}
+
+// ReadEvent est poussé quand des messages sont lus
+type ReadEvent struct {
+	ConversationID string    `json:"conversation_id"`
+	MessageID      string    `json:"message_id,omitempty"`
+	ReaderID       string    `json:"reader_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// DeletedEvent est poussé quand un message est supprimé
+type DeletedEvent struct {
+	ConversationID string    `json:"conversation_id"`
+	MessageID      string    `json:"message_id"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// ServeWS GET /api/messages/ws
+func ServeWS(c *gin.Context) {
+	userID := c.GetString("user_id")
+	route := c.FullPath()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade a déjà répondu au client
+		logs.LogJSON("WARN", "WebSocket upgrade failed", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	events, unsubscribe := realtime.Subscribe(userID)
+	defer unsubscribe()
+
+	logs.LogJSON("INFO", "WebSocket connected", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+	})
+
+	done := make(chan struct{})
+	go readPump(conn, userID, done)
+	writePump(conn, events, done)
+
+	logs.LogJSON("INFO", "WebSocket disconnected", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+	})
+}
+
+// writePump pousse les événements du broker vers le client et entretient la connexion
+func writePump(conn *websocket.Conn, events <-chan realtime.Event, done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// readPump lit les événements envoyés par le client (indicateurs de saisie)
+func readPump(conn *websocket.Conn, userID string, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var event clientEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case realtime.EventTyping:
+			otherUserID, err := getOtherParticipant(event.ConversationID, userID)
+			if err != nil {
+				continue
+			}
+			realtime.Publish(realtime.Event{
+				Type: realtime.EventTyping,
+				Data: TypingEvent{
+					ConversationID: event.ConversationID,
+					UserID:         userID,
+					IsTyping:       event.IsTyping,
+				},
+			}, otherUserID)
+		}
+	}
+}
+
+// getOtherParticipant retourne l'autre participant d'une conversation dont l'utilisateur fait partie
+func getOtherParticipant(conversationID, userID string) (string, error) {
+	var conversation Conversation
+	if err := database.DB.
+		Where("id = ? AND (user1_id = ? OR user2_id = ?)", conversationID, userID, userID).
+		First(&conversation).Error; err != nil {
+		return "", err
+	}
+
+	if conversation.User1ID == userID {
+		return conversation.User2ID, nil
+	}
+	return conversation.User1ID, nil
+}
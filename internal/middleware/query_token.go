@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenFromQuery permet aux clients WebSocket, qui ne peuvent pas envoyer d'en-tête Authorization,
+// de transmettre leur token via ?access_token= avant AuthMiddleware.
+// Le refresh token n'est jamais lu dans l'URL, et l'access token est masqué du journal d'accès par LoggedPath.
+// Le rafraîchissement est désactivé : le nouveau token d'une réponse 101 n'est pas lisible par un navigateur. Un token expiré reçoit donc un 401, le client rafraîchit puis se reconnecte.
+func TokenFromQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Request.Header.Del("X-Refresh-Token")
+		c.Next()
+	}
+}
+
+// LoggedPath retourne le chemin et les paramètres de la requête pour le journal d'accès,
+// avec le token transmis via ?access_token= masqué
+func LoggedPath(req *http.Request) string {
+	if req.URL.RawQuery == "" {
+		return req.URL.Path
+	}
+	query := req.URL.Query()
+	if !query.Has("access_token") {
+		return req.URL.Path + "?" + req.URL.RawQuery
+	}
+	query.Set("access_token", "redacted")
+	return req.URL.Path + "?" + query.Encode()
+}
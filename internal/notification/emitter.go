@@ -0,0 +1,126 @@
+package notification
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/realtime"
+)
+
+// EventNotificationNew est l'événement temps réel poussé au destinataire
+const EventNotificationNew = "notification.new"
+
+// Emitter reçoit les événements produits par les handlers.
+// Les tests peuvent le remplacer par un Recorder via SetEmitter.
+type Emitter interface {
+	Emit(event Event)
+}
+
+var emitter Emitter = DBEmitter{}
+
+// SetEmitter remplace l'émetteur utilisé par l'application et retourne le précédent
+func SetEmitter(e Emitter) Emitter {
+	previous := emitter
+	emitter = e
+	return previous
+}
+
+// Emit transmet un événement à l'émetteur courant. On ne notifie jamais un utilisateur de ses propres actions.
+func Emit(event Event) {
+	if event.RecipientID == "" || event.RecipientID == event.ActorID {
+		return
+	}
+	emitter.Emit(event)
+}
+
+// DBEmitter enregistre les notifications en base en regroupant les événements
+// identiques (même destinataire, type et cible) tant que la notification n'est pas lue
+type DBEmitter struct{}
+
+func (DBEmitter) Emit(event Event) {
+	notification, err := store(event)
+	if err != nil {
+		logs.LogJSON("ERROR", "Error storing notification", map[string]interface{}{
+			"error":    err.Error(),
+			"userID":   event.RecipientID,
+			"actorID":  event.ActorID,
+			"type":     event.Type,
+			"targetID": event.TargetID,
+		})
+		return
+	}
+	if notification != nil {
+		realtime.Publish(realtime.Event{Type: EventNotificationNew, Data: notification}, event.RecipientID)
+	}
+}
+
+// store crée ou met à jour la notification regroupée ; elle retourne nil si l'acteur y figurait déjà.
+// La notification est insérée avec ON CONFLICT sur l'index unique des notifications non lues :
+// deux premiers événements concurrents aboutissent à la même ligne au lieu d'en perdre un.
+func store(event Event) (*Notification, error) {
+	notification := Notification{
+		UserID:     event.RecipientID,
+		Type:       event.Type,
+		TargetID:   event.TargetID,
+		ActorID:    event.ActorID,
+		ActorCount: 1,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	changed := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// La mise à jour sans effet verrouille la notification existante et la retourne
+		err := tx.Clauses(
+			clause.OnConflict{
+				Columns:     []clause.Column{{Name: "user_id"}, {Name: "type"}, {Name: "target_id"}},
+				TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "is_read = false"}}},
+				DoUpdates:   clause.Assignments(map[string]interface{}{"user_id": gorm.Expr("excluded.user_id")}),
+			},
+			clause.Returning{},
+		).Create(&notification).Error
+		if err != nil {
+			return err
+		}
+
+		// Un acteur déjà compté (ex : like, unlike puis like) ne change pas la notification
+		added := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&NotificationActor{NotificationID: notification.ID, ActorID: event.ActorID, CreatedAt: time.Now()})
+		if added.Error != nil {
+			return added.Error
+		}
+		if added.RowsAffected == 0 {
+			return nil
+		}
+		changed = true
+
+		// Le nombre d'acteurs est recompté, que la notification vienne d'être créée ou non
+		notification.ActorID = event.ActorID
+		notification.UpdatedAt = time.Now()
+		return tx.Model(&notification).Clauses(clause.Returning{Columns: []clause.Column{{Name: "actor_count"}}}).Updates(map[string]interface{}{
+			"actor_id":    notification.ActorID,
+			"actor_count": gorm.Expr("(SELECT COUNT(*) FROM notification_actors WHERE notification_id = ?)", notification.ID),
+			"updated_at":  notification.UpdatedAt,
+		}).Error
+	})
+	if err != nil || !changed {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// Recorder est un émetteur en mémoire qui conserve les événements, utile dans les tests
+type Recorder struct {
+	mu     sync.Mutex
+	Events []Event
+}
+
+func (r *Recorder) Emit(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Events = append(r.Events, event)
+}
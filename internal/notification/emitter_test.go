@@ -0,0 +1,105 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestEmit(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		expected int
+	}{
+		{
+			name:     "Like d'un autre utilisateur",
+			event:    Event{RecipientID: "author", ActorID: "fan", Type: TypeLike, TargetID: "post1"},
+			expected: 1,
+		},
+		{
+			name:     "Action sur son propre contenu",
+			event:    Event{RecipientID: "author", ActorID: "author", Type: TypeLike, TargetID: "post1"},
+			expected: 0,
+		},
+		{
+			name:     "Destinataire inconnu",
+			event:    Event{RecipientID: "", ActorID: "fan", Type: TypeComment, TargetID: "post1"},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &Recorder{}
+			previous := SetEmitter(recorder)
+			defer SetEmitter(previous)
+
+			Emit(tt.event)
+
+			assert.Len(t, recorder.Events, tt.expected)
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	event := Event{RecipientID: "author", ActorID: "fan", Type: TypeLike, TargetID: "post1"}
+	columns := []string{"id", "user_id", "type", "target_id", "actor_id", "actor_count", "is_read"}
+	upsert := `INSERT INTO "notifications" .* ON CONFLICT \("user_id","type","target_id"\) WHERE is_read = false DO UPDATE SET "user_id"=excluded.user_id RETURNING \*`
+
+	t.Run("Première notification", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(upsert).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("n1", "author", "like", "post1", "fan", 1, false))
+		mock.ExpectExec("INSERT INTO \"notification_actors\".*ON CONFLICT DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("UPDATE \"notifications\" SET .*SELECT COUNT.* RETURNING \"actor_count\"").
+			WillReturnRows(sqlmock.NewRows([]string{"actor_count"}).AddRow(1))
+		mock.ExpectCommit()
+
+		notification, err := store(event)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, notification)
+		assert.Equal(t, "n1", notification.ID)
+		assert.Equal(t, 1, notification.ActorCount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nouvel acteur regroupé", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(upsert).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("n1", "author", "like", "post1", "other", 1, false))
+		mock.ExpectExec("INSERT INTO \"notification_actors\".*ON CONFLICT DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("UPDATE \"notifications\" SET .*SELECT COUNT.* RETURNING \"actor_count\"").
+			WillReturnRows(sqlmock.NewRows([]string{"actor_count"}).AddRow(2))
+		mock.ExpectCommit()
+
+		notification, err := store(event)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, notification)
+		assert.Equal(t, 2, notification.ActorCount)
+		assert.Equal(t, "fan", notification.ActorID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Acteur déjà compté", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(upsert).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("n1", "author", "like", "post1", "fan", 1, false))
+		mock.ExpectExec("INSERT INTO \"notification_actors\".*ON CONFLICT DO NOTHING").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		notification, err := store(event)
+
+		assert.NoError(t, err)
+		assert.Nil(t, notification)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,159 @@
+package notification
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
+)
+
+// GetNotifications GET /api/notifications
+func GetNotifications(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	query := database.DB.Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("is_read = false")
+	}
+
+	// Les notifications regroupées remontent à chaque nouvel acteur : tri par date de mise à jour
+	var notifications []Notification
+	if err := query.
+		Preload("Actor").
+		Scopes(page.Scope("notifications.updated_at", "notifications.id")).
+		Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des notifications"})
+		logs.LogJSON("ERROR", "Error retrieving notifications", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	notifications, envelope := pagination.Paginate(page, notifications, func(n Notification) (time.Time, string) {
+		return n.UpdatedAt, n.ID
+	})
+
+	var unreadCount int64
+	database.DB.Model(&Notification{}).Where("user_id = ? AND is_read = false", userID).Count(&unreadCount)
+
+	response := make([]NotificationResponse, 0, len(notifications))
+	for _, n := range notifications {
+		response = append(response, NotificationResponse{
+			ID:        n.ID,
+			CreatedAt: n.CreatedAt,
+			UpdatedAt: n.UpdatedAt,
+			Type:      n.Type,
+			TargetID:  n.TargetID,
+			Actor: NotificationActorResponse{
+				ID:        n.Actor.ID,
+				Username:  n.Actor.Username,
+				AvatarURL: n.Actor.AvatarURL,
+			},
+			ActorCount: n.ActorCount,
+			IsRead:     n.IsRead,
+			ReadAt:     n.ReadAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": response,
+		"unread_count":  unreadCount,
+		"pagination":    envelope,
+	})
+	logs.LogJSON("INFO", "Notifications retrieved successfully", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+	})
+}
+
+// MarkNotificationAsRead PUT /api/notifications/:id/read
+func MarkNotificationAsRead(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	notificationID := c.Param("id")
+
+	var notification Notification
+	if err := database.DB.
+		Where("id = ? AND user_id = ?", notificationID, userID).
+		First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification non trouvée"})
+		logs.LogJSON("WARN", "Notification not found", map[string]interface{}{
+			"route":          route,
+			"userID":         userID,
+			"notificationID": notificationID,
+		})
+		return
+	}
+
+	if !notification.IsRead {
+		if err := database.DB.Model(&notification).Updates(map[string]interface{}{
+			"is_read": true,
+			"read_at": time.Now(),
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour de la notification"})
+			logs.LogJSON("ERROR", "Error during notification read update", map[string]interface{}{
+				"error":          err.Error(),
+				"route":          route,
+				"userID":         userID,
+				"notificationID": notificationID,
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marquée comme lue"})
+	logs.LogJSON("INFO", "Notification marked as read", map[string]interface{}{
+		"route":          route,
+		"userID":         userID,
+		"notificationID": notificationID,
+	})
+}
+
+// MarkAllNotificationsAsRead PUT /api/notifications/read-all
+func MarkAllNotificationsAsRead(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	result := database.DB.Model(&Notification{}).
+		Where("user_id = ? AND is_read = false", userID).
+		Updates(map[string]interface{}{
+			"is_read": true,
+			"read_at": time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour des notifications"})
+		logs.LogJSON("ERROR", "Error during notifications read update", map[string]interface{}{
+			"error":  result.Error.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notifications marquées comme lues",
+		"updated": result.RowsAffected,
+	})
+	logs.LogJSON("INFO", "All notifications marked as read", map[string]interface{}{
+		"route":   route,
+		"userID":  userID,
+		"updated": result.RowsAffected,
+	})
+}
@@ -0,0 +1,83 @@
+package notification
+
+import "time"
+
+// NotificationType définit les types de notifications possibles
+type NotificationType string
+
+const (
+	TypeLike          NotificationType = "like"
+	TypeComment       NotificationType = "comment"
+	TypeFollow        NotificationType = "follow"
+	TypeSubscription  NotificationType = "subscription"
+	TypeMessage       NotificationType = "message"
+	TypePriceChange   NotificationType = "price_change"
+	TypeUnlock        NotificationType = "unlock"
+	TypeTip           NotificationType = "tip"
+	TypeMessageUnlock NotificationType = "message_unlock"
+	TypeGift          NotificationType = "gift"
+	TypeGiftExpired   NotificationType = "gift_expired"
+)
+
+// Notification représente une notification, éventuellement regroupée
+// (ex : "12 personnes ont aimé votre post" tant qu'elle n'est pas lue)
+type Notification struct {
+	ID         string           `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	UserID     string           `json:"user_id" gorm:"index"`
+	Type       NotificationType `json:"type"`
+	TargetID   string           `json:"target_id"`
+	ActorID    string           `json:"actor_id"`
+	Actor      Actor            `json:"-" gorm:"foreignKey:ActorID"`
+	ActorCount int              `json:"actor_count" gorm:"default:1"`
+	IsRead     bool             `json:"is_read" gorm:"default:false"`
+	ReadAt     *time.Time       `json:"read_at,omitempty"`
+}
+
+// Actor est la vue réduite d'un utilisateur affichée dans une notification.
+// Le paquet user n'est pas importé pour qu'il puisse lui-même émettre des notifications.
+type Actor struct {
+	ID        string
+	Username  string
+	AvatarURL string
+}
+
+func (Actor) TableName() string {
+	return "users"
+}
+
+// NotificationActor enregistre les acteurs distincts d'une notification regroupée
+type NotificationActor struct {
+	NotificationID string `gorm:"primaryKey"`
+	ActorID        string `gorm:"primaryKey"`
+	CreatedAt      time.Time
+}
+
+// Event est un événement émis par les handlers pour produire une notification
+type Event struct {
+	RecipientID string
+	ActorID     string
+	Type        NotificationType
+	TargetID    string
+}
+
+// NotificationActorResponse structure pour le dernier acteur d'une notification
+type NotificationActorResponse struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// NotificationResponse structure pour la réponse d'une notification
+type NotificationResponse struct {
+	ID         string                    `json:"id"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	UpdatedAt  time.Time                 `json:"updated_at"`
+	Type       NotificationType          `json:"type"`
+	TargetID   string                    `json:"target_id"`
+	Actor      NotificationActorResponse `json:"actor"`
+	ActorCount int                       `json:"actor_count"`
+	IsRead     bool                      `json:"is_read"`
+	ReadAt     *time.Time                `json:"read_at,omitempty"`
+}
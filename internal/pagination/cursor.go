@@ -0,0 +1,144 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor est retournée quand le curseur fourni ne peut pas être décodé
+var ErrInvalidCursor = errors.New("curseur de pagination invalide")
+
+// Cursor identifie une position dans une liste triée par (created_at, id)
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"i"`
+	// Backward indique que le curseur remonte vers les éléments plus récents
+	Backward bool `json:"b,omitempty"`
+}
+
+// Encode retourne la forme opaque du curseur, transmise telle quelle au client
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode reconstruit un curseur à partir de sa forme opaque
+func Decode(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == "" || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Page regroupe les paramètres de pagination d'une requête
+type Page struct {
+	Limit  int
+	Cursor *Cursor
+}
+
+// Envelope est l'objet "pagination" renvoyé par toutes les routes de liste
+type Envelope struct {
+	NextCursor *string `json:"next_cursor"`
+	PrevCursor *string `json:"prev_cursor"`
+	Limit      int     `json:"limit"`
+	Total      *int64  `json:"total,omitempty"` // nombre total d'éléments, pour les listes qui le calculent
+}
+
+// FromRequest lit les paramètres ?limit= et ?cursor= de la requête
+func FromRequest(c *gin.Context, defaultLimit int) (Page, error) {
+	page := Page{Limit: defaultLimit}
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			page.Limit = parsed
+		}
+	}
+	if page.Limit > MaxLimit {
+		page.Limit = MaxLimit
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := Decode(token)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = cursor
+	}
+
+	return page, nil
+}
+
+// Scope est un scope GORM qui applique le filtre keyset, le tri (du plus récent au plus ancien)
+// et la limite sur les colonnes données. Une ligne supplémentaire est demandée pour savoir s'il reste des éléments.
+func (p Page) Scope(timeColumn, idColumn string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		direction := "DESC"
+		if p.Cursor != nil {
+			if p.Cursor.Backward {
+				direction = "ASC"
+				db = db.Where(fmt.Sprintf("(%s, %s) > (?, ?)", timeColumn, idColumn), p.Cursor.CreatedAt, p.Cursor.ID)
+			} else {
+				db = db.Where(fmt.Sprintf("(%s, %s) < (?, ?)", timeColumn, idColumn), p.Cursor.CreatedAt, p.Cursor.ID)
+			}
+		}
+		return db.
+			Order(fmt.Sprintf("%s %s, %s %s", timeColumn, direction, idColumn, direction)).
+			Limit(p.Limit + 1)
+	}
+}
+
+// Paginate tronque les résultats d'une requête passée par Scope, les remet dans l'ordre
+// du plus récent au plus ancien et calcule les curseurs suivant et précédent
+func Paginate[T any](p Page, items []T, key func(T) (time.Time, string)) ([]T, Envelope) {
+	envelope := Envelope{Limit: p.Limit}
+
+	hasMore := len(items) > p.Limit
+	if hasMore {
+		items = items[:p.Limit]
+	}
+
+	backward := p.Cursor != nil && p.Cursor.Backward
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if len(items) == 0 {
+		return items, envelope
+	}
+
+	// Éléments plus anciens : s'il en reste, ou si l'on vient de remonter depuis eux
+	if hasMore || backward {
+		createdAt, id := key(items[len(items)-1])
+		next := Cursor{CreatedAt: createdAt, ID: id}.Encode()
+		envelope.NextCursor = &next
+	}
+
+	// Éléments plus récents : si l'on est parti d'un curseur, ou s'il en reste en remontant
+	if (p.Cursor != nil && !backward) || (backward && hasMore) {
+		createdAt, id := key(items[0])
+		prev := Cursor{CreatedAt: createdAt, ID: id, Backward: true}.Encode()
+		envelope.PrevCursor = &prev
+	}
+
+	return items, envelope
+}
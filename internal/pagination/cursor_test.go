@@ -0,0 +1,126 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+func itemKey(i item) (time.Time, string) {
+	return i.CreatedAt, i.ID
+}
+
+func TestDecode(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2025, 6, 1, 12, 0, 0, 123456000, time.UTC), ID: "post1", Backward: true}
+
+	tests := []struct {
+		name          string
+		token         string
+		expected      *Cursor
+		expectedError bool
+	}{
+		{
+			name:          "Valid cursor",
+			token:         cursor.Encode(),
+			expected:      &cursor,
+			expectedError: false,
+		},
+		{
+			name:          "Not base64",
+			token:         "%%%",
+			expected:      nil,
+			expectedError: true,
+		},
+		{
+			name:          "Missing id",
+			token:         Cursor{CreatedAt: cursor.CreatedAt}.Encode(),
+			expected:      nil,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Decode(tt.token)
+
+			if tt.expectedError {
+				assert.ErrorIs(t, err, ErrInvalidCursor)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, tt.expected.CreatedAt.Equal(result.CreatedAt))
+				assert.Equal(t, tt.expected.ID, result.ID)
+				assert.Equal(t, tt.expected.Backward, result.Backward)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := item{ID: "a", CreatedAt: now}
+	b := item{ID: "b", CreatedAt: now.Add(-time.Minute)}
+	c := item{ID: "c", CreatedAt: now.Add(-2 * time.Minute)}
+
+	tests := []struct {
+		name         string
+		page         Page
+		rows         []item
+		expectedIDs  []string
+		expectedNext bool
+		expectedPrev bool
+	}{
+		{
+			name:         "First page with more rows",
+			page:         Page{Limit: 2},
+			rows:         []item{a, b, c},
+			expectedIDs:  []string{"a", "b"},
+			expectedNext: true,
+			expectedPrev: false,
+		},
+		{
+			name:         "Last page after a forward cursor",
+			page:         Page{Limit: 2, Cursor: &Cursor{CreatedAt: a.CreatedAt, ID: a.ID}},
+			rows:         []item{b, c},
+			expectedIDs:  []string{"b", "c"},
+			expectedNext: false,
+			expectedPrev: true,
+		},
+		{
+			name:         "Backward cursor returns rows newest first",
+			page:         Page{Limit: 2, Cursor: &Cursor{CreatedAt: c.CreatedAt, ID: c.ID, Backward: true}},
+			rows:         []item{b, a},
+			expectedIDs:  []string{"a", "b"},
+			expectedNext: true,
+			expectedPrev: false,
+		},
+		{
+			name:         "Empty page",
+			page:         Page{Limit: 2},
+			rows:         []item{},
+			expectedIDs:  nil,
+			expectedNext: false,
+			expectedPrev: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, envelope := Paginate(tt.page, tt.rows, itemKey)
+
+			var ids []string
+			for _, i := range items {
+				ids = append(ids, i.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, tt.expectedNext, envelope.NextCursor != nil)
+			assert.Equal(t, tt.expectedPrev, envelope.PrevCursor != nil)
+			assert.Equal(t, tt.page.Limit, envelope.Limit)
+		})
+	}
+}
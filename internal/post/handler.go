@@ -2,20 +2,27 @@ package post
 
 import (
 	"fmt"
+	"mime/multipart"
 	"net/http"
-	"path/filepath"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
 
+// minUnlockPrice est le prix minimum d'un déblocage à l'unité, en dessous duquel les frais Stripe l'emportent
+const minUnlockPrice = 1.0
+
 // CreatePost gère la création d'un nouveau post avec média
 func CreatePost(c *gin.Context) {
 	route := c.FullPath()
@@ -52,6 +59,39 @@ func CreatePost(c *gin.Context) {
 		isPaid = false
 	}
 
+	// Prix de déblocage à l'unité, uniquement pour un post payant
+	var unlockPrice *float64
+	if unlockPriceStr := c.PostForm("unlock_price"); unlockPriceStr != "" && isPaid {
+		price, err := strconv.ParseFloat(unlockPriceStr, 64)
+		if err != nil || price < minUnlockPrice {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le prix de déblocage doit être d'au moins %.2f €", minUnlockPrice)})
+			logs.LogJSON("WARN", "Invalid unlock price", map[string]interface{}{
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("unlock_price : %s", unlockPriceStr),
+			})
+			return
+		}
+		unlockPrice = &price
+	}
+
+	// Palier minimum exigé, parmi les paliers actifs du créateur
+	minTierRank := 0
+	if tierID := c.PostForm("tier_id"); tierID != "" && isPaid {
+		tier, err := billing.FindActiveTier(u.ID, tierID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Palier introuvable"})
+			logs.LogJSON("WARN", "Invalid post tier", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("tier_id : %s", tierID),
+			})
+			return
+		}
+		minTierRank = tier.Rank
+	}
+
 	// Vérification des champs obligatoires
 	if title == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Le titre est obligatoire"})
@@ -62,27 +102,45 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 
-	// Upload du média
-	file, header, err := c.Request.FormFile("media")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Aucun média fourni", "details": err.Error()})
-		logs.LogJSON("WARN", "No media supplied", map[string]interface{}{
+	// Publication immédiate par défaut, brouillon ou publication programmée sur demande
+	now := time.Now()
+	status, publishAt, message := parsePublication(c.PostForm("status"), c.PostForm("publish_at"), now)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		logs.LogJSON("WARN", "Invalid publication schedule", map[string]interface{}{
 			"route":  route,
 			"userID": userID,
+			"extra":  fmt.Sprintf("status : %s, publish_at : %s", c.PostForm("status"), c.PostForm("publish_at")),
 		})
 		return
 	}
-	defer file.Close()
 
-	// Validation du type de fichier
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	validExtensions := map[string]bool{
-		".jpg": true, ".jpeg": true, ".png": true,
-		".gif": true, ".webp": true, ".heic": true,
-		".mp4": true, ".mov": true, ".avi": true,
+	// Médias du carrousel, dans l'ordre d'envoi. Un brouillon peut être créé sans média,
+	// ils sont alors ajoutés par envoi direct sur S3
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["media"]
+	}
+	if len(files) == 0 && status != access.StatusDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Aucun média fourni"})
+		logs.LogJSON("WARN", "No media supplied", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+	if len(files) > MaxMediaPerPost {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Un post ne peut pas contenir plus de %d médias", MaxMediaPerPost)})
+		logs.LogJSON("WARN", "Too many media", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"extra":  fmt.Sprintf("media count : %d", len(files)),
+		})
+		return
 	}
 
-	if !validExtensions[ext] {
+	// Validation du type de fichier
+	if ext := invalidMediaExtension(files); ext != "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Extension de fichier invalide"})
 		logs.LogJSON("WARN", "Invalid file extension", map[string]interface{}{
 			"route":  route,
@@ -92,39 +150,52 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 
-	// Génération d'un nom de fichier unique
-	postID := uuid.New().String()
-	filename := fmt.Sprintf("post_%s%s", postID, ext)
-	contentType := header.Header.Get("Content-Type")
+	// Médias visibles par tous sur un post payant, désignés par leur position
+	previews, message := parsePreviews(c.PostFormArray("preview"), len(files))
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		logs.LogJSON("WARN", "Invalid media preview", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
 
-	// Upload du fichier vers S3
-	url, err := storage.UploadToS3(file, filename, contentType, "posts")
+	// Upload des fichiers vers S3, ceux déjà envoyés sont supprimés en cas d'échec
+	postID := uuid.New().String()
+	media, err := uploadMedia(postID, files, previews, isPaid)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'upload S3", "details": err.Error()})
 		logs.LogJSON("ERROR", "S3 upload error", map[string]interface{}{
+			"error":  err.Error(),
 			"route":  route,
 			"userID": userID,
 		})
 		return
 	}
 
-	// Création du post en base de données
+	// Création du post et de ses médias en base de données
 	newPost := Post{
 		ID:          postID,
-		CreatedAt:   time.Now(),
+		CreatedAt:   now,
 		UserID:      userID.(string),
 		Title:       title,
 		Description: description,
-		MediaURL:    url,
+		MediaURL:    coverURL(media),
 		IsPaid:      isPaid,
+		UnlockPrice: unlockPrice,
+		MinTierRank: minTierRank,
+		Status:      status,
+		PublishAt:   publishAt,
+		Media:       media,
+	}
+	if status == access.StatusPublished {
+		newPost.PublishedAt = publishAt
 	}
 
 	if err := database.DB.Create(&newPost).Error; err != nil {
-		// Si l'insertion en BDD échoue, on tente de supprimer le fichier déjà uploadé
-		urlParts := strings.Split(url, ".amazonaws.com/")
-		if len(urlParts) > 1 {
-			_ = storage.DeleteFromS3(urlParts[1]) // On ignore l'erreur ici
-		}
+		// Si l'insertion en BDD échoue, on tente de supprimer les fichiers déjà uploadés
+		deleteMediaItems(media)
 
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du post"})
 		logs.LogJSON("ERROR", "Error when creating post", map[string]interface{}{
@@ -137,7 +208,7 @@ func CreatePost(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Post créé avec succès",
-		"post":    newPost,
+		"post":    SignPost(newPost),
 	})
 	logs.LogJSON("INFO", "Post created successfully", map[string]interface{}{
 		"postID": postID,
@@ -159,8 +230,33 @@ func GetUserPosts(c *gin.Context) {
 		return
 	}
 
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	// L'auteur voit tous ses posts, éventuellement filtrés par statut (brouillons, programmés...)
+	query := database.DB.Scopes(WithMedia).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		if !isPostStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Statut de publication invalide"})
+			logs.LogJSON("WARN", "Invalid post status filter", map[string]interface{}{
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("status : %s", status),
+			})
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
 	var posts []Post
-	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&posts).Error; err != nil {
+	if err := query.Scopes(page.Scope("posts.created_at", "posts.id")).Find(&posts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération de ces propres posts"})
 		logs.LogJSON("ERROR", "Error retrieving own posts", map[string]interface{}{
 			"route":  route,
@@ -169,8 +265,11 @@ func GetUserPosts(c *gin.Context) {
 		return
 	}
 
+	posts, envelope := pagination.Paginate(page, posts, postCursorKey)
+
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"posts":      SignPosts(posts),
+		"pagination": envelope,
 	})
 	logs.LogJSON("INFO", "Own posts fetched successfully", map[string]interface{}{
 		"route":  route,
@@ -188,7 +287,7 @@ func GetAllPosts(c *gin.Context) {
 	// Paramètre de requête pour filtrer par contenu payant/gratuit
 	showPaywalled := c.Query("paywalled") == "true"
 
-	query := database.DB.Order("created_at DESC")
+	query := database.DB.Order("published_at DESC").Scopes(access.PublishedPosts(""), WithMedia)
 
 	// Filtrer les posts selon les règles d'accès
 	if !showPaywalled || !userLoggedIn {
@@ -196,9 +295,8 @@ func GetAllPosts(c *gin.Context) {
 		query = query.Where("is_paid = ?", false)
 	} else {
 		// Utilisateur connecté qui veut voir du contenu payant:
-		// Montrer les posts gratuits et ses propres posts payants
-		// Note: pour un système d'abonnement complet, vous devriez ajouter une vérification ici
-		query = query.Where("is_paid = ? OR (is_paid = ? AND user_id = ?)", false, true, userID)
+		// Montrer les posts gratuits, ses propres posts payants et ceux des créateurs auxquels il est abonné
+		query = query.Scopes(access.VisiblePosts(userID.(string)))
 	}
 
 	var posts []Post
@@ -211,8 +309,9 @@ func GetAllPosts(c *gin.Context) {
 		return
 	}
 
+	// La requête ne retourne que des posts visibles par l'utilisateur
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"posts": SignPosts(posts),
 	})
 	logs.LogJSON("INFO", "Own posts fetched successfully", map[string]interface{}{
 		"route":  route,
@@ -225,10 +324,10 @@ func GetPostByID(c *gin.Context) {
 	route := c.FullPath()
 
 	postID := c.Param("id")
-	userID, exists := c.Get("user_id")
+	userID := c.GetString("user_id")
 
 	var post Post
-	if err := database.DB.First(&post, "id = ?", postID).Error; err != nil {
+	if err := database.DB.Scopes(WithMedia).First(&post, "id = ?", postID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
 		logs.LogJSON("WARN", "Post not found", map[string]interface{}{
 			"postID": postID,
@@ -239,22 +338,12 @@ func GetPostByID(c *gin.Context) {
 	}
 
 	// Vérification si l'utilisateur a accès au post s'il est payant
-	if post.IsPaid {
-		if !exists || post.UserID != userID.(string) {
-			// Ici, vous pourriez implémenter une vérification d'abonnement
-			// Pour l'instant, seul le créateur peut voir son propre post payant
-			c.JSON(http.StatusForbidden, gin.H{"error": "Accès non autorisé à ce contenu premium"})
-			logs.LogJSON("WARN", "Unauthorized access to premium content", map[string]interface{}{
-				"postID": postID,
-				"route":  route,
-				"userID": userID,
-			})
-			return
-		}
+	if !checkPostAccess(c, post, c.GetString("user_id")) {
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"post": post,
+		"post": SignPost(post),
 	})
 	logs.LogJSON("INFO", "Post fetched successfully", map[string]interface{}{
 		"postID": postID,
@@ -281,7 +370,7 @@ func DeletePost(c *gin.Context) {
 
 	// Vérifier que le post existe et appartient à l'utilisateur
 	var post Post
-	if err := database.DB.First(&post, "id = ? AND user_id = ?", postID, userID).Error; err != nil {
+	if err := database.DB.Scopes(WithMedia).First(&post, "id = ? AND user_id = ?", postID, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé ou vous n'êtes pas autorisé à le supprimer"})
 		logs.LogJSON("WARN", "Post not found or you are not authorized to delete it", map[string]interface{}{
 			"postID": postID,
@@ -291,22 +380,29 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
-	// Extraire la clé du média de l'URL pour le supprimer de S3
+	// Autres médias du carrousel et anciens médias conservés pour l'historique des modifications
+	otherMedia, err := secondaryMediaURLs(post)
+	if err != nil {
+		logs.LogJSON("WARN", "Error retrieving revision media", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+	}
+
+	// Supprimer le média principal de S3, posts.media_url contient sa clé
 	if post.MediaURL != "" {
-		urlParts := strings.Split(post.MediaURL, ".amazonaws.com/")
-		if len(urlParts) > 1 {
-			mediaKey := urlParts[1]
-			if err := storage.DeleteFromS3(mediaKey); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Erreur lors de la suppression du média sur S3: %v\n", err)})
-				logs.LogJSON("ERROR", "Error deleting media on S3", map[string]interface{}{
-					"error":  err.Error(),
-					"postID": postID,
-					"route":  route,
-					"userID": userID,
-					"extra":  fmt.Sprintf("Error deleting media on S3 : %s", post.MediaURL),
-				})
-				return
-			}
+		if err := storage.DeleteFromS3(post.MediaURL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Erreur lors de la suppression du média sur S3: %v\n", err)})
+			logs.LogJSON("ERROR", "Error deleting media on S3", map[string]interface{}{
+				"error":  err.Error(),
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("Error deleting media on S3 : %s", post.MediaURL),
+			})
+			return
 		}
 	}
 
@@ -323,6 +419,11 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
+	// Les médias et révisions sont supprimés avec le post, leurs fichiers ne sont plus référencés
+	for _, mediaURL := range otherMedia {
+		deleteMedia(mediaURL)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Post supprimé avec succès",
 	})
@@ -337,7 +438,7 @@ func GetCommentsByPostID(c *gin.Context) {
 	route := c.FullPath()
 
 	postID := c.Param("id")
-	userID, exists := c.Get("user_id")
+	userID := c.GetString("user_id")
 
 	// Vérifier que le post existe
 	var post Post
@@ -352,20 +453,23 @@ func GetCommentsByPostID(c *gin.Context) {
 	}
 
 	// Vérifier l'accès si le post est payant
-	if post.IsPaid {
-		if !exists || post.UserID != userID.(string) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Accès non autorisé à ce contenu premium"})
-			logs.LogJSON("WARN", "Unauthorized access to premium content", map[string]interface{}{
-				"postID": postID,
-				"route":  route,
-				"userID": userID,
-			})
-			return
-		}
+	if !checkPostAccess(c, post, userID) {
+		return
+	}
+
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return
 	}
 
 	var comments []Comment
-	if err := database.DB.Where("post_id = ?", postID).Order("created_at desc").Find(&comments).Error; err != nil {
+	if err := database.DB.Where("post_id = ?", postID).Scopes(page.Scope("comments.created_at", "comments.id")).Find(&comments).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des commentaires"})
 		logs.LogJSON("ERROR", "Error retrieving comments", map[string]interface{}{
 			"postID": postID,
@@ -375,8 +479,13 @@ func GetCommentsByPostID(c *gin.Context) {
 		return
 	}
 
+	comments, envelope := pagination.Paginate(page, comments, func(comment Comment) (time.Time, string) {
+		return comment.CreatedAt, comment.ID
+	})
+
 	c.JSON(http.StatusOK, gin.H{
-		"comments": comments,
+		"comments":   comments,
+		"pagination": envelope,
 	})
 	logs.LogJSON("INFO", "Comments fetched successfully", map[string]interface{}{
 		"postID": postID,
@@ -427,18 +536,8 @@ func CreateComment(c *gin.Context) {
 	}
 
 	// Vérifier l'accès si le post est payant
-	if post.IsPaid {
-		if post.UserID != userID.(string) {
-			// Pour l'instant, seul le créateur peut commenter sur son propre post payant
-			// Ici, tu pourrais implémenter une vérification d'abonnement
-			c.JSON(http.StatusForbidden, gin.H{"error": "Accès non autorisé à ce contenu premium"})
-			logs.LogJSON("WARN", "Unauthorized access to premium content", map[string]interface{}{
-				"postID": input.PostID,
-				"route":  route,
-				"userID": userID,
-			})
-			return
-		}
+	if !checkPostAccess(c, post, userID.(string)) {
+		return
 	}
 
 	// Création du commentaire
@@ -460,6 +559,13 @@ func CreateComment(c *gin.Context) {
 		return
 	}
 
+	notification.Emit(notification.Event{
+		RecipientID: post.UserID,
+		ActorID:     userID.(string),
+		Type:        notification.TypeComment,
+		TargetID:    post.ID,
+	})
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Commentaire ajouté avec succès",
 		"comment": comment,
@@ -518,3 +624,41 @@ func DeleteComment(c *gin.Context) {
 		"userID": userID,
 	})
 }
+
+// checkPostAccess vérifie que l'utilisateur peut accéder au post et répond à sa place sinon
+func checkPostAccess(c *gin.Context, post Post, userID string) bool {
+	route := c.FullPath()
+
+	// Un post non publié n'existe pas pour les autres utilisateurs que son auteur
+	if post.AccessInfo().HiddenFrom(userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+		logs.LogJSON("WARN", "Unpublished post", map[string]interface{}{
+			"postID": post.ID,
+			"route":  route,
+			"userID": userID,
+		})
+		return false
+	}
+
+	canView, err := access.CanViewPost(userID, post.AccessInfo())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification de l'abonnement"})
+		logs.LogJSON("ERROR", "Subscription verification error", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": post.ID,
+			"route":  route,
+			"userID": userID,
+		})
+		return false
+	}
+	if !canView {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Accès non autorisé à ce contenu premium"})
+		logs.LogJSON("WARN", "Unauthorized access to premium content", map[string]interface{}{
+			"postID": post.ID,
+			"route":  route,
+			"userID": userID,
+		})
+		return false
+	}
+	return true
+}
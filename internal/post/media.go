@@ -0,0 +1,299 @@
+package post
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
+)
+
+// validMediaExtensions associe les extensions de fichier acceptées pour un post à leur type de média
+var validMediaExtensions = map[string]string{
+	".jpg": MediaImage, ".jpeg": MediaImage, ".png": MediaImage,
+	".gif": MediaImage, ".webp": MediaImage, ".heic": MediaImage,
+	".mp4": MediaVideo, ".mov": MediaVideo, ".avi": MediaVideo,
+}
+
+// ErrTooManyMedia est retournée quand le carrousel d'un post est déjà complet
+var ErrTooManyMedia = fmt.Errorf("un post ne peut pas contenir plus de %d médias", MaxMediaPerPost)
+
+// WithMedia est un scope GORM qui charge les médias d'un post dans l'ordre du carrousel
+func WithMedia(db *gorm.DB) *gorm.DB {
+	return db.Preload("Media", func(db *gorm.DB) *gorm.DB {
+		return db.Order("post_media.position")
+	})
+}
+
+// MediaByPost charge en une seule requête les médias d'une page de posts, dans l'ordre du carrousel
+func MediaByPost(postIDs []string) (map[string][]Media, error) {
+	media := make(map[string][]Media, len(postIDs))
+	if len(postIDs) == 0 {
+		return media, nil
+	}
+
+	var items []Media
+	if err := database.DB.Where("post_id IN ?", postIDs).Order("post_id, position").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		media[item.PostID] = append(media[item.PostID], item)
+	}
+	return media, nil
+}
+
+// RedactMedia masque l'URL des médias payants d'un post verrouillé, les aperçus restent visibles
+func RedactMedia(items []Media, unlocked bool) []Media {
+	if unlocked {
+		return items
+	}
+	redacted := make([]Media, len(items))
+	for i, item := range items {
+		if item.IsPaid {
+			item.URL = ""
+		}
+		redacted[i] = item
+	}
+	return redacted
+}
+
+// SignMedia remplace les clés S3 des médias par des URL signées temporaires.
+// À n'appeler qu'après vérification de l'accès, sur des médias déjà masqués par RedactMedia si besoin.
+func SignMedia(items []Media) []Media {
+	signed := make([]Media, len(items))
+	for i, item := range items {
+		item.URL = storage.SignedURL(item.URL)
+		signed[i] = item
+	}
+	return signed
+}
+
+// SignPost retourne le post avec des URL signées à la place des clés de ses médias
+func SignPost(post Post) Post {
+	post.MediaURL = storage.SignedURL(post.MediaURL)
+	post.Media = SignMedia(post.Media)
+	return post
+}
+
+// SignPosts signe les médias d'une liste de posts que l'utilisateur a le droit de voir
+func SignPosts(posts []Post) []Post {
+	signed := make([]Post, len(posts))
+	for i, post := range posts {
+		signed[i] = SignPost(post)
+	}
+	return signed
+}
+
+// SignRevision retourne la révision avec des URL signées à la place des clés de ses médias
+func SignRevision(revision Revision) Revision {
+	revision.MediaURL = storage.SignedURL(revision.MediaURL)
+	revision.Media = SignMedia(revision.Media)
+	return revision
+}
+
+// SignRevisions signe les médias d'une liste de révisions
+func SignRevisions(revisions []Revision) []Revision {
+	signed := make([]Revision, len(revisions))
+	for i, revision := range revisions {
+		signed[i] = SignRevision(revision)
+	}
+	return signed
+}
+
+// AttachMedia ajoute à la fin du carrousel d'un post de l'utilisateur un média déjà présent sur S3.
+// L'ajout est enregistré dans l'historique des modifications comme une modification classique.
+func AttachMedia(postID, userID, key string, isPreview bool) (Post, error) {
+	mediaType, ok := validMediaExtensions[strings.ToLower(filepath.Ext(key))]
+	if !ok {
+		return Post{}, fmt.Errorf("extension de média invalide : %s", key)
+	}
+
+	var before Post
+	if err := database.DB.Scopes(WithMedia).First(&before, "id = ? AND user_id = ?", postID, userID).Error; err != nil {
+		return before, err
+	}
+	if len(before.Media) >= MaxMediaPerPost {
+		return before, ErrTooManyMedia
+	}
+
+	after := before
+	after.Media = append(append([]Media(nil), before.Media...), Media{
+		Position:  len(before.Media),
+		Type:      mediaType,
+		URL:       key,
+		IsPreview: isPreview,
+	})
+	applyPaidFlags(after.Media, after.IsPaid)
+	after.MediaURL = coverURL(after.Media)
+
+	if _, err := EditPost(before, after, userID, nil); err != nil {
+		return before, err
+	}
+	return after, nil
+}
+
+// invalidMediaExtension retourne la première extension de fichier refusée, ou une chaîne vide
+func invalidMediaExtension(files []*multipart.FileHeader) string {
+	for _, header := range files {
+		ext := strings.ToLower(filepath.Ext(header.Filename))
+		if _, ok := validMediaExtensions[ext]; !ok {
+			return ext
+		}
+	}
+	return ""
+}
+
+// parsePreviews lit les positions des médias servant d'aperçu, et retourne un message d'erreur si invalides
+func parsePreviews(values []string, count int) (map[int]bool, string) {
+	previews := make(map[int]bool, len(values))
+	for _, value := range values {
+		position, err := strconv.Atoi(value)
+		if err != nil || position < 0 || position >= count {
+			return nil, "Position d'aperçu invalide"
+		}
+		previews[position] = true
+	}
+	return previews, ""
+}
+
+// applyPaidFlags réserve aux ayants droit les médias d'un post payant qui ne sont pas des aperçus
+func applyPaidFlags(items []Media, isPaid bool) {
+	for i := range items {
+		if !isPaid {
+			items[i].IsPreview = false
+		}
+		items[i].IsPaid = isPaid && !items[i].IsPreview
+	}
+}
+
+// uploadMedia envoie les fichiers sur S3 dans l'ordre et retourne les éléments du carrousel.
+// En cas d'échec, les fichiers déjà envoyés sont supprimés pour ne pas laisser d'orphelins.
+func uploadMedia(postID string, files []*multipart.FileHeader, previews map[int]bool, isPaid bool) ([]Media, error) {
+	items := make([]Media, 0, len(files))
+	for position, header := range files {
+		item, err := uploadMediaFile(postID, header, position)
+		if err != nil {
+			deleteMediaItems(items)
+			return nil, err
+		}
+		item.IsPreview = previews[position]
+		items = append(items, item)
+	}
+	applyPaidFlags(items, isPaid)
+	return items, nil
+}
+
+// uploadMediaFile envoie un fichier sur S3, sous une clé unique pour ne jamais écraser un média
+// encore référencé par une révision
+func uploadMediaFile(postID string, header *multipart.FileHeader, position int) (Media, error) {
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	item := Media{Position: position, Type: validMediaExtensions[ext]}
+
+	file, err := header.Open()
+	if err != nil {
+		return item, fmt.Errorf("ouverture du fichier %s : %w", header.Filename, err)
+	}
+	defer file.Close()
+
+	// Dimensions des formats d'image décodables, puis retour au début du fichier pour l'upload
+	if item.Type == MediaImage {
+		if config, _, err := image.DecodeConfig(file); err == nil {
+			item.Width = &config.Width
+			item.Height = &config.Height
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return item, fmt.Errorf("lecture du fichier %s : %w", header.Filename, err)
+		}
+	}
+
+	filename := fmt.Sprintf("post_%s_%s%s", postID, uuid.New().String(), ext)
+	item.URL, err = storage.UploadToS3(file, filename, header.Header.Get("Content-Type"), "posts")
+	if err != nil {
+		return item, fmt.Errorf("upload du fichier %s : %w", header.Filename, err)
+	}
+	return item, nil
+}
+
+// secondaryMediaURLs liste les fichiers du post autres que son média principal : les autres éléments
+// du carrousel et ceux conservés par ses révisions
+func secondaryMediaURLs(post Post) ([]string, error) {
+	var revisionURLs []string
+	if err := database.DB.Raw(`SELECT media_url FROM post_revisions WHERE post_id = ?
+		UNION SELECT item->>'url' FROM post_revisions, jsonb_array_elements(media) AS item WHERE post_id = ?`,
+		post.ID, post.ID).Scan(&revisionURLs).Error; err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{post.MediaURL: true, "": true}
+	var urls []string
+	for _, url := range append(mediaURLs(post.Media), revisionURLs...) {
+		if !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+// mediaChanged indique si deux listes de médias diffèrent par leurs fichiers, leur ordre ou leurs options
+func mediaChanged(before, after []Media) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for i := range before {
+		if before[i].URL != after[i].URL || before[i].Position != after[i].Position ||
+			before[i].IsPaid != after[i].IsPaid || before[i].IsPreview != after[i].IsPreview {
+			return true
+		}
+	}
+	return false
+}
+
+// coverURL retourne la clé du premier média, conservée dans posts.media_url
+func coverURL(items []Media) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return items[0].URL
+}
+
+// mediaURLs retourne les clés S3 d'une liste de médias
+func mediaURLs(items []Media) []string {
+	urls := make([]string, 0, len(items))
+	for _, item := range items {
+		urls = append(urls, item.URL)
+	}
+	return urls
+}
+
+// deleteMediaItems supprime de S3 les fichiers d'une liste de médias, sans bloquer en cas d'échec
+func deleteMediaItems(items []Media) {
+	for _, item := range items {
+		deleteMedia(item.URL)
+	}
+}
+
+// deleteMedia supprime un média de S3 à partir de sa clé, sans bloquer en cas d'échec
+func deleteMedia(key string) {
+	if key == "" {
+		return
+	}
+	if err := storage.DeleteFromS3(key); err != nil {
+		logs.LogJSON("WARN", "Error deleting media on S3", map[string]interface{}{
+			"error": err.Error(),
+			"extra": fmt.Sprintf("media : %s", key),
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package post
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestParsePreviews(t *testing.T) {
+	previews, message := parsePreviews([]string{"0", "2"}, 3)
+	assert.Empty(t, message)
+	assert.Equal(t, map[int]bool{0: true, 2: true}, previews)
+
+	for _, values := range [][]string{{"3"}, {"-1"}, {"premier"}} {
+		_, message := parsePreviews(values, 3)
+		assert.NotEmpty(t, message, values)
+	}
+}
+
+func TestApplyPaidFlags(t *testing.T) {
+	items := []Media{{URL: "a.jpg", IsPreview: true}, {URL: "b.jpg"}}
+
+	applyPaidFlags(items, true)
+	assert.False(t, items[0].IsPaid)
+	assert.True(t, items[1].IsPaid)
+
+	// Un post gratuit n'a ni média payant ni aperçu
+	applyPaidFlags(items, false)
+	assert.False(t, items[0].IsPaid || items[0].IsPreview)
+	assert.False(t, items[1].IsPaid)
+}
+
+func TestRedactMedia(t *testing.T) {
+	items := []Media{{URL: "a.jpg", IsPreview: true}, {URL: "b.jpg", IsPaid: true}}
+
+	assert.Equal(t, items, RedactMedia(items, true))
+
+	redacted := RedactMedia(items, false)
+	assert.Equal(t, "a.jpg", redacted[0].URL)
+	assert.Empty(t, redacted[1].URL)
+	assert.Equal(t, "b.jpg", items[1].URL, "la liste d'origine n'est pas modifiée")
+}
+
+func TestSecondaryMediaURLs(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	post := Post{ID: "post1", MediaURL: "a.jpg", Media: []Media{{URL: "a.jpg"}, {URL: "b.jpg"}}}
+
+	mock.ExpectQuery(`SELECT media_url FROM post_revisions WHERE post_id = \$1\s+UNION SELECT item->>'url'`).
+		WithArgs("post1", "post1").
+		WillReturnRows(sqlmock.NewRows([]string{"media_url"}).AddRow("a.jpg").AddRow("old.jpg").AddRow("b.jpg"))
+
+	urls, err := secondaryMediaURLs(post)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b.jpg", "old.jpg"}, urls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,8 +1,12 @@
 package post
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
 
@@ -13,6 +17,146 @@ type Post struct {
 	User        user.User `gorm:"foreignKey:UserID"`
 	Title       string
 	Description string
-	MediaURL    string
+	MediaURL    string // clé S3 du premier média, signée à l'affichage
 	IsPaid      bool
+	UnlockPrice *float64   // prix de déblocage à l'unité, optionnel pour un post payant
+	MinTierRank int        // rang du palier d'abonnement minimum, 0 pour tout abonné
+	Status      string     // access.StatusDraft, StatusScheduled, StatusPublished ou StatusArchived
+	PublishAt   *time.Time // date de publication, programmée ou effective
+	PublishedAt *time.Time // date de la première mise en ligne, qui ordonne les fils
+	Media       []Media    `gorm:"foreignKey:PostID"` // éléments du carrousel, MediaURL est celui du premier
+}
+
+// Types de média d'un post
+const (
+	MediaImage = "image"
+	MediaVideo = "video"
+)
+
+// MaxMediaPerPost est le nombre maximum de médias d'un post en carrousel
+const MaxMediaPerPost = 20
+
+// Media est un élément d'un post, dans l'ordre d'affichage du carrousel
+type Media struct {
+	ID        string `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	PostID    string `json:"-" gorm:"index"`
+	Position  int    `json:"position"`
+	Type      string `json:"type"`
+	URL       string `json:"url"`        // clé S3, remplacée par une URL signée dans les réponses
+	Width     *int   `json:"width"`      // inconnu pour les vidéos et certains formats d'image
+	Height    *int   `json:"height"`     // inconnu pour les vidéos et certains formats d'image
+	IsPaid    bool   `json:"is_paid"`    // réservé aux utilisateurs ayant accès au contenu payant du post
+	IsPreview bool   `json:"is_preview"` // aperçu visible par tous sur un post payant
+}
+
+func (Media) TableName() string {
+	return "post_media"
+}
+
+// MediaList est une liste de médias stockée en jsonb dans les révisions
+type MediaList []Media
+
+// Value implémente driver.Valuer
+func (l MediaList) Value() (driver.Value, error) {
+	if l == nil {
+		l = MediaList{}
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implémente sql.Scanner
+func (l *MediaList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return fmt.Errorf("type de liste de médias non pris en charge : %T", src)
+}
+
+// AccessInfo retourne les informations utilisées par les règles d'accès
+func (p Post) AccessInfo() access.Post {
+	return access.Post{
+		ID:          p.ID,
+		UserID:      p.UserID,
+		Status:      p.Status,
+		IsPaid:      p.IsPaid,
+		MinTierRank: p.MinTierRank,
+	}
+}
+
+// postCursorKey retourne la clé de pagination (created_at, id) d'un post
+func postCursorKey(p Post) (time.Time, string) {
+	return p.CreatedAt, p.ID
+}
+
+// publishedCursorColumn ordonne les posts par date de mise en ligne, les brouillons de l'auteur
+// par date de création
+const publishedCursorColumn = "COALESCE(posts.published_at, posts.created_at)"
+
+// publishedCursorKey retourne la clé de pagination d'un post trié par publishedCursorColumn
+func publishedCursorKey(p Post) (time.Time, string) {
+	if p.PublishedAt != nil {
+		return *p.PublishedAt, p.ID
+	}
+	return p.CreatedAt, p.ID
+}
+
+// Revision conserve l'état d'un post avant une modification, pour l'historique et la restauration
+type Revision struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt      time.Time `json:"created_at"`
+	PostID         string    `json:"post_id" gorm:"index"`
+	EditorID       string    `json:"editor_id"` // auteur du post ou administrateur
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	MediaURL       string    `json:"media_url"`
+	Media          MediaList `json:"media" gorm:"type:jsonb"`
+	IsPaid         bool      `json:"is_paid"`
+	Diff           Diff      `json:"diff" gorm:"type:jsonb"`
+	RestoredFromID *string   `json:"restored_from_id"` // révision restaurée par cette modification
+}
+
+func (Revision) TableName() string {
+	return "post_revisions"
+}
+
+// FieldChange décrit la modification d'un champ
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Diff associe à chaque champ modifié son ancienne et sa nouvelle valeur, stocké en jsonb
+type Diff map[string]FieldChange
+
+// Value implémente driver.Valuer
+func (d Diff) Value() (driver.Value, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implémente sql.Scanner
+func (d *Diff) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	}
+	return fmt.Errorf("type de diff non pris en charge : %T", src)
 }
@@ -0,0 +1,338 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+)
+
+// errNoMediaMessage est renvoyé quand on tente de publier ou programmer un brouillon sans média
+const errNoMediaMessage = "Un post doit contenir au moins un média pour être publié"
+
+// DraftInput contient les modifications d'un post non publié
+type DraftInput struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Status      string  `json:"status"`
+	PublishAt   string  `json:"publish_at"` // RFC 3339, obligatoire pour un post programmé
+}
+
+// parsePublication détermine le statut et la date de publication demandés, et retourne
+// un message d'erreur si la demande est invalide. Sans statut, un post avec une date est
+// programmé et un post sans date est publié immédiatement.
+func parsePublication(status, publishAtStr string, now time.Time) (string, *time.Time, string) {
+	var publishAt *time.Time
+	if publishAtStr != "" {
+		parsed, err := time.Parse(time.RFC3339, publishAtStr)
+		if err != nil {
+			return "", nil, "Date de publication invalide"
+		}
+		publishAt = &parsed
+	}
+
+	if status == "" {
+		status = access.StatusPublished
+		if publishAt != nil {
+			status = access.StatusScheduled
+		}
+	}
+
+	switch status {
+	case access.StatusDraft:
+		return status, publishAt, ""
+	case access.StatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return "", nil, "La date de publication doit être dans le futur"
+		}
+		return status, publishAt, ""
+	case access.StatusPublished:
+		return status, &now, ""
+	}
+	return "", nil, "Statut de publication invalide"
+}
+
+// isPostStatus indique si le statut fait partie des statuts de publication d'un post
+func isPostStatus(status string) bool {
+	switch status {
+	case access.StatusDraft, access.StatusScheduled, access.StatusPublished, access.StatusArchived:
+		return true
+	}
+	return false
+}
+
+// UpdateDraft PUT /api/posts/:id/draft
+// Modifie un brouillon ou un post programmé, et permet de le programmer ou de le publier
+func UpdateDraft(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	postID := c.Param("id")
+
+	var input DraftInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		logs.LogJSON("WARN", "Invalid draft input", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
+	}
+
+	post, ok := loadOwnPost(c, postID)
+	if !ok {
+		return
+	}
+	if post.Status != access.StatusDraft && post.Status != access.StatusScheduled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Seul un brouillon ou un post programmé peut être modifié ici"})
+		logs.LogJSON("WARN", "Post is not a draft", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+			"extra":  fmt.Sprintf("status : %s", post.Status),
+		})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Le titre est obligatoire"})
+			return
+		}
+		updates["title"] = title
+	}
+	if input.Description != nil {
+		updates["description"] = *input.Description
+	}
+
+	// Le statut n'est recalculé que si l'auteur le demande, un brouillon reste un brouillon sinon
+	if input.Status != "" || input.PublishAt != "" {
+		now := time.Now()
+		status, publishAt, message := parsePublication(input.Status, input.PublishAt, now)
+		if message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			logs.LogJSON("WARN", "Invalid publication schedule", map[string]interface{}{
+				"route":  route,
+				"userID": userID,
+				"postID": postID,
+				"extra":  fmt.Sprintf("status : %s, publish_at : %s", input.Status, input.PublishAt),
+			})
+			return
+		}
+		if status != access.StatusDraft && post.MediaURL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errNoMediaMessage})
+			return
+		}
+		updates["status"] = status
+		updates["publish_at"] = publishAt
+		if status == access.StatusPublished {
+			updates["published_at"] = now
+		}
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusOK, gin.H{"post": SignPost(post)})
+		return
+	}
+	if !updateOwnPost(c, &post, updates) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"post": SignPost(post)})
+	logs.LogJSON("INFO", "Draft updated successfully", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+		"postID": postID,
+	})
+}
+
+// PublishPost POST /api/posts/:id/publish
+// Publie immédiatement un brouillon ou un post programmé, ou republie un post archivé
+func PublishPost(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	postID := c.Param("id")
+
+	post, ok := loadOwnPost(c, postID)
+	if !ok {
+		return
+	}
+
+	if post.MediaURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errNoMediaMessage})
+		logs.LogJSON("WARN", "Post without media", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
+	}
+
+	updates := map[string]interface{}{"status": access.StatusPublished}
+	switch post.Status {
+	case access.StatusDraft, access.StatusScheduled:
+		// Le post prend sa place dans les fils à sa date de mise en ligne
+		now := time.Now()
+		updates["published_at"] = now
+		updates["publish_at"] = now
+	case access.StatusArchived:
+		// Un post republié garde sa place d'origine dans les fils
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ce post est déjà publié"})
+		logs.LogJSON("WARN", "Post already published", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
+	}
+
+	if !updateOwnPost(c, &post, updates) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"post": SignPost(post)})
+	logs.LogJSON("INFO", "Post published successfully", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+		"postID": postID,
+	})
+}
+
+// ArchivePost POST /api/posts/:id/archive
+// Retire un post publié de la vue des autres utilisateurs sans le supprimer
+func ArchivePost(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	postID := c.Param("id")
+
+	post, ok := loadOwnPost(c, postID)
+	if !ok {
+		return
+	}
+	if post.Status != access.StatusPublished {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Seul un post publié peut être archivé"})
+		logs.LogJSON("WARN", "Post is not published", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+			"extra":  fmt.Sprintf("status : %s", post.Status),
+		})
+		return
+	}
+
+	if !updateOwnPost(c, &post, map[string]interface{}{"status": access.StatusArchived}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"post": SignPost(post)})
+	logs.LogJSON("INFO", "Post archived successfully", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+		"postID": postID,
+	})
+}
+
+// PublishScheduled publie les posts programmés dont la date de publication est passée.
+// Le post prend sa place dans les fils à sa date de publication prévue.
+func PublishScheduled() error {
+	result := database.DB.Model(&Post{}).
+		Where("status = ? AND publish_at <= ?", access.StatusScheduled, time.Now()).
+		Updates(map[string]interface{}{
+			"status":       access.StatusPublished,
+			"published_at": gorm.Expr("publish_at"),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("publication des posts programmés : %w", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		logs.LogJSON("INFO", "Scheduled posts published", map[string]interface{}{
+			"extra": fmt.Sprintf("count : %d", result.RowsAffected),
+		})
+	}
+	return nil
+}
+
+// loadOwnPost charge un post de l'utilisateur connecté, et répond à sa place s'il est introuvable
+func loadOwnPost(c *gin.Context, postID string) (Post, bool) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var post Post
+	if err := database.DB.Scopes(WithMedia).First(&post, "id = ? AND user_id = ?", postID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+			logs.LogJSON("WARN", "Post not found", map[string]interface{}{
+				"route":  route,
+				"userID": userID,
+				"postID": postID,
+			})
+			return post, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
+		logs.LogJSON("ERROR", "Database error", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return post, false
+	}
+	return post, true
+}
+
+// updateOwnPost applique les modifications si le statut du post n'a pas changé entre-temps
+// (publication programmée concurrente), recharge le post et répond à sa place en cas d'échec
+func updateOwnPost(c *gin.Context, post *Post, updates map[string]interface{}) bool {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	result := database.DB.Model(&Post{}).
+		Where("id = ? AND status = ?", post.ID, post.Status).
+		Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du post"})
+		logs.LogJSON("ERROR", "Error updating post", map[string]interface{}{
+			"error":  result.Error.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": post.ID,
+		})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Le statut du post a changé, veuillez réessayer"})
+		logs.LogJSON("WARN", "Post status changed concurrently", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": post.ID,
+		})
+		return false
+	}
+
+	var updated Post
+	if err := database.DB.Scopes(WithMedia).First(&updated, "id = ?", post.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du post"})
+		logs.LogJSON("ERROR", "Error reloading post", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": post.ID,
+		})
+		return false
+	}
+	*post = updated
+	return true
+}
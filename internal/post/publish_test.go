@@ -0,0 +1,70 @@
+package post
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestParsePublication(t *testing.T) {
+	now := time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC)
+	future := "2024-05-20T18:00:00Z"
+
+	tests := []struct {
+		name          string
+		status        string
+		publishAt     string
+		wantStatus    string
+		wantPublishAt *time.Time
+		wantError     bool
+	}{
+		{name: "Published by default", wantStatus: access.StatusPublished, wantPublishAt: &now},
+		{name: "Date alone schedules the post", publishAt: future, wantStatus: access.StatusScheduled},
+		{name: "Explicit schedule", status: access.StatusScheduled, publishAt: future, wantStatus: access.StatusScheduled},
+		{name: "Draft without date", status: access.StatusDraft, wantStatus: access.StatusDraft},
+		{name: "Draft keeps its planned date", status: access.StatusDraft, publishAt: future, wantStatus: access.StatusDraft},
+		{name: "Schedule requires a date", status: access.StatusScheduled, wantError: true},
+		{name: "Schedule in the past is rejected", publishAt: "2024-05-01T08:00:00Z", wantError: true},
+		{name: "Invalid date", publishAt: "demain", wantError: true},
+		{name: "Archived on creation is rejected", status: access.StatusArchived, wantError: true},
+		{name: "Unknown status", status: "hidden", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, publishAt, message := parsePublication(tt.status, tt.publishAt, now)
+
+			if tt.wantError {
+				assert.NotEmpty(t, message)
+				return
+			}
+			assert.Empty(t, message)
+			assert.Equal(t, tt.wantStatus, status)
+			if tt.wantPublishAt != nil {
+				assert.Equal(t, *tt.wantPublishAt, *publishAt)
+			} else if tt.publishAt != "" {
+				assert.Equal(t, tt.publishAt, publishAt.Format(time.RFC3339))
+			} else {
+				assert.Nil(t, publishAt)
+			}
+		})
+	}
+}
+
+func TestPublishScheduled(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET "published_at"=publish_at,"status"=\$1 WHERE status = \$2 AND publish_at <= \$3`).
+		WithArgs(access.StatusPublished, access.StatusScheduled, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	assert.NoError(t, PublishScheduled())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
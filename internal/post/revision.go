@@ -0,0 +1,413 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// ErrNothingToChange est retournée quand une modification laisserait le post identique
+var ErrNothingToChange = errors.New("aucune modification")
+
+// diffPosts liste les champs éditables qui diffèrent entre deux états d'un post
+func diffPosts(before, after Post) Diff {
+	diff := Diff{}
+	if before.Title != after.Title {
+		diff["title"] = FieldChange{Old: before.Title, New: after.Title}
+	}
+	if before.Description != after.Description {
+		diff["description"] = FieldChange{Old: before.Description, New: after.Description}
+	}
+	if before.IsPaid != after.IsPaid {
+		diff["is_paid"] = FieldChange{Old: before.IsPaid, New: after.IsPaid}
+	}
+	if before.MediaURL != after.MediaURL {
+		diff["media_url"] = FieldChange{Old: before.MediaURL, New: after.MediaURL}
+	}
+	beforeURLs, afterURLs := mediaURLs(before.Media), mediaURLs(after.Media)
+	if !slices.Equal(beforeURLs, afterURLs) {
+		diff["media"] = FieldChange{Old: beforeURLs, New: afterURLs}
+	}
+	return diff
+}
+
+// EditPost enregistre l'état actuel du post dans une révision puis applique les nouvelles valeurs.
+// restoredFromID indique la révision restaurée, nil pour une modification classique.
+func EditPost(before, after Post, editorID string, restoredFromID *string) (Revision, error) {
+	revision := Revision{
+		CreatedAt:      time.Now(),
+		PostID:         before.ID,
+		EditorID:       editorID,
+		Title:          before.Title,
+		Description:    before.Description,
+		MediaURL:       before.MediaURL,
+		Media:          before.Media,
+		IsPaid:         before.IsPaid,
+		Diff:           diffPosts(before, after),
+		RestoredFromID: restoredFromID,
+	}
+	if len(revision.Diff) == 0 {
+		return revision, ErrNothingToChange
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&revision).Error; err != nil {
+			return fmt.Errorf("création de la révision : %w", err)
+		}
+		if err := tx.Model(&Post{}).Where("id = ?", before.ID).Updates(map[string]interface{}{
+			"title":       after.Title,
+			"description": after.Description,
+			"is_paid":     after.IsPaid,
+			"media_url":   after.MediaURL,
+		}).Error; err != nil {
+			return fmt.Errorf("mise à jour du post : %w", err)
+		}
+		if !mediaChanged(before.Media, after.Media) {
+			return nil
+		}
+
+		// Les médias sont remplacés en bloc, les anciens fichiers restent référencés par la révision
+		if err := tx.Where("post_id = ?", before.ID).Delete(&Media{}).Error; err != nil {
+			return fmt.Errorf("suppression des médias du post : %w", err)
+		}
+		items := make([]Media, len(after.Media))
+		for i, item := range after.Media {
+			item.ID = ""
+			item.PostID = before.ID
+			items[i] = item
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return fmt.Errorf("création des médias du post : %w", err)
+			}
+		}
+		return nil
+	})
+	return revision, err
+}
+
+// RevisionCounts retourne le nombre de révisions de chacun des posts, en une seule requête.
+// Les posts jamais modifiés sont absents du résultat.
+func RevisionCounts(postIDs []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(postIDs))
+	if len(postIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		PostID string
+		Count  int64
+	}
+	if err := database.DB.Model(&Revision{}).
+		Select("post_id, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).
+		Group("post_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, nil
+}
+
+// UpdatePost PUT /api/posts/:id
+// Modifie le titre, la description, le caractère payant ou les médias d'un post (multipart, champs optionnels).
+// Les anciens médias sont conservés sur S3 tant que des révisions y font référence.
+func UpdatePost(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	postID := c.Param("id")
+
+	post, ok := loadOwnPost(c, postID)
+	if !ok {
+		return
+	}
+
+	after := post
+	if title, ok := c.GetPostForm("title"); ok {
+		after.Title = strings.TrimSpace(title)
+		if after.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Le titre est obligatoire"})
+			logs.LogJSON("WARN", "Title is mandatory", map[string]interface{}{
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+			})
+			return
+		}
+	}
+	if description, ok := c.GetPostForm("description"); ok {
+		after.Description = description
+	}
+	if isPaid, ok := c.GetPostForm("is_paid"); ok {
+		after.IsPaid = isPaid == "true"
+		if after.IsPaid && !post.IsPaid {
+			// Seul un créateur peut rendre un post payant
+			var u user.User
+			if err := database.DB.Select("id, is_creator").First(&u, "id = ?", userID).Error; err != nil || !u.IsCreator {
+				after.IsPaid = false
+			}
+		}
+	}
+
+	// Remplacement des médias, sous de nouvelles clés pour ne pas écraser ceux des révisions
+	var newMedia []Media
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["media"]
+	}
+	if len(files) > 0 {
+		if len(files) > MaxMediaPerPost {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Un post ne peut pas contenir plus de %d médias", MaxMediaPerPost)})
+			logs.LogJSON("WARN", "Too many media", map[string]interface{}{
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("media count : %d", len(files)),
+			})
+			return
+		}
+		if ext := invalidMediaExtension(files); ext != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Extension de fichier invalide"})
+			logs.LogJSON("WARN", "Invalid file extension", map[string]interface{}{
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("Invalid file extension : %s", ext),
+			})
+			return
+		}
+		previews, message := parsePreviews(c.PostFormArray("preview"), len(files))
+		if message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			logs.LogJSON("WARN", "Invalid media preview", map[string]interface{}{
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+			})
+			return
+		}
+
+		var err error
+		newMedia, err = uploadMedia(postID, files, previews, after.IsPaid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'upload S3", "details": err.Error()})
+			logs.LogJSON("ERROR", "S3 upload error", map[string]interface{}{
+				"error":  err.Error(),
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+			})
+			return
+		}
+		after.Media = newMedia
+		after.MediaURL = newMedia[0].URL
+	} else {
+		// Les médias suivent le caractère payant du post
+		after.Media = append([]Media(nil), post.Media...)
+		applyPaidFlags(after.Media, after.IsPaid)
+	}
+
+	revision, err := EditPost(post, after, userID, nil)
+	if err != nil {
+		if errors.Is(err, ErrNothingToChange) {
+			c.JSON(http.StatusOK, gin.H{"post": SignPost(post)})
+			return
+		}
+		// Les nouveaux médias ne sont référencés nulle part, on les supprime
+		deleteMediaItems(newMedia)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la modification du post"})
+		logs.LogJSON("ERROR", "Error updating post", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"post": SignPost(after), "revision": SignRevision(revision)})
+	logs.LogJSON("INFO", "Post updated successfully", map[string]interface{}{
+		"postID": postID,
+		"route":  route,
+		"userID": userID,
+	})
+}
+
+// GetPostRevisions GET /api/posts/:id/revisions
+// Historique des modifications, visible par l'auteur du post et les administrateurs
+func GetPostRevisions(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	postID := c.Param("id")
+
+	if _, ok := loadPostForRevisions(c, postID); !ok {
+		return
+	}
+
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	var revisions []Revision
+	if err := database.DB.Where("post_id = ?", postID).
+		Scopes(page.Scope("post_revisions.created_at", "post_revisions.id")).
+		Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des révisions"})
+		logs.LogJSON("ERROR", "Error retrieving post revisions", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	revisions, envelope := pagination.Paginate(page, revisions, func(r Revision) (time.Time, string) {
+		return r.CreatedAt, r.ID
+	})
+
+	c.JSON(http.StatusOK, gin.H{"revisions": SignRevisions(revisions), "pagination": envelope})
+}
+
+// RestorePostRevision POST /api/posts/:id/revisions/:revision_id/restore
+// Remet le post dans l'état enregistré par la révision. La restauration crée elle-même
+// une révision, l'historique n'est jamais réécrit.
+func RestorePostRevision(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	postID := c.Param("id")
+	revisionID := c.Param("revision_id")
+
+	post, ok := loadPostForRevisions(c, postID)
+	if !ok {
+		return
+	}
+
+	var target Revision
+	if err := database.DB.First(&target, "id = ? AND post_id = ?", revisionID, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Révision introuvable"})
+			logs.LogJSON("WARN", "Post revision not found", map[string]interface{}{
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+				"extra":  fmt.Sprintf("revision_id : %s", revisionID),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
+		logs.LogJSON("ERROR", "Database error", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	after := post
+	after.Title = target.Title
+	after.Description = target.Description
+	after.MediaURL = target.MediaURL
+	after.Media = append([]Media(nil), target.Media...)
+	after.IsPaid = target.IsPaid
+
+	revision, err := EditPost(post, after, userID, &target.ID)
+	if err != nil {
+		if errors.Is(err, ErrNothingToChange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Le post est déjà dans l'état de cette révision"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la restauration de la révision"})
+		logs.LogJSON("ERROR", "Error restoring post revision", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+			"extra":  fmt.Sprintf("revision_id : %s", revisionID),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"post": SignPost(after), "revision": SignRevision(revision)})
+	logs.LogJSON("INFO", "Post revision restored successfully", map[string]interface{}{
+		"postID": postID,
+		"route":  route,
+		"userID": userID,
+		"extra":  fmt.Sprintf("revision_id : %s", revisionID),
+	})
+}
+
+// loadPostForRevisions charge un post dont l'utilisateur connecté est l'auteur ou l'administrateur,
+// et répond à sa place sinon
+func loadPostForRevisions(c *gin.Context, postID string) (Post, bool) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var post Post
+	if err := database.DB.Scopes(WithMedia).First(&post, "id = ?", postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+			logs.LogJSON("WARN", "Post not found", map[string]interface{}{
+				"postID": postID,
+				"route":  route,
+				"userID": userID,
+			})
+			return post, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
+		logs.LogJSON("ERROR", "Database error", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return post, false
+	}
+	if post.UserID == userID {
+		return post, true
+	}
+
+	isAdmin, err := user.IsAdmin(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification des droits"})
+		logs.LogJSON("ERROR", "Admin verification error", map[string]interface{}{
+			"error":  err.Error(),
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return post, false
+	}
+	if !isAdmin {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+		logs.LogJSON("WARN", "Post revisions access denied", map[string]interface{}{
+			"postID": postID,
+			"route":  route,
+			"userID": userID,
+		})
+		return post, false
+	}
+	return post, true
+}
@@ -0,0 +1,105 @@
+package post
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestDiffPosts(t *testing.T) {
+	before := Post{ID: "post1", Title: "Titre", Description: "Avant", MediaURL: "posts/a.jpg"}
+
+	after := before
+	after.Description = "Après"
+	after.IsPaid = true
+
+	diff := diffPosts(before, after)
+	assert.Len(t, diff, 2)
+	assert.Equal(t, FieldChange{Old: "Avant", New: "Après"}, diff["description"])
+	assert.Equal(t, FieldChange{Old: false, New: true}, diff["is_paid"])
+
+	assert.Empty(t, diffPosts(before, before))
+}
+
+func TestDiffScan(t *testing.T) {
+	diff := Diff{"title": {Old: "Avant", New: "Après"}}
+
+	value, err := diff.Value()
+	assert.NoError(t, err)
+
+	var scanned Diff
+	assert.NoError(t, scanned.Scan([]byte(value.(string))))
+	assert.Equal(t, diff, scanned)
+
+	assert.Error(t, scanned.Scan(42))
+}
+
+func TestEditPost(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	before := Post{ID: "post1", UserID: "creator1", Title: "Titre", Description: "Avant"}
+	after := before
+	after.Title = "Nouveau titre"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "post_revisions"`).
+		WithArgs(sqlmock.AnyArg(), "post1", "creator1", "Titre", "Avant", "", "[]", false,
+			`{"title":{"old":"Titre","new":"Nouveau titre"}}`, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("rev1"))
+	mock.ExpectExec(`UPDATE "posts" SET "description"=\$1,"is_paid"=\$2,"media_url"=\$3,"title"=\$4 WHERE id = \$5`).
+		WithArgs("Avant", false, "", "Nouveau titre", "post1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	revision, err := EditPost(before, after, "creator1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "rev1", revision.ID)
+	assert.Equal(t, "Titre", revision.Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEditPostReplacesMedia(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	before := Post{ID: "post1", UserID: "creator1", Title: "Titre", MediaURL: "a.jpg",
+		Media: []Media{{ID: "m1", PostID: "post1", Type: MediaImage, URL: "a.jpg"}}}
+	after := before
+	after.MediaURL = "b.jpg"
+	after.Media = []Media{{Type: MediaImage, URL: "b.jpg"}, {Position: 1, Type: MediaVideo, URL: "c.mp4"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "post_revisions"`).
+		WithArgs(sqlmock.AnyArg(), "post1", "creator1", "Titre", "", "a.jpg",
+			`[{"id":"m1","position":0,"type":"image","url":"a.jpg","width":null,"height":null,"is_paid":false,"is_preview":false}]`,
+			false, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("rev1"))
+	mock.ExpectExec(`UPDATE "posts" SET`).
+		WithArgs("", false, "b.jpg", "Titre", "post1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "post_media" WHERE post_id = \$1`).
+		WithArgs("post1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "post_media"`).
+		WithArgs("post1", 0, MediaImage, "b.jpg", nil, nil, false, false,
+			"post1", 1, MediaVideo, "c.mp4", nil, nil, false, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("m2").AddRow("m3"))
+	mock.ExpectCommit()
+
+	revision, err := EditPost(before, after, "creator1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, FieldChange{Old: []string{"a.jpg"}, New: []string{"b.jpg", "c.mp4"}}, revision.Diff["media"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEditPostWithoutChanges(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	post := Post{ID: "post1", UserID: "creator1", Title: "Titre"}
+
+	_, err := EditPost(post, post, "creator1", nil)
+	assert.ErrorIs(t, err, ErrNothingToChange)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
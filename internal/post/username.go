@@ -6,7 +6,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
 
@@ -26,23 +28,21 @@ func GetPostsByUsername(c *gin.Context) {
 
 	requesterID := c.GetString("user_id")
 
-	var isSubscribed bool
-	if requesterID != "" {
-		var count int64
-		database.DB.Table("subscriptions").Where("subscriber_id = ? AND creator_id = ? AND status = 'active'", requesterID, u.ID).Count(&count)
-		isSubscribed = count > 0
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		return
 	}
 
 	var posts []Post
-	query := database.DB.Preload("User").Where("user_id = ?", u.ID)
-	if !isSubscribed {
-		query = query.Where("is_paid = false")
-	}
+	query := database.DB.Preload("User").Where("user_id = ?", u.ID).Scopes(WithMedia, access.PublishedPosts(requesterID), access.VisiblePosts(requesterID))
 
-	if err := query.Order("created_at DESC").Find(&posts).Error; err != nil {
+	if err := query.Scopes(page.Scope(publishedCursorColumn, "posts.id")).Find(&posts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de récupération des posts"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"posts": posts})
+	posts, envelope := pagination.Paginate(page, posts, publishedCursorKey)
+
+	c.JSON(http.StatusOK, gin.H{"posts": SignPosts(posts), "pagination": envelope})
 }
@@ -0,0 +1,107 @@
+package realtime
+
+import (
+	"sync"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+)
+
+// Types d'événements poussés aux clients
+const (
+	EventMessageNew       = "message.new"
+	EventMessageRead      = "message.read"
+	EventMessageDeleted   = "message.deleted"
+	EventMessageUnlocked  = "message.unlocked"
+	EventConversationRead = "conversation.read"
+	EventTyping           = "typing"
+)
+
+// Event est un événement temps réel envoyé à un utilisateur
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Broker distribue les événements aux connexions des utilisateurs.
+// L'implémentation par défaut est en mémoire ; une implémentation Redis ou Postgres LISTEN/NOTIFY
+// peut être branchée avec SetBroker pour partager les événements entre plusieurs réplicas.
+type Broker interface {
+	// Publish envoie un événement à toutes les connexions d'un utilisateur
+	Publish(userID string, event Event)
+	// Subscribe ouvre un flux d'événements pour un utilisateur et retourne la fonction de désinscription
+	Subscribe(userID string) (<-chan Event, func())
+}
+
+var broker Broker = NewLocalBroker()
+
+// SetBroker remplace le broker utilisé par l'application
+func SetBroker(b Broker) {
+	broker = b
+}
+
+// Publish envoie un événement aux utilisateurs donnés via le broker courant
+func Publish(event Event, userIDs ...string) {
+	for _, userID := range userIDs {
+		broker.Publish(userID, event)
+	}
+}
+
+// Subscribe ouvre un flux d'événements pour un utilisateur via le broker courant
+func Subscribe(userID string) (<-chan Event, func()) {
+	return broker.Subscribe(userID)
+}
+
+// subscriberBufferSize est le nombre d'événements en attente avant qu'une connexion lente ne perde des événements
+const subscriberBufferSize = 32
+
+// LocalBroker est un broker en mémoire limité à un seul processus
+type LocalBroker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{subscribers: make(map[string]map[chan Event]struct{})}
+}
+
+func (b *LocalBroker) Publish(userID string, event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			logs.LogJSON("WARN", "Realtime event dropped for slow subscriber", map[string]interface{}{
+				"userID": userID,
+				"type":   event.Type,
+			})
+		}
+	}
+}
+
+func (b *LocalBroker) Subscribe(userID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], ch)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
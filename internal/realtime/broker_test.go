@@ -0,0 +1,39 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBroker(t *testing.T) {
+	b := NewLocalBroker()
+
+	alice1, unsubscribeAlice1 := b.Subscribe("alice")
+	alice2, unsubscribeAlice2 := b.Subscribe("alice")
+	bob, unsubscribeBob := b.Subscribe("bob")
+	defer unsubscribeAlice2()
+	defer unsubscribeBob()
+
+	event := Event{Type: EventMessageNew, Data: "hello"}
+	b.Publish("alice", event)
+
+	// Toutes les connexions d'alice reçoivent l'événement, pas celles de bob
+	assert.Equal(t, event, <-alice1)
+	assert.Equal(t, event, <-alice2)
+	assert.Len(t, bob, 0)
+
+	// Après désinscription, le canal est fermé et ne reçoit plus rien
+	unsubscribeAlice1()
+	unsubscribeAlice1()
+	b.Publish("alice", event)
+	_, open := <-alice1
+	assert.False(t, open)
+	assert.Equal(t, event, <-alice2)
+
+	// Un abonné lent perd les événements au-delà du tampon sans bloquer la publication
+	for i := 0; i < subscriberBufferSize+5; i++ {
+		b.Publish("bob", event)
+	}
+	assert.Len(t, bob, subscriberBufferSize)
+}
@@ -3,13 +3,15 @@ package report
 import (
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/post"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
@@ -111,9 +113,15 @@ func GetReports(c *gin.Context) {
 	userID := c.GetString("user_id")
 
 	// Paramètres de pagination
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	offset := (page - 1) * limit
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
 
 	// Filtres
 	status := c.Query("status")
@@ -123,8 +131,7 @@ func GetReports(c *gin.Context) {
 	// Construction de la requête
 	query := database.DB.Model(&Report{}).
 		Preload("Reporter").
-		Preload("Admin").
-		Order("created_at DESC")
+		Preload("Admin")
 
 	// Application des filtres
 	if status != "" {
@@ -139,11 +146,19 @@ func GetReports(c *gin.Context) {
 
 	// Compter le total
 	var total int64
-	query.Count(&total)
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des signalements"})
+		logs.LogJSON("ERROR", "Error counting reports", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
 
 	// Récupérer les signalements avec pagination
 	var reports []Report
-	if err := query.Limit(limit).Offset(offset).Find(&reports).Error; err != nil {
+	if err := query.Scopes(page.Scope("reports.created_at", "reports.id")).Find(&reports).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des signalements"})
 		logs.LogJSON("ERROR", "Error fetching reports", map[string]interface{}{
 			"error":  err.Error(),
@@ -153,6 +168,30 @@ func GetReports(c *gin.Context) {
 		return
 	}
 
+	reports, envelope := pagination.Paginate(page, reports, func(r Report) (time.Time, string) {
+		return r.CreatedAt, r.ID
+	})
+	envelope.Total = &total
+
+	// Nombre de révisions des posts signalés : l'historique signé n'est chargé que par
+	// GET /api/posts/:id/revisions, à l'ouverture d'un signalement
+	var reportedPostIDs []string
+	for _, report := range reports {
+		if report.TargetType == ReportTypePost {
+			reportedPostIDs = append(reportedPostIDs, report.TargetID)
+		}
+	}
+	revisionCounts, err := post.RevisionCounts(reportedPostIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des signalements"})
+		logs.LogJSON("ERROR", "Error counting reported post revisions", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
 	// Enrichir avec les détails des cibles
 	reportsWithTargets := make([]ReportWithTarget, len(reports))
 	for i, report := range reports {
@@ -162,9 +201,12 @@ func GetReports(c *gin.Context) {
 		switch report.TargetType {
 		case ReportTypePost:
 			var targetPost post.Post
-			if err := database.DB.First(&targetPost, "id = ?", report.TargetID).Error; err == nil {
+			// Les modérateurs voient les médias du post signalé, y compris payants
+			if err := database.DB.Scopes(post.WithMedia).First(&targetPost, "id = ?", report.TargetID).Error; err == nil {
+				targetPost = post.SignPost(targetPost)
 				reportWithTarget.TargetPost = &targetPost
 			}
+			reportWithTarget.TargetPostRevisionCount = revisionCounts[report.TargetID]
 		case ReportTypeUser:
 			var targetUser user.User
 			if err := database.DB.First(&targetUser, "id = ?", report.TargetID).Error; err == nil {
@@ -181,13 +223,8 @@ func GetReports(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"reports": reportsWithTargets,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-			"pages": (total + int64(limit) - 1) / int64(limit),
-		},
+		"reports":    reportsWithTargets,
+		"pagination": envelope,
 	})
 
 	logs.LogJSON("INFO", "Reports fetched successfully", map[string]interface{}{
@@ -363,7 +400,7 @@ func validateTargetExists(targetType ReportType, targetID string) error {
 	switch targetType {
 	case ReportTypePost:
 		var post post.Post
-		return database.DB.First(&post, "id = ?", targetID).Error
+		return database.DB.First(&post, "id = ? AND status = ?", targetID, access.StatusPublished).Error
 	case ReportTypeUser:
 		var user user.User
 		return database.DB.First(&user, "id = ?", targetID).Error
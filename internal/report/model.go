@@ -81,9 +81,10 @@ type UpdateReportInput struct {
 // ReportWithTarget structure pour la réponse avec les détails de la cible
 type ReportWithTarget struct {
 	Report
-	TargetPost    *post.Post    `json:"target_post,omitempty"`
-	TargetUser    *user.User    `json:"target_user,omitempty"`
-	TargetComment *post.Comment `json:"target_comment,omitempty"`
+	TargetPost              *post.Post    `json:"target_post,omitempty"`
+	TargetPostRevisionCount int64         `json:"target_post_revision_count,omitempty"` // nombre de modifications, historique via GET /api/posts/:id/revisions
+	TargetUser              *user.User    `json:"target_user,omitempty"`
+	TargetComment           *post.Comment `json:"target_comment,omitempty"`
 }
 
 // Validation des raisons de signalement
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
+)
+
+// JobStatus est l'état d'une tâche affiché aux administrateurs
+type JobStatus struct {
+	Name      string    `json:"name"`
+	Schedule  string    `json:"schedule"`
+	NextRunAt time.Time `json:"next_run_at"`
+	LastRun   *Run      `json:"last_run"`
+	LastError *Run      `json:"last_error"`
+}
+
+// GetJobs GET /api/admin/jobs
+// Tâches planifiées avec leur prochaine échéance, leur dernière exécution et leur dernier échec
+func GetJobs(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	registered := Jobs()
+	statuses := make([]JobStatus, 0, len(registered))
+	for _, job := range registered {
+		status := JobStatus{
+			Name:      job.Name,
+			Schedule:  job.Spec,
+			NextRunAt: job.Schedule.Next(time.Now()),
+		}
+
+		lastRun, err := latestRun(job.Name, "")
+		if err == nil {
+			status.LastRun = lastRun
+			status.LastError, err = latestRun(job.Name, RunFailed)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des tâches"})
+			logs.LogJSON("ERROR", "Error retrieving job runs", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+				"job":    job.Name,
+			})
+			return
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": statuses})
+}
+
+// GetJobRuns GET /api/admin/jobs/:name/runs
+func GetJobRuns(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	name := c.Param("name")
+
+	if _, err := Find(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tâche introuvable"})
+		return
+	}
+
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	var runs []Run
+	if err := database.DB.Where("job_name = ?", name).
+		Scopes(page.Scope("started_at", "id")).
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération de l'historique"})
+		logs.LogJSON("ERROR", "Error retrieving job runs", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"job":    name,
+		})
+		return
+	}
+
+	runs, envelope := pagination.Paginate(page, runs, func(r Run) (time.Time, string) {
+		return r.StartedAt, r.ID
+	})
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs, "pagination": envelope})
+}
+
+// TriggerJob POST /api/admin/jobs/:name/run
+// Lance une exécution immédiate, ignorée si une autre instance exécute déjà la tâche
+func TriggerJob(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	name := c.Param("name")
+
+	if err := Trigger(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tâche introuvable"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Exécution lancée"})
+	logs.LogJSON("INFO", "Job triggered manually", map[string]interface{}{
+		"route":  route,
+		"userID": userID,
+		"job":    name,
+	})
+}
+
+// latestRun retourne la dernière exécution d'une tâche, éventuellement restreinte à un statut
+func latestRun(name, status string) (*Run, error) {
+	query := database.DB.Where("job_name = ?", name)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var run Run
+	if err := query.Order("started_at DESC").Take(&run).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
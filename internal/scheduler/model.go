@@ -0,0 +1,33 @@
+package scheduler
+
+import "time"
+
+// Statuts d'une exécution de tâche
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// Origines d'une exécution de tâche
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Run est une exécution d'une tâche planifiée, conservée pour l'historique
+type Run struct {
+	ID           string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	JobName      string     `json:"job_name" gorm:"index"`
+	Trigger      string     `json:"trigger"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	Instance     string     `json:"instance"`
+}
+
+func (Run) TableName() string {
+	return "job_runs"
+}
@@ -0,0 +1,31 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+)
+
+// RunRetention est la durée de conservation d'une exécution réussie dans l'historique.
+// Les exécutions en échec sont conservées pour le diagnostic.
+const RunRetention = 30 * 24 * time.Hour
+
+// PurgeRuns supprime de l'historique les exécutions réussies depuis plus de RunRetention :
+// la publication des posts programmés à elle seule en ajoute 1 440 par jour
+func PurgeRuns() error {
+	result := database.DB.
+		Where("status = ? AND started_at < ?", RunSucceeded, time.Now().Add(-RunRetention)).
+		Delete(&Run{})
+	if result.Error != nil {
+		return fmt.Errorf("purge de l'historique des tâches : %w", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		logs.LogJSON("INFO", "Job runs purged", map[string]interface{}{
+			"count": result.RowsAffected,
+		})
+	}
+	return nil
+}
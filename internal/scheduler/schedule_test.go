@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Mercredi 15 mai 2024, 10h17m30s
+	from := time.Date(2024, 5, 15, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 4 * * *", time.Date(2024, 5, 16, 4, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2024, 6, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 5, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		// Jour du mois et jour de la semaine restreints : le premier des deux qui arrive
+		{"0 12 1 * 1", time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)},
+		{"@every 1h", time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule.Next(from))
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@every 10s", "@every demain"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,210 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+)
+
+// ErrJobNotFound est retournée pour une tâche qui n'a pas été enregistrée
+var ErrJobNotFound = errors.New("tâche introuvable")
+
+// Job est une tâche de fond nommée, exécutée selon son planning
+type Job struct {
+	Name     string
+	Spec     string
+	Schedule Schedule
+	Run      func() error
+}
+
+var (
+	mu   sync.RWMutex
+	jobs = map[string]*Job{}
+)
+
+// Register enregistre une tâche. L'expression de planning est vérifiée au démarrage :
+// une expression invalide est une erreur de programmation et provoque un panic.
+func Register(name, spec string, run func() error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		panic(fmt.Sprintf("tâche %s : %v", name, err))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := jobs[name]; exists {
+		panic(fmt.Sprintf("tâche %s déjà enregistrée", name))
+	}
+	jobs[name] = &Job{Name: name, Spec: spec, Schedule: schedule, Run: run}
+}
+
+// Jobs retourne les tâches enregistrées, triées par nom
+func Jobs() []*Job {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	list := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Find retourne une tâche enregistrée
+func Find(name string) (*Job, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	job, ok := jobs[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Start lance une boucle par tâche enregistrée. Chaque instance du serveur calcule les mêmes échéances,
+// le verrou Postgres garantit qu'une seule d'entre elles exécute chaque échéance.
+func Start() {
+	for _, job := range Jobs() {
+		go loop(job)
+	}
+}
+
+func loop(job *Job) {
+	for {
+		next := job.Schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		time.Sleep(time.Until(next))
+
+		if _, err := Execute(job, next, TriggerSchedule); err != nil {
+			logs.LogJSON("ERROR", "Error running scheduled job", map[string]interface{}{
+				"error": err.Error(),
+				"job":   job.Name,
+			})
+		}
+	}
+}
+
+// Trigger lance immédiatement une exécution manuelle d'une tâche, en arrière-plan
+func Trigger(name string) error {
+	job, err := Find(name)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if _, err := Execute(job, time.Now(), TriggerManual); err != nil {
+			logs.LogJSON("ERROR", "Error running job manually", map[string]interface{}{
+				"error": err.Error(),
+				"job":   job.Name,
+			})
+		}
+	}()
+	return nil
+}
+
+// Execute exécute une tâche pour une échéance si cette instance obtient le verrou de la tâche.
+// Retourne nil sans erreur quand une autre instance l'exécute déjà ou a déjà traité l'échéance.
+// L'erreur de la tâche elle-même est enregistrée dans l'historique, pas retournée.
+func Execute(job *Job, scheduledFor time.Time, trigger string) (*Run, error) {
+	var run *Run
+	key := lockKey(job.Name)
+
+	// Le verrou consultatif est lié à la session Postgres : toute l'exécution passe par la même connexion
+	err := database.DB.Connection(func(conn *gorm.DB) error {
+		conn = conn.Session(&gorm.Session{NewDB: true})
+
+		var acquired bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", key).Scan(&acquired).Error; err != nil {
+			return fmt.Errorf("prise du verrou : %w", err)
+		}
+		if !acquired {
+			logs.LogJSON("INFO", "Job already running on another instance", map[string]interface{}{
+				"job": job.Name,
+			})
+			return nil
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", key)
+
+		if trigger == TriggerSchedule {
+			var done int64
+			if err := conn.Model(&Run{}).
+				Where("job_name = ? AND scheduled_for = ?", job.Name, scheduledFor).
+				Count(&done).Error; err != nil {
+				return fmt.Errorf("vérification de l'historique : %w", err)
+			}
+			if done > 0 {
+				return nil
+			}
+		}
+
+		run = &Run{
+			JobName:      job.Name,
+			Trigger:      trigger,
+			ScheduledFor: scheduledFor,
+			StartedAt:    time.Now(),
+			Status:       RunRunning,
+			Instance:     instanceName(),
+		}
+		if err := conn.Create(run).Error; err != nil {
+			return fmt.Errorf("enregistrement de l'exécution : %w", err)
+		}
+
+		runErr := safeRun(job)
+
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		run.Status = RunSucceeded
+		if runErr != nil {
+			run.Status = RunFailed
+			run.Error = runErr.Error()
+			logs.LogJSON("ERROR", "Scheduled job failed", map[string]interface{}{
+				"error": runErr.Error(),
+				"job":   job.Name,
+			})
+		}
+		return conn.Model(&Run{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+			"status":      run.Status,
+			"error":       run.Error,
+			"finished_at": finishedAt,
+		}).Error
+	})
+	return run, err
+}
+
+// safeRun exécute la tâche en transformant un panic en erreur, pour qu'il n'arrête pas le planificateur
+func safeRun(job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic : %v", r)
+		}
+	}()
+	return job.Run()
+}
+
+// lockKey dérive de son nom la clé du verrou consultatif d'une tâche
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
+
+func instanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestExecute(t *testing.T) {
+	scheduledFor := time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)
+
+	t.Run("Verrou obtenu : exécution enregistrée", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := 0
+		job := &Job{Name: "test_job", Run: func() error { calls++; return nil }}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+			WithArgs(lockKey("test_job")).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "job_runs" WHERE job_name = \$1 AND scheduled_for = \$2`).
+			WithArgs("test_job", scheduledFor).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "job_runs"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("run1"))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "job_runs" SET "error"=\$1,"finished_at"=\$2,"status"=\$3 WHERE id = \$4`).
+			WithArgs("", sqlmock.AnyArg(), RunSucceeded, "run1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).
+			WithArgs(lockKey("test_job")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		run, err := Execute(job, scheduledFor, TriggerSchedule)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, RunSucceeded, run.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Échec de la tâche : erreur conservée dans l'historique", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		job := &Job{Name: "test_job", Run: func() error { return errors.New("stripe indisponible") }}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "job_runs"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("run1"))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "job_runs"`).
+			WithArgs("stripe indisponible", sqlmock.AnyArg(), RunFailed, "run1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectExec(`SELECT pg_advisory_unlock`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		// Une exécution manuelle ne vérifie pas l'historique des échéances
+		run, err := Execute(job, scheduledFor, TriggerManual)
+
+		assert.NoError(t, err)
+		assert.Equal(t, RunFailed, run.Status)
+		assert.Equal(t, "stripe indisponible", run.Error)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Verrou détenu par une autre instance : rien n'est exécuté", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := 0
+		job := &Job{Name: "test_job", Run: func() error { calls++; return nil }}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+		run, err := Execute(job, scheduledFor, TriggerSchedule)
+
+		assert.NoError(t, err)
+		assert.Nil(t, run)
+		assert.Zero(t, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Échéance déjà traitée par une autre instance", func(t *testing.T) {
+		mock := testutil.SetupMockDB(t)
+		calls := 0
+		job := &Job{Name: "test_job", Run: func() error { calls++; return nil }}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "job_runs"`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectExec(`SELECT pg_advisory_unlock`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		run, err := Execute(job, scheduledFor, TriggerSchedule)
+
+		assert.NoError(t, err)
+		assert.Nil(t, run)
+		assert.Zero(t, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPurgeRuns(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "job_runs" WHERE status = \$1 AND started_at < \$2`).
+		WithArgs(RunSucceeded, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1440))
+	mock.ExpectCommit()
+
+	assert.NoError(t, PurgeRuns())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+)
+
+// SignedURLTTL est la durée de validité des liens vers les médias privés : assez longue pour lire
+// une vidéo, assez courte pour qu'un lien partagé ne donne pas un accès durable
+const SignedURLTTL = time.Hour
+
+// ErrObjectNotFound est retournée quand l'objet n'existe pas (encore) dans le bucket
+var ErrObjectNotFound = errors.New("objet introuvable")
+
+// PresignedRequest est une requête signée que le client envoie directement à S3
+type PresignedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"` // en-têtes signés à renvoyer tels quels
+}
+
+// ObjectInfo contient les métadonnées d'un objet déjà envoyé
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// CompletedPart identifie une partie envoyée d'un envoi en plusieurs parties
+type CompletedPart struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// Key retourne la clé S3 d'un fichier dans un dossier, comme UploadToS3
+func Key(folder, filename string) string {
+	return fmt.Sprintf("%s/%s", folder, filename)
+}
+
+// PublicURL retourne l'URL publique d'un objet. Seul le dossier des avatars est lisible par tous,
+// les médias des posts et des messages passent par SignedURL.
+func PublicURL(key string) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s3Bucket, s3Region, key)
+}
+
+// PresignGet signe la lecture d'un objet privé pour la durée donnée
+func PresignGet(key string, ttl time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(s3Client).PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("signature de la lecture : %w", err)
+	}
+	return req.URL, nil
+}
+
+// SignedURL retourne un lien temporaire vers un objet privé, ou une chaîne vide si la clé est vide
+// ou la signature impossible. À n'appeler qu'après avoir vérifié que l'utilisateur peut voir le média.
+func SignedURL(key string) string {
+	if key == "" {
+		return ""
+	}
+	url, err := PresignGet(key, SignedURLTTL)
+	if err != nil {
+		logs.LogJSON("ERROR", "Error presigning media URL", map[string]interface{}{
+			"error": err.Error(),
+			"extra": fmt.Sprintf("key : %s", key),
+		})
+		return ""
+	}
+	return url
+}
+
+// PresignPut signe un envoi en une fois : S3 refuse tout fichier d'un autre type ou d'une autre taille
+func PresignPut(key, contentType string, size int64, ttl time.Duration) (PresignedRequest, error) {
+	req, err := s3.NewPresignClient(s3Client).PresignPutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:        aws.String(s3Bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return PresignedRequest{}, fmt.Errorf("signature de l'envoi : %w", err)
+	}
+
+	headers := make(map[string]string, len(req.SignedHeader))
+	for name := range req.SignedHeader {
+		if name != "Host" {
+			headers[name] = req.SignedHeader.Get(name)
+		}
+	}
+	return PresignedRequest{Method: req.Method, URL: req.URL, Headers: headers}, nil
+}
+
+// CreateMultipartUpload démarre un envoi en plusieurs parties et retourne son identifiant S3
+func CreateMultipartUpload(key, contentType string) (string, error) {
+	out, err := s3Client.CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s3Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("création de l'envoi en plusieurs parties : %w", err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+// PresignUploadPart signe l'envoi d'une partie d'un envoi en plusieurs parties
+func PresignUploadPart(key, uploadID string, partNumber int32, ttl time.Duration) (PresignedRequest, error) {
+	req, err := s3.NewPresignClient(s3Client).PresignUploadPart(context.TODO(), &s3.UploadPartInput{
+		Bucket:     aws.String(s3Bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return PresignedRequest{}, fmt.Errorf("signature de la partie %d : %w", partNumber, err)
+	}
+	return PresignedRequest{Method: req.Method, URL: req.URL}, nil
+}
+
+// CompleteMultipartUpload assemble les parties envoyées en un seul objet
+func CompleteMultipartUpload(key, uploadID string, parts []CompletedPart) error {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	_, err := s3Client.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s3Bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("assemblage de l'envoi en plusieurs parties : %w", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload abandonne un envoi en plusieurs parties et libère les parties déjà envoyées
+func AbortMultipartUpload(key, uploadID string) error {
+	_, err := s3Client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s3Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	var noSuchUpload *types.NoSuchUpload
+	if err != nil && !errors.As(err, &noSuchUpload) {
+		return fmt.Errorf("abandon de l'envoi en plusieurs parties : %w", err)
+	}
+	return nil
+}
+
+// HeadObject retourne la taille et le type d'un objet, ErrObjectNotFound s'il n'existe pas
+func HeadObject(key string) (ObjectInfo, error) {
+	out, err := s3Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("lecture des métadonnées de l'objet : %w", err)
+	}
+	return ObjectInfo{Size: aws.ToInt64(out.ContentLength), ContentType: aws.ToString(out.ContentType)}, nil
+}
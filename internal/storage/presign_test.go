@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestClient(t *testing.T) {
+	originalClient, originalBucket, originalRegion := s3Client, s3Bucket, s3Region
+	s3Bucket, s3Region = "onlyfeed-test", "eu-west-3"
+	s3Client = s3.New(s3.Options{
+		Region:      s3Region,
+		Credentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+	})
+	t.Cleanup(func() {
+		s3Client, s3Bucket, s3Region = originalClient, originalBucket, originalRegion
+	})
+}
+
+func TestSignedURL(t *testing.T) {
+	setupTestClient(t)
+
+	signed, err := url.Parse(SignedURL("posts/post_1.jpg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/posts/post_1.jpg", signed.Path)
+	assert.Equal(t, "3600", signed.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, signed.Query().Get("X-Amz-Signature"))
+
+	assert.Empty(t, SignedURL(""))
+}
+
+func TestPresignPutSignsTypeAndSize(t *testing.T) {
+	setupTestClient(t)
+
+	req, err := PresignPut("posts/upload_1.mp4", "video/mp4", 1024, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "video/mp4", req.Headers["Content-Type"])
+
+	signed, err := url.Parse(req.URL)
+	assert.NoError(t, err)
+	assert.Contains(t, signed.Query().Get("X-Amz-SignedHeaders"), "content-length")
+	assert.Contains(t, signed.Query().Get("X-Amz-SignedHeaders"), "content-type")
+}
@@ -36,8 +36,10 @@ func InitS3() error {
 	return nil
 }
 
+// UploadToS3 envoie un fichier dans le bucket privé et retourne sa clé, à convertir en URL
+// signée (SignedURL) ou publique pour les avatars (PublicURL) au moment de l'affichage
 func UploadToS3(file multipart.File, filename string, contentType string, folder string) (string, error) {
-	key := fmt.Sprintf("%s/%s", folder, filename)
+	key := Key(folder, filename)
 
 	_, err := s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
 		Bucket:      aws.String(s3Bucket),
@@ -49,8 +51,7 @@ func UploadToS3(file multipart.File, filename string, contentType string, folder
 		return "", fmt.Errorf("upload échoué: %w", err)
 	}
 
-	publicURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s3Bucket, s3Region, key)
-	return publicURL, nil
+	return key, nil
 }
 
 func DeleteFromS3(key string) error {
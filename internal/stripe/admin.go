@@ -0,0 +1,144 @@
+package stripe
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v78"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/pagination"
+)
+
+// GetStripeEvents GET /api/admin/stripe/events
+// Liste les événements Stripe reçus, par défaut ceux en échec
+func GetStripeEvents(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	page, err := pagination.FromRequest(c, pagination.DefaultLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Curseur de pagination invalide"})
+		logs.LogJSON("WARN", "Invalid pagination cursor", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	status := c.DefaultQuery("status", EventStatusFailed)
+
+	query := database.DB.Model(&StripeEvent{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+
+	var events []StripeEvent
+	if err := query.Scopes(page.Scope("stripe_events.created_at", "stripe_events.id")).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des événements Stripe"})
+		logs.LogJSON("ERROR", "Error fetching Stripe events", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	events, envelope := pagination.Paginate(page, events, func(e StripeEvent) (time.Time, string) {
+		return e.CreatedAt, e.ID
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":     events,
+		"pagination": envelope,
+	})
+}
+
+// RetryStripeEvent POST /api/admin/stripe/events/:id/retry
+// Rejoue un événement en échec à partir du payload enregistré
+func RetryStripeEvent(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	eventID := c.Param("id")
+
+	var record StripeEvent
+	if err := database.DB.First(&record, "id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Événement introuvable"})
+			logs.LogJSON("WARN", "Stripe event not found", map[string]interface{}{
+				"route":   route,
+				"userID":  userID,
+				"eventID": eventID,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
+		logs.LogJSON("ERROR", "Database error", map[string]interface{}{
+			"error":   err.Error(),
+			"route":   route,
+			"userID":  userID,
+			"eventID": eventID,
+		})
+		return
+	}
+
+	var event stripe.Event
+	if err := json.Unmarshal([]byte(record.Payload), &event); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Payload de l'événement illisible"})
+		logs.LogJSON("ERROR", "Error decoding stored Stripe event", map[string]interface{}{
+			"error":   err.Error(),
+			"route":   route,
+			"userID":  userID,
+			"eventID": eventID,
+		})
+		return
+	}
+
+	claimed, err := reclaimEvent(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la reprise de l'événement"})
+		logs.LogJSON("ERROR", "Error reclaiming Stripe event", map[string]interface{}{
+			"error":   err.Error(),
+			"route":   route,
+			"userID":  userID,
+			"eventID": eventID,
+		})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Événement déjà traité ou en cours de traitement"})
+		logs.LogJSON("WARN", "Stripe event not retryable", map[string]interface{}{
+			"route":   route,
+			"userID":  userID,
+			"eventID": eventID,
+			"status":  record.Status,
+		})
+		return
+	}
+
+	if err := processEvent(event); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Échec du traitement de l'événement", "details": err.Error()})
+		logs.LogJSON("ERROR", "Error retrying Stripe event", map[string]interface{}{
+			"error":   err.Error(),
+			"route":   route,
+			"userID":  userID,
+			"eventID": eventID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Événement traité avec succès"})
+	logs.LogJSON("INFO", "Stripe event retried successfully", map[string]interface{}{
+		"route":   route,
+		"userID":  userID,
+		"eventID": eventID,
+	})
+}
@@ -0,0 +1,109 @@
+package stripe
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stripe/stripe-go/v78"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+)
+
+// Statuts de traitement d'un événement Stripe
+const (
+	EventStatusProcessing = "processing"
+	EventStatusProcessed  = "processed"
+	EventStatusFailed     = "failed"
+)
+
+// staleProcessingDelay au-delà duquel un événement resté "processing" peut être repris
+// (processus interrompu pendant le traitement)
+const staleProcessingDelay = 5 * time.Minute
+
+// StripeEvent journalise chaque événement reçu pour rendre le webhook idempotent
+type StripeEvent struct {
+	ID          string     `json:"id" gorm:"primaryKey"` // ID de l'événement Stripe (evt_...)
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Type        string     `json:"type"`
+	Account     string     `json:"account"`
+	Payload     string     `json:"-" gorm:"type:jsonb"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// claimEvent enregistre l'événement et réserve son traitement.
+// Elle retourne false si l'événement a déjà été traité ou est en cours de traitement.
+func claimEvent(event stripe.Event, payload []byte) (bool, error) {
+	now := time.Now()
+	record := StripeEvent{
+		ID:        event.ID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Type:      string(event.Type),
+		Account:   event.Account,
+		Payload:   string(payload),
+		Status:    EventStatusProcessing,
+		Attempts:  1,
+	}
+
+	inserted := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if inserted.Error != nil {
+		return false, fmt.Errorf("enregistrement de l'événement %s : %w", event.ID, inserted.Error)
+	}
+	if inserted.RowsAffected == 1 {
+		return true, nil
+	}
+
+	return reclaimEvent(event.ID)
+}
+
+// reclaimEvent réserve à nouveau un événement en échec ou abandonné en cours de traitement
+func reclaimEvent(eventID string) (bool, error) {
+	now := time.Now()
+	result := database.DB.Model(&StripeEvent{}).
+		Where("id = ?", eventID).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			EventStatusFailed, EventStatusProcessing, now.Add(-staleProcessingDelay)).
+		Updates(map[string]interface{}{
+			"status":     EventStatusProcessing,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("reprise de l'événement %s : %w", eventID, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// processEvent traite un événement réservé et enregistre le résultat
+func processEvent(event stripe.Event) error {
+	handleErr := handleEvent(event)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       EventStatusProcessed,
+		"last_error":   "",
+		"processed_at": now,
+		"updated_at":   now,
+	}
+	if handleErr != nil {
+		updates = map[string]interface{}{
+			"status":     EventStatusFailed,
+			"last_error": handleErr.Error(),
+			"updated_at": now,
+		}
+	}
+
+	if err := database.DB.Model(&StripeEvent{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+		if handleErr != nil {
+			return handleErr
+		}
+		return fmt.Errorf("mise à jour de l'événement %s : %w", event.ID, err)
+	}
+	return handleErr
+}
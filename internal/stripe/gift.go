@@ -0,0 +1,279 @@
+package stripe
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/checkout/session"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/earnings"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/gift"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// checkoutKindGift identifie dans les metadata une session d'abonnement offert
+const checkoutKindGift = "gift"
+
+// CreateGiftSession POST /api/stripe/create-gift-session/:creator_id
+// Crée un abonnement offert en attente et la session de paiement unique des N mois pour le bénéficiaire
+func CreateGiftSession(c *gin.Context) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	domain := os.Getenv("DOMAIN_URL")
+	route := c.FullPath()
+
+	creatorID := c.Param("creator_id")
+	userID := c.GetString("user_id")
+	userEmail := c.GetString("user_email")
+
+	var input gift.CreateGiftInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		logs.LogJSON("WARN", "Invalid gift input", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+	if input.Months < gift.MinMonths || input.Months > gift.MaxMonths {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La durée offerte doit être comprise entre %d et %d mois", gift.MinMonths, gift.MaxMonths)})
+		return
+	}
+	if utf8.RuneCountInString(input.Message) > gift.MaxMessageLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le message ne doit pas dépasser %d caractères", gift.MaxMessageLength)})
+		return
+	}
+
+	var creator user.User
+	if err := database.DB.First(&creator, "id = ? AND is_creator = true", creatorID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Créateur introuvable"})
+		return
+	}
+	if creator.StripeAccountID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le créateur n'a pas de compte Stripe"})
+		return
+	}
+
+	var recipient user.User
+	if err := database.DB.First(&recipient, "username = ?", input.RecipientUsername).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bénéficiaire introuvable"})
+		return
+	}
+	if recipient.ID == userID || recipient.ID == creator.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bénéficiaire invalide"})
+		return
+	}
+
+	// Un abonnement offert en cours peut être prolongé, pas un abonnement récurrent, même impayé ou en pause
+	var existing subscription.Subscription
+	err := database.DB.Where("subscriber_id = ? AND creator_id = ?", recipient.ID, creator.ID).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification de l'abonnement"})
+		logs.LogJSON("ERROR", "Subscription verification error", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     route,
+			"userID":    userID,
+			"creatorID": creator.ID,
+		})
+		return
+	}
+	if err == nil && existing.Status != subscription.StatusGifted && (subscription.GrantsAccess(existing.Status) || existing.Recurring()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ce bénéficiaire est déjà abonné à ce créateur"})
+		return
+	}
+
+	newGift := gift.Gift{
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		BuyerID:     userID,
+		RecipientID: recipient.ID,
+		CreatorID:   creator.ID,
+		Months:      input.Months,
+		Amount:      creator.SubscriptionPrice * float64(input.Months),
+		Message:     input.Message,
+		Status:      gift.StatusPending,
+	}
+	if err := database.DB.Create(&newGift).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du cadeau"})
+		logs.LogJSON("ERROR", "Error creating gift", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	amount := billing.ToCents(newGift.Amount)
+	fee := int64(float64(amount) * platformFeePercent / 100)
+
+	sessionParams := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(fmt.Sprintf("%s/%s?gift=success&gift_id=%s", domain, creator.Username, newGift.ID)),
+		CancelURL:  stripe.String(fmt.Sprintf("%s/%s?gift=error&gift_id=%s", domain, creator.Username, newGift.ID)),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency:   stripe.String("eur"),
+					UnitAmount: stripe.Int64(amount),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(fmt.Sprintf("%d mois d'abonnement à %s offerts à %s", input.Months, creator.Username, recipient.Username)),
+					},
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		CustomerEmail: stripe.String(userEmail),
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			ApplicationFeeAmount: stripe.Int64(fee),
+		},
+		Metadata: map[string]string{
+			"kind":         checkoutKindGift,
+			"gift_id":      newGift.ID,
+			"creator_id":   creator.ID,
+			"buyer_id":     userID,
+			"recipient_id": recipient.ID,
+			"platform_fee": strconv.FormatInt(fee, 10),
+		},
+	}
+	sessionParams.SetStripeAccount(creator.StripeAccountID)
+
+	createdSession, err := session.New(sessionParams)
+	if err != nil {
+		database.DB.Delete(&newGift)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création session Stripe"})
+		logs.LogJSON("ERROR", "Error creating Stripe gift session", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     route,
+			"userID":    userID,
+			"creatorID": creator.ID,
+		})
+		return
+	}
+
+	database.DB.Model(&newGift).Update("stripe_session_id", createdSession.ID)
+
+	c.JSON(http.StatusOK, gin.H{"url": createdSession.URL, "gift_id": newGift.ID})
+}
+
+// handleGiftCompleted confirme un abonnement offert payé via Stripe Checkout et l'accorde au bénéficiaire
+func handleGiftCompleted(checkout stripe.CheckoutSession) error {
+	giftID := checkout.Metadata["gift_id"]
+	if giftID == "" {
+		logs.LogJSON("WARN", "Missing checkout session metadata", map[string]interface{}{
+			"sessionID": checkout.ID,
+		})
+		return nil
+	}
+
+	// Les moyens de paiement différés sont confirmés par checkout.session.async_payment_succeeded
+	if checkout.PaymentStatus != stripe.CheckoutSessionPaymentStatusPaid {
+		logs.LogJSON("INFO", "Gift payment pending", map[string]interface{}{
+			"sessionID": checkout.ID,
+			"giftID":    giftID,
+		})
+		return nil
+	}
+
+	var paidGift gift.Gift
+	if err := database.DB.First(&paidGift, "id = ?", giftID).Error; err != nil {
+		return fmt.Errorf("récupération du cadeau %s : %w", giftID, err)
+	}
+
+	fee, _ := strconv.ParseInt(checkout.Metadata["platform_fee"], 10, 64)
+	paymentID := checkout.ID
+	if checkout.PaymentIntent != nil && checkout.PaymentIntent.ID != "" {
+		paymentID = checkout.PaymentIntent.ID
+	}
+	now := time.Now()
+
+	// La confirmation, l'abonnement et le revenu du créateur sont enregistrés ensemble :
+	// un webhook rejoué n'accorde rien deux fois
+	granted, refused := false, false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&gift.Gift{}).
+			Where("id = ? AND status = ?", giftID, gift.StatusPending).
+			Updates(map[string]interface{}{
+				"status":     gift.StatusPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("confirmation du cadeau %s : %w", giftID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if _, err := gift.Grant(tx, paidGift); err != nil {
+			// Le bénéficiaire s'est abonné entre-temps : le cadeau est marqué à rembourser
+			// et le paiement n'est pas compté dans les revenus du créateur
+			if errors.Is(err, gift.ErrAlreadySubscribed) {
+				refused = true
+				return tx.Model(&gift.Gift{}).
+					Where("id = ?", giftID).
+					Update("status", gift.StatusRefundPending).Error
+			}
+			return err
+		}
+		granted = true
+
+		return earnings.RecordTx(tx, earnings.Payment{
+			CreatedAt:      now,
+			StripeObjectID: paymentID,
+			Kind:           earnings.KindGift,
+			CreatorID:      paidGift.CreatorID,
+			PayerID:        paidGift.BuyerID,
+			Amount:         checkout.AmountTotal,
+			PlatformFee:    fee,
+			Currency:       string(checkout.Currency),
+			PaidAt:         now,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if refused {
+		logs.LogJSON("WARN", "Gift recipient already subscribed, refund pending", map[string]interface{}{
+			"userID":    paidGift.RecipientID,
+			"creatorID": paidGift.CreatorID,
+			"giftID":    giftID,
+			"paymentID": paymentID,
+		})
+	}
+	if !granted {
+		return nil
+	}
+
+	logs.LogJSON("INFO", "Gift subscription granted", map[string]interface{}{
+		"userID":    paidGift.BuyerID,
+		"creatorID": paidGift.CreatorID,
+		"giftID":    giftID,
+	})
+
+	notification.Emit(notification.Event{
+		RecipientID: paidGift.RecipientID,
+		ActorID:     paidGift.BuyerID,
+		Type:        notification.TypeGift,
+		TargetID:    paidGift.CreatorID,
+	})
+	notification.Emit(notification.Event{
+		RecipientID: paidGift.CreatorID,
+		ActorID:     paidGift.RecipientID,
+		Type:        notification.TypeSubscription,
+		TargetID:    paidGift.CreatorID,
+	})
+	return nil
+}
@@ -119,7 +119,7 @@ func CompleteConnect(c *gin.Context) {
 	// Mise à jour de l’utilisateur : il devient créateur avec un prix par défaut de 5€
 	updateData := map[string]interface{}{
 		"is_creator":         true,
-		"subscription_price": 5.0,
+		"subscription_price": defaultSubscriptionPrice,
 	}
 
 	if err := database.DB.Model(&user.User{}).Where("id = ?", userId).Updates(updateData).Error; err != nil {
@@ -138,14 +138,3 @@ func CompleteConnect(c *gin.Context) {
 		"route":  c.FullPath(),
 	})
 }
-
-//// Exemple d’intention de paiement avec commission de 10%
-//params := &stripe.PaymentIntentParams{
-//Amount:   stripe.Int64(1000), // 10.00€
-//Currency: stripe.String(string(stripe.CurrencyEUR)),
-//ApplicationFeeAmount: stripe.Int64(100), // 1.00€ pour toi
-//TransferData: &stripe.PaymentIntentTransferDataParams{
-//Destination: stripe.String(creatorsStripeAccountID), // ID du créateur
-//},
-//}
-//intent, err := paymentintent.New(params)
@@ -0,0 +1,228 @@
+package stripe
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stripe/stripe-go/v78"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/earnings"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// defaultSubscriptionPrice est le prix attribué à un nouveau créateur
+const defaultSubscriptionPrice = 5.0
+
+// platformFeePercent est la commission de la plateforme sur les paiements des créateurs
+const platformFeePercent = 20.0
+
+// handleSubscriptionChanged synchronise le statut et la fin de période d'un abonnement
+// (customer.subscription.updated et customer.subscription.deleted)
+func handleSubscriptionChanged(sub stripe.Subscription) error {
+	status := subscription.StatusFromStripe(string(sub.Status))
+	updates := map[string]interface{}{
+		"status": status,
+	}
+	if end := unixTime(sub.CurrentPeriodEnd); end != nil {
+		updates["current_period_end"] = end
+	}
+	if status != subscription.StatusCancelled {
+		return updateSubscription(sub.ID, nil, updates)
+	}
+
+	cancelledAt := cancellationTime(sub)
+	updates["cancelled_at"] = cancelledAt
+	if err := updateSubscription(sub.ID, nil, updates); err != nil {
+		return err
+	}
+	return subscription.CloseStripePeriod(database.DB, sub.ID, cancelledAt)
+}
+
+// handleInvoicePaid rétablit un abonnement en défaut de paiement et prolonge sa période
+func handleInvoicePaid(invoice stripe.Invoice) error {
+	if invoice.Subscription == nil || invoice.Subscription.ID == "" {
+		return nil
+	}
+
+	// Le prix payé peut différer du prix de souscription après un changement de prix du créateur
+	updates := map[string]interface{}{}
+	if line := subscriptionLine(invoice); line != nil {
+		if line.Period != nil {
+			if end := unixTime(line.Period.End); end != nil {
+				updates["current_period_end"] = end
+			}
+		}
+		if line.Amount > 0 {
+			updates["price"] = float64(line.Amount) / 100
+		}
+	}
+	if err := updateSubscription(invoice.Subscription.ID, nil, updates); err != nil {
+		return err
+	}
+
+	if err := recordInvoicePayment(invoice); err != nil {
+		return err
+	}
+
+	// Seul un abonnement en défaut redevient actif : un abonnement en essai le reste
+	return updateSubscription(
+		invoice.Subscription.ID,
+		[]string{subscription.StatusPastDue, subscription.StatusUnpaid, subscription.StatusIncomplete},
+		map[string]interface{}{"status": subscription.StatusActive},
+	)
+}
+
+// recordInvoicePayment enregistre le paiement d'une facture pour les revenus du créateur
+func recordInvoicePayment(invoice stripe.Invoice) error {
+	if invoice.AmountPaid == 0 {
+		return nil
+	}
+
+	var sub subscription.Subscription
+	if err := database.DB.Where("stripe_subscription_id = ?", invoice.Subscription.ID).First(&sub).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logs.LogJSON("WARN", "Unknown Stripe subscription", map[string]interface{}{
+				"subscriptionID": invoice.Subscription.ID,
+				"invoiceID":      invoice.ID,
+			})
+			return nil
+		}
+		return fmt.Errorf("récupération de l'abonnement %s : %w", invoice.Subscription.ID, err)
+	}
+
+	paidAt := time.Now()
+	if invoice.StatusTransitions != nil {
+		if t := unixTime(invoice.StatusTransitions.PaidAt); t != nil {
+			paidAt = *t
+		}
+	}
+
+	return earnings.Record(earnings.Payment{
+		CreatedAt:      time.Now(),
+		StripeObjectID: invoice.ID,
+		Kind:           earnings.KindSubscription,
+		CreatorID:      sub.CreatorID,
+		PayerID:        sub.SubscriberID,
+		Amount:         invoice.AmountPaid,
+		PlatformFee:    invoice.ApplicationFeeAmount,
+		Currency:       string(invoice.Currency),
+		PaidAt:         paidAt,
+	})
+}
+
+// handleInvoicePaymentFailed passe un abonnement actif en défaut de paiement
+func handleInvoicePaymentFailed(invoice stripe.Invoice) error {
+	if invoice.Subscription == nil || invoice.Subscription.ID == "" {
+		return nil
+	}
+
+	return updateSubscription(
+		invoice.Subscription.ID,
+		[]string{subscription.StatusActive, subscription.StatusTrialing},
+		map[string]interface{}{"status": subscription.StatusPastDue},
+	)
+}
+
+// handleAccountUpdated suit l'activation des comptes Stripe Connect des créateurs
+func handleAccountUpdated(acct stripe.Account) error {
+	var creator user.User
+	if err := database.DB.First(&creator, "stripe_account_id = ?", acct.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logs.LogJSON("WARN", "Unknown Stripe account", map[string]interface{}{
+				"accountID": acct.ID,
+			})
+			return nil
+		}
+		return fmt.Errorf("récupération du compte %s : %w", acct.ID, err)
+	}
+
+	if !acct.ChargesEnabled {
+		if creator.IsCreator {
+			logs.LogJSON("WARN", "Stripe account charges disabled", map[string]interface{}{
+				"userID":    creator.ID,
+				"accountID": acct.ID,
+			})
+		}
+		return nil
+	}
+
+	if creator.IsCreator {
+		return nil
+	}
+
+	// Onboarding terminé sans retour sur /complete-connect : l'utilisateur devient créateur
+	updateData := map[string]interface{}{
+		"is_creator":         true,
+		"subscription_price": defaultSubscriptionPrice,
+	}
+	if err := database.DB.Model(&user.User{}).Where("id = ?", creator.ID).Updates(updateData).Error; err != nil {
+		return fmt.Errorf("mise à jour du créateur %s : %w", creator.ID, err)
+	}
+
+	logs.LogJSON("INFO", "User updated to creator from Stripe account", map[string]interface{}{
+		"userID":    creator.ID,
+		"accountID": acct.ID,
+	})
+	return nil
+}
+
+// updateSubscription met à jour l'abonnement local lié à un abonnement Stripe,
+// éventuellement seulement s'il est dans l'un des statuts donnés
+func updateSubscription(stripeSubscriptionID string, fromStatuses []string, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	query := database.DB.Model(&subscription.Subscription{}).
+		Where("stripe_subscription_id = ?", stripeSubscriptionID)
+	if fromStatuses != nil {
+		query = query.Where("status IN ?", fromStatuses)
+	}
+
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("mise à jour de l'abonnement %s : %w", stripeSubscriptionID, result.Error)
+	}
+	if result.RowsAffected == 0 && fromStatuses == nil {
+		logs.LogJSON("WARN", "Unknown Stripe subscription", map[string]interface{}{
+			"subscriptionID": stripeSubscriptionID,
+		})
+	}
+	return nil
+}
+
+// subscriptionLine retourne la ligne d'abonnement d'une facture
+func subscriptionLine(invoice stripe.Invoice) *stripe.InvoiceLineItem {
+	if invoice.Lines == nil {
+		return nil
+	}
+	for _, line := range invoice.Lines.Data {
+		if line.Type == stripe.InvoiceLineItemTypeSubscription {
+			return line
+		}
+	}
+	return nil
+}
+
+// cancellationTime retourne la date de fin effective d'un abonnement annulé
+func cancellationTime(sub stripe.Subscription) time.Time {
+	for _, timestamp := range []int64{sub.EndedAt, sub.CanceledAt} {
+		if t := unixTime(timestamp); t != nil {
+			return *t
+		}
+	}
+	return time.Now()
+}
+
+func unixTime(timestamp int64) *time.Time {
+	if timestamp == 0 {
+		return nil
+	}
+	t := time.Unix(timestamp, 0).UTC()
+	return &t
+}
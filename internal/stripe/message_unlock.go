@@ -0,0 +1,176 @@
+package stripe
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/checkout/session"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/earnings"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/message"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// checkoutKindMessageUnlock identifie dans les metadata une session d'achat de média en message privé
+const checkoutKindMessageUnlock = "message_unlock"
+
+// CreateMessageUnlockSession POST /api/stripe/create-message-unlock-session/:message_id
+// Crée une session de paiement Stripe pour débloquer le média payant d'un message reçu
+func CreateMessageUnlockSession(c *gin.Context) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	domain := os.Getenv("DOMAIN_URL")
+	route := c.FullPath()
+
+	messageID := c.Param("message_id")
+	userID := c.GetString("user_id")
+	userEmail := c.GetString("user_email")
+
+	var msg message.Message
+	if err := database.DB.First(&msg, "id = ? AND receiver_id = ? AND is_deleted = false", messageID, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message non trouvé"})
+		logs.LogJSON("WARN", "Message not found", map[string]interface{}{
+			"route":     route,
+			"userID":    userID,
+			"messageID": messageID,
+		})
+		return
+	}
+	if msg.Price == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ce message n'est pas payant"})
+		return
+	}
+	if msg.UnlockedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vous avez déjà débloqué ce message"})
+		return
+	}
+
+	var creator user.User
+	if err := database.DB.First(&creator, "id = ?", msg.SenderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Créateur introuvable"})
+		return
+	}
+	if creator.StripeAccountID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le créateur n'a pas de compte Stripe"})
+		return
+	}
+
+	amount := billing.ToCents(*msg.Price)
+	fee := int64(float64(amount) * platformFeePercent / 100)
+
+	sessionParams := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(fmt.Sprintf("%s/messages/%s?unlock=success&message_id=%s", domain, msg.ConversationID, msg.ID)),
+		CancelURL:  stripe.String(fmt.Sprintf("%s/messages/%s?unlock=error&message_id=%s", domain, msg.ConversationID, msg.ID)),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency:   stripe.String("eur"),
+					UnitAmount: stripe.Int64(amount),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(fmt.Sprintf("Média privé de %s", creator.Username)),
+					},
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		CustomerEmail: stripe.String(userEmail),
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			ApplicationFeeAmount: stripe.Int64(fee),
+		},
+		Metadata: map[string]string{
+			"kind":            checkoutKindMessageUnlock,
+			"message_id":      msg.ID,
+			"conversation_id": msg.ConversationID,
+			"creator_id":      creator.ID,
+			"buyer_id":        userID,
+			"platform_fee":    strconv.FormatInt(fee, 10),
+		},
+	}
+	sessionParams.SetStripeAccount(creator.StripeAccountID)
+
+	createdSession, err := session.New(sessionParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création session Stripe"})
+		logs.LogJSON("ERROR", "Error creating Stripe message unlock session", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     route,
+			"userID":    userID,
+			"messageID": messageID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": createdSession.URL})
+}
+
+// handleMessageUnlockCompleted débloque le média d'un message payé via Stripe Checkout
+func handleMessageUnlockCompleted(checkout stripe.CheckoutSession) error {
+	messageID := checkout.Metadata["message_id"]
+	buyerID := checkout.Metadata["buyer_id"]
+	creatorID := checkout.Metadata["creator_id"]
+
+	if messageID == "" || buyerID == "" || creatorID == "" {
+		logs.LogJSON("WARN", "Missing checkout session metadata", map[string]interface{}{
+			"sessionID": checkout.ID,
+		})
+		return nil
+	}
+
+	// Les moyens de paiement différés sont confirmés par checkout.session.async_payment_succeeded
+	if checkout.PaymentStatus != stripe.CheckoutSessionPaymentStatusPaid {
+		logs.LogJSON("INFO", "Message unlock payment pending", map[string]interface{}{
+			"sessionID": checkout.ID,
+			"userID":    buyerID,
+			"messageID": messageID,
+		})
+		return nil
+	}
+
+	fee, _ := strconv.ParseInt(checkout.Metadata["platform_fee"], 10, 64)
+	paymentID := checkout.ID
+	if checkout.PaymentIntent != nil && checkout.PaymentIntent.ID != "" {
+		paymentID = checkout.PaymentIntent.ID
+	}
+	if err := earnings.Record(earnings.Payment{
+		CreatedAt:      time.Now(),
+		StripeObjectID: paymentID,
+		Kind:           earnings.KindMessageUnlock,
+		CreatorID:      creatorID,
+		PayerID:        buyerID,
+		Amount:         checkout.AmountTotal,
+		PlatformFee:    fee,
+		Currency:       string(checkout.Currency),
+		PaidAt:         time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	unlocked, err := message.UnlockMessage(messageID, buyerID)
+	if err != nil {
+		return err
+	}
+	if !unlocked {
+		return nil
+	}
+
+	logs.LogJSON("INFO", "Message unlocked", map[string]interface{}{
+		"userID":    buyerID,
+		"messageID": messageID,
+	})
+	notification.Emit(notification.Event{
+		RecipientID: creatorID,
+		ActorID:     buyerID,
+		Type:        notification.TypeMessageUnlock,
+		TargetID:    checkout.Metadata["conversation_id"],
+	})
+	return nil
+}
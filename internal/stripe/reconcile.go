@@ -0,0 +1,221 @@
+package stripe
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/stripe/stripe-go/v78"
+	stripesub "github.com/stripe/stripe-go/v78/subscription"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+)
+
+// ReconcileReport est le compte rendu d'une réconciliation des abonnements locaux avec Stripe
+type ReconcileReport struct {
+	StartedAt            time.Time         `json:"started_at"`
+	FinishedAt           time.Time         `json:"finished_at"`
+	DryRun               bool              `json:"dry_run"`
+	CreatorsChecked      int               `json:"creators_checked"`
+	SubscriptionsChecked int               `json:"subscriptions_checked"`
+	Changes              []ReconcileChange `json:"changes"`
+	MissingLocally       []string          `json:"missing_locally"`   // abonnements Stripe sans ligne locale
+	MissingInStripe      []string          `json:"missing_in_stripe"` // abonnements locaux inconnus de Stripe
+	Errors               []ReconcileError  `json:"errors"`
+}
+
+// ReconcileChange est un écart entre l'abonnement local et Stripe, corrigé sauf en mode simulation
+type ReconcileChange struct {
+	SubscriptionID       string      `json:"subscription_id"`
+	StripeSubscriptionID string      `json:"stripe_subscription_id"`
+	CreatorID            string      `json:"creator_id"`
+	SubscriberID         string      `json:"subscriber_id"`
+	Field                string      `json:"field"`
+	Local                interface{} `json:"local"`
+	Stripe               interface{} `json:"stripe"`
+}
+
+// ReconcileError est l'échec de la réconciliation d'un créateur, les autres créateurs sont tout de même traités
+type ReconcileError struct {
+	CreatorID string `json:"creator_id"`
+	Error     string `json:"error"`
+}
+
+// ReconcileSubscriptions est la tâche planifiée de réconciliation : les écarts sont corrigés et journalisés
+func ReconcileSubscriptions() error {
+	report, err := Reconcile(false)
+	if err != nil {
+		return err
+	}
+
+	logs.LogJSON("INFO", "Stripe subscriptions reconciled", map[string]interface{}{
+		"creators":        report.CreatorsChecked,
+		"subscriptions":   report.SubscriptionsChecked,
+		"changes":         report.Changes,
+		"missingLocally":  report.MissingLocally,
+		"missingInStripe": report.MissingInStripe,
+	})
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("réconciliation incomplète : %d créateur(s) en échec", len(report.Errors))
+	}
+	return nil
+}
+
+// Reconcile compare les abonnements locaux de chaque créateur ayant un compte Stripe avec ceux de Stripe
+// et corrige le statut, le prix et la fin de période. En mode simulation, les écarts sont seulement rapportés.
+func Reconcile(dryRun bool) (*ReconcileReport, error) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+
+	report := &ReconcileReport{
+		StartedAt:       time.Now(),
+		DryRun:          dryRun,
+		Changes:         []ReconcileChange{},
+		MissingLocally:  []string{},
+		MissingInStripe: []string{},
+		Errors:          []ReconcileError{},
+	}
+
+	var creators []struct {
+		ID              string
+		StripeAccountID string
+	}
+	if err := database.DB.Table("users").
+		Select("id, stripe_account_id").
+		Where("is_creator = true AND stripe_account_id <> ''").
+		Order("id").
+		Scan(&creators).Error; err != nil {
+		return nil, fmt.Errorf("récupération des créateurs : %w", err)
+	}
+
+	for _, creator := range creators {
+		if err := reconcileCreator(creator.ID, creator.StripeAccountID, report); err != nil {
+			report.Errors = append(report.Errors, ReconcileError{CreatorID: creator.ID, Error: err.Error()})
+			logs.LogJSON("ERROR", "Error reconciling creator subscriptions", map[string]interface{}{
+				"error":     err.Error(),
+				"creatorID": creator.ID,
+			})
+			continue
+		}
+		report.CreatorsChecked++
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// reconcileCreator réconcilie les abonnements d'un créateur sur son compte connecté
+func reconcileCreator(creatorID, stripeAccountID string, report *ReconcileReport) error {
+	var locals []subscription.Subscription
+	if err := database.DB.
+		Where("creator_id = ? AND stripe_subscription_id <> ''", creatorID).
+		Find(&locals).Error; err != nil {
+		return fmt.Errorf("récupération des abonnements locaux : %w", err)
+	}
+	byStripeID := make(map[string]subscription.Subscription, len(locals))
+	for _, local := range locals {
+		byStripeID[local.StripeSubscriptionID] = local
+	}
+
+	params := &stripe.SubscriptionListParams{Status: stripe.String("all")}
+	params.SetStripeAccount(stripeAccountID)
+	seen := make(map[string]bool)
+
+	iter := stripesub.List(params)
+	for iter.Next() {
+		remote := iter.Subscription()
+		seen[remote.ID] = true
+
+		local, ok := byStripeID[remote.ID]
+		if !ok {
+			// Sans les metadata de la session Checkout, l'abonné ne peut pas être déduit : signalé seulement
+			if subscription.GrantsAccess(subscription.StatusFromStripe(string(remote.Status))) {
+				report.MissingLocally = append(report.MissingLocally, remote.ID)
+			}
+			continue
+		}
+		report.SubscriptionsChecked++
+
+		changes, updates := diffSubscription(local, remote)
+		if len(changes) == 0 {
+			continue
+		}
+		report.Changes = append(report.Changes, changes...)
+		if report.DryRun {
+			continue
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&subscription.Subscription{}).
+				Where("id = ?", local.ID).
+				Updates(updates).Error; err != nil {
+				return fmt.Errorf("correction de l'abonnement %s : %w", remote.ID, err)
+			}
+			if cancelledAt, ok := updates["cancelled_at"].(time.Time); ok {
+				return subscription.ClosePeriod(tx, local.SubscriberID, local.CreatorID, cancelledAt)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("liste des abonnements Stripe : %w", err)
+	}
+
+	for _, local := range locals {
+		if !seen[local.StripeSubscriptionID] && local.Status != subscription.StatusCancelled {
+			report.MissingInStripe = append(report.MissingInStripe, local.StripeSubscriptionID)
+		}
+	}
+	return nil
+}
+
+// diffSubscription compare un abonnement local à son abonnement Stripe
+// et retourne les écarts avec les mises à jour qui les corrigent
+func diffSubscription(local subscription.Subscription, remote *stripe.Subscription) ([]ReconcileChange, map[string]interface{}) {
+	var changes []ReconcileChange
+	updates := map[string]interface{}{}
+	change := func(field string, localValue, stripeValue interface{}) {
+		changes = append(changes, ReconcileChange{
+			SubscriptionID:       local.ID,
+			StripeSubscriptionID: remote.ID,
+			CreatorID:            local.CreatorID,
+			SubscriberID:         local.SubscriberID,
+			Field:                field,
+			Local:                localValue,
+			Stripe:               stripeValue,
+		})
+		updates[field] = stripeValue
+	}
+
+	status := subscription.StatusFromStripe(string(remote.Status))
+	if status != local.Status {
+		change("status", local.Status, status)
+		if status == subscription.StatusCancelled && local.CancelledAt == nil {
+			updates["cancelled_at"] = cancellationTime(*remote)
+		}
+	}
+
+	if remote.Items != nil && len(remote.Items.Data) > 0 && remote.Items.Data[0].Price != nil {
+		price := float64(remote.Items.Data[0].Price.UnitAmount) / 100
+		if math.Abs(price-local.Price) >= 0.005 {
+			change("price", local.Price, price)
+		}
+	}
+
+	if end := unixTime(remote.CurrentPeriodEnd); end != nil {
+		if local.CurrentPeriodEnd == nil || !local.CurrentPeriodEnd.Equal(*end) {
+			var localEnd interface{}
+			if local.CurrentPeriodEnd != nil {
+				localEnd = *local.CurrentPeriodEnd
+			}
+			change("current_period_end", localEnd, *end)
+		}
+	}
+
+	return changes, updates
+}
@@ -0,0 +1,134 @@
+package stripe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v78"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+// stubStripeSubscriptions sert la liste des abonnements Stripe de chaque compte connecté
+func stubStripeSubscriptions(t *testing.T, byAccount map[string][]map[string]interface{}) {
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_stub")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/subscriptions" {
+			http.NotFound(w, r)
+			return
+		}
+		data := byAccount[r.Header.Get("Stripe-Account")]
+		if data == nil {
+			data = []map[string]interface{}{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"object":   "list",
+			"url":      "/v1/subscriptions",
+			"has_more": false,
+			"data":     data,
+		})
+	}))
+
+	original := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:           stripe.String(server.URL),
+		LeveledLogger: &stripe.LeveledLogger{Level: stripe.LevelNull},
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, original)
+		server.Close()
+	})
+}
+
+func stripeSubscription(id, status string, unitAmount int64, periodEnd time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 id,
+		"object":             "subscription",
+		"status":             status,
+		"current_period_end": periodEnd.Unix(),
+		"items": map[string]interface{}{
+			"object": "list",
+			"data": []map[string]interface{}{
+				{"id": "si_" + id, "object": "subscription_item", "price": map[string]interface{}{"id": "price_1", "object": "price", "unit_amount": unitAmount}},
+			},
+		},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	periodEnd := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	staleEnd := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	localColumns := []string{"id", "subscriber_id", "creator_id", "status", "stripe_subscription_id", "price", "current_period_end"}
+
+	setup := func(t *testing.T) sqlmock.Sqlmock {
+		mock := testutil.SetupMockDB(t)
+		stubStripeSubscriptions(t, map[string][]map[string]interface{}{
+			"acct_1": {
+				stripeSubscription("sub_Drift", "past_due", 500, periodEnd),
+				stripeSubscription("sub_Cancelled", "canceled", 500, periodEnd),
+				stripeSubscription("sub_InSync", "active", 800, periodEnd),
+				stripeSubscription("sub_Unknown", "active", 500, periodEnd),
+			},
+		})
+
+		mock.ExpectQuery(`SELECT id, stripe_account_id FROM "users" WHERE is_creator = true AND stripe_account_id <> ''`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "stripe_account_id"}).AddRow("creator1", "acct_1"))
+		mock.ExpectQuery(`SELECT \* FROM "subscriptions" WHERE creator_id = \$1 AND stripe_subscription_id <> ''`).
+			WithArgs("creator1").
+			WillReturnRows(sqlmock.NewRows(localColumns).
+				AddRow("s1", "fan1", "creator1", "active", "sub_Drift", 5.0, staleEnd).
+				AddRow("s2", "fan2", "creator1", "active", "sub_Cancelled", 5.0, periodEnd).
+				AddRow("s3", "fan3", "creator1", "active", "sub_InSync", 8.0, periodEnd).
+				AddRow("s4", "fan4", "creator1", "active", "sub_Gone", 5.0, periodEnd))
+		return mock
+	}
+
+	t.Run("Écarts corrigés et rapportés", func(t *testing.T) {
+		mock := setup(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "subscriptions" SET "current_period_end"=\$1,"status"=\$2 WHERE id = \$3`).
+			WithArgs(periodEnd, "past_due", "s1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "subscriptions" SET "cancelled_at"=\$1,"status"=\$2 WHERE id = \$3`).
+			WithArgs(sqlmock.AnyArg(), "cancelled", "s2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE "subscription_periods" SET "ended_at"=\$1 WHERE subscriber_id = \$2 AND creator_id = \$3 AND ended_at IS NULL`).
+			WithArgs(sqlmock.AnyArg(), "fan2", "creator1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		report, err := Reconcile(false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.CreatorsChecked)
+		assert.Equal(t, 3, report.SubscriptionsChecked)
+		assert.Len(t, report.Changes, 3)
+		assert.Equal(t, "status", report.Changes[0].Field)
+		assert.Equal(t, "current_period_end", report.Changes[1].Field)
+		assert.Equal(t, "cancelled", report.Changes[2].Stripe)
+		assert.Equal(t, []string{"sub_Unknown"}, report.MissingLocally)
+		assert.Equal(t, []string{"sub_Gone"}, report.MissingInStripe)
+		assert.Empty(t, report.Errors)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Simulation : aucune correction", func(t *testing.T) {
+		mock := setup(t)
+
+		report, err := Reconcile(true)
+
+		assert.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Len(t, report.Changes, 3)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,6 +1,7 @@
 package stripe
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -8,10 +9,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v78"
 	"github.com/stripe/stripe-go/v78/checkout/session"
-	"github.com/stripe/stripe-go/v78/price"
-	"github.com/stripe/stripe-go/v78/product"
+	"gorm.io/gorm"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
 
@@ -27,14 +30,27 @@ func CreateSubscriptionSession(c *gin.Context) {
 	var existing struct {
 		Status string
 	}
-	if err := database.DB.
+	err := database.DB.
 		Table("subscriptions").
 		Select("status").
 		Where("subscriber_id = ? AND creator_id = ?", userID, creatorID).
-		First(&existing).Error; err == nil && existing.Status == "active" {
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification de l'abonnement"})
+		logs.LogJSON("ERROR", "Subscription verification error", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     c.FullPath(),
+			"userID":    userID,
+			"creatorID": creatorID,
+		})
+		return
+	}
+	if err == nil && subscription.GrantsAccess(existing.Status) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Vous êtes déjà abonné à ce créateur"})
 		return
 	}
+	// L'essai gratuit est réservé aux abonnés qui n'ont jamais été abonnés à ce créateur
+	hadSubscription := err == nil
 
 	// Récupérer les infos du créateur
 	var creator user.User
@@ -51,31 +67,25 @@ func CreateSubscriptionSession(c *gin.Context) {
 	baseParams := &stripe.Params{}
 	baseParams.StripeAccount = &creator.StripeAccountID
 
-	// Création du produit
-	productParams := &stripe.ProductParams{
-		Params: *baseParams,
-		Name:   stripe.String(fmt.Sprintf("Abonnement OnlyFeed au créateur %s", creator.Username)),
+	// Tarif Stripe du palier ou de l'offre groupée demandés, ou tarif de base du créateur réutilisé d'un paiement à l'autre
+	tierID := c.Query("tier_id")
+	bundleID := c.Query("bundle_id")
+	if tierID != "" && bundleID != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Une offre groupée ne peut pas être combinée à un palier"})
+		return
 	}
-	createdProduct, err := product.New(productParams)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création produit Stripe"})
+	stripePriceID, ok := subscriptionPriceID(c, creator, tierID, bundleID)
+	if !ok {
 		return
 	}
 
-	// Création du prix
-	priceParams := &stripe.PriceParams{
-		Params:     *baseParams,
-		Product:    stripe.String(createdProduct.ID),
-		Currency:   stripe.String("eur"),
-		UnitAmount: stripe.Int64(int64(creator.SubscriptionPrice * 100)),
-		Recurring: &stripe.PriceRecurringParams{
-			Interval: stripe.String("month"),
-		},
-	}
-	createdPrice, err := price.New(priceParams)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création tarif Stripe"})
-		return
+	// Code promo vérifié ici plutôt que saisi sur la page Stripe, pour appliquer nos propres règles
+	var promo *billing.PromoCode
+	if code := c.Query("promo_code"); code != "" {
+		promo, ok = validatePromoCode(c, creator.ID, code)
+		if !ok {
+			return
+		}
 	}
 
 	// Création de la session d’abonnement
@@ -86,20 +96,33 @@ func CreateSubscriptionSession(c *gin.Context) {
 		CancelURL:  stripe.String(fmt.Sprintf("%s/%s?subscribe=error", domain, creator.Username)),
 		LineItems: []*stripe.CheckoutSessionLineItemParams{
 			{
-				Price:    stripe.String(createdPrice.ID),
+				Price:    stripe.String(stripePriceID),
 				Quantity: stripe.Int64(1),
 			},
 		},
 		CustomerEmail: stripe.String(userEmail),
 		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
-			ApplicationFeePercent: stripe.Float64(20.0),
+			ApplicationFeePercent: stripe.Float64(platformFeePercent),
 		},
 		Metadata: map[string]string{
 			"creator_id":    creator.ID,
 			"subscriber_id": userID,
+			"tier_id":       tierID,
+			"bundle_id":     bundleID,
 		},
 	}
 
+	if promo != nil {
+		sessionParams.Discounts = []*stripe.CheckoutSessionDiscountParams{
+			{Coupon: stripe.String(promo.StripeCouponID)},
+		}
+		sessionParams.Metadata["promo_code_id"] = promo.ID
+	}
+	if creator.TrialDays > 0 && !hadSubscription {
+		sessionParams.SubscriptionData.TrialPeriodDays = stripe.Int64(int64(creator.TrialDays))
+		sessionParams.Metadata["trial"] = "true"
+	}
+
 	createdSession, err := session.New(sessionParams)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création session Stripe"})
@@ -108,3 +131,91 @@ func CreateSubscriptionSession(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"url": createdSession.URL})
 }
+
+// subscriptionPriceID retourne le tarif Stripe d'un palier ou d'une offre groupée du créateur,
+// ou son tarif de base si tierID et bundleID sont vides, et répond à sa place en cas d'erreur
+func subscriptionPriceID(c *gin.Context, creator user.User, tierID, bundleID string) (string, bool) {
+	userID := c.GetString("user_id")
+
+	if bundleID != "" {
+		bundle, err := billing.FindActiveBundle(creator.ID, bundleID)
+		if err != nil {
+			if errors.Is(err, billing.ErrBundleNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Offre groupée introuvable"})
+				return "", false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération de l'offre groupée"})
+			logs.LogJSON("ERROR", "Error retrieving subscription bundle", map[string]interface{}{
+				"error":     err.Error(),
+				"route":     c.FullPath(),
+				"userID":    userID,
+				"creatorID": creator.ID,
+				"bundleID":  bundleID,
+			})
+			return "", false
+		}
+		return bundle.StripePriceID, true
+	}
+
+	if tierID != "" {
+		tier, err := billing.FindActiveTier(creator.ID, tierID)
+		if err != nil {
+			if errors.Is(err, billing.ErrTierNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Palier introuvable"})
+				return "", false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du palier"})
+			logs.LogJSON("ERROR", "Error retrieving subscription tier", map[string]interface{}{
+				"error":     err.Error(),
+				"route":     c.FullPath(),
+				"userID":    userID,
+				"creatorID": creator.ID,
+				"tierID":    tierID,
+			})
+			return "", false
+		}
+		return tier.StripePriceID, true
+	}
+
+	currentPrice, err := billing.CurrentPrice(creator.BillingInfo())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création tarif Stripe"})
+		logs.LogJSON("ERROR", "Error retrieving creator Stripe price", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     c.FullPath(),
+			"userID":    userID,
+			"creatorID": creator.ID,
+		})
+		return "", false
+	}
+	return currentPrice.StripePriceID, true
+}
+
+// validatePromoCode vérifie le code promo saisi par l'abonné, et répond à sa place s'il n'est pas utilisable
+func validatePromoCode(c *gin.Context, creatorID, code string) (*billing.PromoCode, bool) {
+	userID := c.GetString("user_id")
+
+	promo, err := billing.ValidatePromoCode(creatorID, code, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, billing.ErrPromoCodeInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Code promo invalide"})
+		case errors.Is(err, billing.ErrPromoCodeExpired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ce code promo a expiré"})
+		case errors.Is(err, billing.ErrPromoCodeExhausted):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ce code promo n'est plus disponible"})
+		case errors.Is(err, billing.ErrPromoCodeAlreadyUsed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vous avez déjà utilisé ce code promo"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification du code promo"})
+			logs.LogJSON("ERROR", "Error validating promo code", map[string]interface{}{
+				"error":     err.Error(),
+				"route":     c.FullPath(),
+				"userID":    userID,
+				"creatorID": creatorID,
+			})
+		}
+		return nil, false
+	}
+	return promo, true
+}
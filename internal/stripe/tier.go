@@ -0,0 +1,150 @@
+package stripe
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// ChangeTierInput est le corps de la requête de changement de palier. Un tier_id vide revient à l'abonnement de base.
+type ChangeTierInput struct {
+	TierID string `json:"tier_id"`
+}
+
+// ChangeSubscriptionTier PUT /api/stripe/subscription-tier/:creator_id
+// Fait passer un abonnement actif sur un autre palier du créateur. Une montée en gamme est facturée
+// immédiatement au prorata, une descente est créditée au prorata sur la prochaine facture.
+func ChangeSubscriptionTier(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	creatorID := c.Param("creator_id")
+
+	var input ChangeTierInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		return
+	}
+
+	var sub subscription.Subscription
+	if err := database.DB.
+		Where("subscriber_id = ? AND creator_id = ? AND status IN ?", userID, creatorID, subscription.AccessStatuses).
+		First(&sub).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Abonnement introuvable"})
+		return
+	}
+	// Un abonnement offert n'est pas facturé par Stripe : il n'a pas de palier à changer
+	if sub.Status == subscription.StatusGifted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Un abonnement offert ne peut pas changer de palier"})
+		return
+	}
+	if sub.StripeSubscriptionID == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ID d’abonnement Stripe manquant"})
+		return
+	}
+
+	currentTierID := ""
+	if sub.TierID != nil {
+		currentTierID = *sub.TierID
+	}
+	if currentTierID == input.TierID && sub.BundleID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vous êtes déjà abonné à ce palier"})
+		return
+	}
+
+	var creator user.User
+	if err := database.DB.First(&creator, "id = ?", creatorID).Error; err != nil || creator.StripeAccountID == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Impossible de récupérer le compte Stripe du créateur"})
+		return
+	}
+
+	// Palier visé : rang et prix, l'abonnement de base ayant le rang 0
+	var tierID *string
+	tierRank := 0
+	price := creator.SubscriptionPrice
+	if input.TierID != "" {
+		tier, err := billing.FindActiveTier(creator.ID, input.TierID)
+		if err != nil {
+			if errors.Is(err, billing.ErrTierNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Palier introuvable"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération du palier"})
+			logs.LogJSON("ERROR", "Error retrieving subscription tier", map[string]interface{}{
+				"error":     err.Error(),
+				"route":     route,
+				"userID":    userID,
+				"creatorID": creatorID,
+				"tierID":    input.TierID,
+			})
+			return
+		}
+		tierID = &tier.ID
+		tierRank = tier.Rank
+		price = tier.Price
+	}
+
+	stripePriceID, ok := subscriptionPriceID(c, creator, input.TierID, "")
+	if !ok {
+		return
+	}
+
+	// Montée ou descente en gamme selon le prix mensuel, une offre groupée comptant pour son prix par mois
+	months := sub.IntervalMonths
+	if months < 1 {
+		months = 1
+	}
+	proration := billing.ProrationCreate
+	if billing.ToCents(price) > billing.ToCents(sub.Price/float64(months)) {
+		proration = billing.ProrationAlwaysInvoice
+	}
+	if err := billing.SwitchSubscriptionPrice(creator.StripeAccountID, sub.StripeSubscriptionID, stripePriceID, proration); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du changement de palier sur Stripe"})
+		logs.LogJSON("ERROR", "Error switching Stripe subscription tier", map[string]interface{}{
+			"error":          err.Error(),
+			"route":          route,
+			"userID":         userID,
+			"creatorID":      creatorID,
+			"subscriptionID": sub.StripeSubscriptionID,
+		})
+		return
+	}
+
+	if err := database.DB.Model(&subscription.Subscription{}).
+		Where("id = ?", sub.ID).
+		Updates(map[string]interface{}{
+			"tier_id":   tierID,
+			"tier_rank": tierRank,
+			"price":     price,
+			// Les paliers sont mensuels : une offre groupée en cours est remplacée
+			"bundle_id":       nil,
+			"interval_months": 1,
+		}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour de l'abonnement"})
+		logs.LogJSON("ERROR", "Error updating subscription tier", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     route,
+			"userID":    userID,
+			"creatorID": creatorID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tier_id":   tierID,
+		"tier_rank": tierRank,
+		"price":     price,
+	})
+	logs.LogJSON("INFO", "Subscription tier changed", map[string]interface{}{
+		"route":     route,
+		"userID":    userID,
+		"creatorID": creatorID,
+		"tierRank":  tierRank,
+	})
+}
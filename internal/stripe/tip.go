@@ -0,0 +1,284 @@
+package stripe
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/checkout/session"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/earnings"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/message"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/tip"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// checkoutKindTip identifie dans les metadata une session de pourboire
+const checkoutKindTip = "tip"
+
+// errTipTargetNotFound signale une cible de pourboire inexistante ou inaccessible
+var errTipTargetNotFound = errors.New("cible du pourboire introuvable")
+
+// CreateTip POST /api/tips
+// Crée un pourboire en attente et la session de paiement Stripe vers le compte du créateur
+func CreateTip(c *gin.Context) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	domain := os.Getenv("DOMAIN_URL")
+	route := c.FullPath()
+
+	userID := c.GetString("user_id")
+	userEmail := c.GetString("user_email")
+
+	var input tip.CreateTipInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		logs.LogJSON("WARN", "Invalid tip input", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+	if !tip.IsValidTarget(input.TargetType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cible du pourboire invalide"})
+		return
+	}
+	if input.Amount < tip.MinAmount || input.Amount > tip.MaxAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le pourboire doit être compris entre %.2f€ et %.2f€", tip.MinAmount, tip.MaxAmount)})
+		return
+	}
+	if utf8.RuneCountInString(input.Message) > tip.MaxMessageLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Le message ne doit pas dépasser %d caractères", tip.MaxMessageLength)})
+		return
+	}
+
+	creatorID, err := tipRecipient(userID, input.TargetType, input.TargetID)
+	if err != nil {
+		if errors.Is(err, errTipTargetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cible du pourboire introuvable"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur de base de données"})
+		logs.LogJSON("ERROR", "Error resolving tip target", map[string]interface{}{
+			"error":    err.Error(),
+			"route":    route,
+			"userID":   userID,
+			"targetID": input.TargetID,
+		})
+		return
+	}
+	if creatorID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Impossible de s'envoyer un pourboire"})
+		return
+	}
+
+	var creator user.User
+	if err := database.DB.First(&creator, "id = ? AND is_creator = true", creatorID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Créateur introuvable"})
+		return
+	}
+	if creator.StripeAccountID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le créateur n'a pas de compte Stripe"})
+		return
+	}
+
+	newTip := tip.Tip{
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		TipperID:   userID,
+		CreatorID:  creator.ID,
+		TargetType: input.TargetType,
+		TargetID:   input.TargetID,
+		Amount:     input.Amount,
+		Message:    input.Message,
+		Status:     tip.StatusPending,
+	}
+	if err := database.DB.Create(&newTip).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du pourboire"})
+		logs.LogJSON("ERROR", "Error creating tip", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	amount := billing.ToCents(input.Amount)
+	fee := int64(float64(amount) * platformFeePercent / 100)
+
+	sessionParams := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(fmt.Sprintf("%s/%s?tip=success&tip_id=%s", domain, creator.Username, newTip.ID)),
+		CancelURL:  stripe.String(fmt.Sprintf("%s/%s?tip=error&tip_id=%s", domain, creator.Username, newTip.ID)),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency:   stripe.String("eur"),
+					UnitAmount: stripe.Int64(amount),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(fmt.Sprintf("Pourboire pour %s", creator.Username)),
+					},
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		CustomerEmail: stripe.String(userEmail),
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			ApplicationFeeAmount: stripe.Int64(fee),
+		},
+		Metadata: map[string]string{
+			"kind":         checkoutKindTip,
+			"tip_id":       newTip.ID,
+			"creator_id":   creator.ID,
+			"tipper_id":    userID,
+			"platform_fee": strconv.FormatInt(fee, 10),
+		},
+	}
+	sessionParams.SetStripeAccount(creator.StripeAccountID)
+
+	createdSession, err := session.New(sessionParams)
+	if err != nil {
+		database.DB.Delete(&newTip)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création session Stripe"})
+		logs.LogJSON("ERROR", "Error creating Stripe tip session", map[string]interface{}{
+			"error":     err.Error(),
+			"route":     route,
+			"userID":    userID,
+			"creatorID": creator.ID,
+		})
+		return
+	}
+
+	database.DB.Model(&newTip).Update("stripe_session_id", createdSession.ID)
+
+	c.JSON(http.StatusOK, gin.H{"url": createdSession.URL, "tip_id": newTip.ID})
+}
+
+// tipRecipient retrouve le créateur destinataire d'un pourboire à partir de sa cible
+func tipRecipient(userID, targetType, targetID string) (string, error) {
+	switch targetType {
+	case tip.TargetPost:
+		var post struct{ UserID string }
+		if err := database.DB.Table("posts").Select("user_id").Where("id = ? AND status = ?", targetID, access.StatusPublished).Take(&post).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", errTipTargetNotFound
+			}
+			return "", err
+		}
+		return post.UserID, nil
+
+	case tip.TargetConversation:
+		// Seul un participant peut envoyer un pourboire dans la conversation
+		var conversation struct{ User1ID, User2ID string }
+		if err := database.DB.Table("conversations").
+			Select("user1_id, user2_id").
+			Where("id = ? AND (user1_id = ? OR user2_id = ?)", targetID, userID, userID).
+			Take(&conversation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", errTipTargetNotFound
+			}
+			return "", err
+		}
+		if conversation.User1ID == userID {
+			return conversation.User2ID, nil
+		}
+		return conversation.User1ID, nil
+	}
+
+	return targetID, nil
+}
+
+// handleTipCompleted confirme un pourboire payé via Stripe Checkout
+func handleTipCompleted(checkout stripe.CheckoutSession) error {
+	tipID := checkout.Metadata["tip_id"]
+	if tipID == "" {
+		logs.LogJSON("WARN", "Missing checkout session metadata", map[string]interface{}{
+			"sessionID": checkout.ID,
+		})
+		return nil
+	}
+
+	// Les moyens de paiement différés sont confirmés par checkout.session.async_payment_succeeded
+	if checkout.PaymentStatus != stripe.CheckoutSessionPaymentStatusPaid {
+		logs.LogJSON("INFO", "Tip payment pending", map[string]interface{}{
+			"sessionID": checkout.ID,
+			"tipID":     tipID,
+		})
+		return nil
+	}
+
+	var paidTip tip.Tip
+	if err := database.DB.First(&paidTip, "id = ?", tipID).Error; err != nil {
+		return fmt.Errorf("récupération du pourboire %s : %w", tipID, err)
+	}
+
+	fee, _ := strconv.ParseInt(checkout.Metadata["platform_fee"], 10, 64)
+	paymentID := checkout.ID
+	if checkout.PaymentIntent != nil && checkout.PaymentIntent.ID != "" {
+		paymentID = checkout.PaymentIntent.ID
+	}
+	now := time.Now()
+	if err := earnings.Record(earnings.Payment{
+		CreatedAt:      now,
+		StripeObjectID: paymentID,
+		Kind:           earnings.KindTip,
+		CreatorID:      paidTip.CreatorID,
+		PayerID:        paidTip.TipperID,
+		Amount:         checkout.AmountTotal,
+		PlatformFee:    fee,
+		Currency:       string(checkout.Currency),
+		PaidAt:         now,
+	}); err != nil {
+		return err
+	}
+
+	// Seule la première confirmation déclenche la notification et le message
+	result := database.DB.Model(&tip.Tip{}).
+		Where("id = ? AND status = ?", tipID, tip.StatusPending).
+		Updates(map[string]interface{}{
+			"status":     tip.StatusPaid,
+			"paid_at":    now,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("confirmation du pourboire %s : %w", tipID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	logs.LogJSON("INFO", "Tip paid", map[string]interface{}{
+		"userID":    paidTip.TipperID,
+		"creatorID": paidTip.CreatorID,
+		"tipID":     tipID,
+	})
+
+	if paidTip.TargetType == tip.TargetConversation {
+		if _, err := message.SendTipMessage(paidTip.TipperID, paidTip.CreatorID, paidTip.Message, paidTip.Amount); err != nil {
+			logs.LogJSON("ERROR", "Error posting tip message", map[string]interface{}{
+				"error": err.Error(),
+				"tipID": tipID,
+			})
+		}
+	}
+
+	notification.Emit(notification.Event{
+		RecipientID: paidTip.CreatorID,
+		ActorID:     paidTip.TipperID,
+		Type:        notification.TypeTip,
+		TargetID:    paidTip.TargetID,
+	})
+	return nil
+}
@@ -0,0 +1,216 @@
+package stripe
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/checkout/session"
+	"gorm.io/gorm/clause"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/earnings"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/unlock"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// checkoutKindPostUnlock identifie dans les metadata une session de déblocage de post
+const checkoutKindPostUnlock = "post_unlock"
+
+// CreateUnlockSession POST /api/stripe/create-unlock-session/:post_id
+// Crée une session de paiement Stripe pour débloquer un post payant à l'unité
+func CreateUnlockSession(c *gin.Context) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	domain := os.Getenv("DOMAIN_URL")
+	route := c.FullPath()
+
+	postID := c.Param("post_id")
+	userID := c.GetString("user_id")
+	userEmail := c.GetString("user_email")
+
+	var post struct {
+		ID          string
+		UserID      string
+		Title       string
+		Status      string
+		IsPaid      bool
+		UnlockPrice *float64
+		MinTierRank int
+	}
+	if err := database.DB.Table("posts").
+		Select("id, user_id, title, status, is_paid, unlock_price, min_tier_rank").
+		Where("id = ? AND status = ?", postID, access.StatusPublished).
+		Take(&post).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+		logs.LogJSON("WARN", "Post not found", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
+	}
+	if !post.IsPaid || post.UnlockPrice == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ce post ne peut pas être acheté à l'unité"})
+		logs.LogJSON("WARN", "Post cannot be unlocked", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
+	}
+
+	// Inutile de payer un post déjà accessible (auteur, abonné ou déjà acheté)
+	canView, err := access.CanViewPost(userID, access.Post{ID: post.ID, UserID: post.UserID, Status: post.Status, IsPaid: post.IsPaid, MinTierRank: post.MinTierRank})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification de l'accès"})
+		logs.LogJSON("ERROR", "Access verification error", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
+	}
+	if canView {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vous avez déjà accès à ce post"})
+		return
+	}
+
+	var creator user.User
+	if err := database.DB.First(&creator, "id = ?", post.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Créateur introuvable"})
+		return
+	}
+	if creator.StripeAccountID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le créateur n'a pas de compte Stripe"})
+		return
+	}
+
+	amount := billing.ToCents(*post.UnlockPrice)
+	fee := int64(float64(amount) * platformFeePercent / 100)
+
+	sessionParams := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(fmt.Sprintf("%s/%s?unlock=success&post_id=%s", domain, creator.Username, post.ID)),
+		CancelURL:  stripe.String(fmt.Sprintf("%s/%s?unlock=error&post_id=%s", domain, creator.Username, post.ID)),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency:   stripe.String("eur"),
+					UnitAmount: stripe.Int64(amount),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(fmt.Sprintf("Déblocage du post « %s » de %s", post.Title, creator.Username)),
+					},
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		CustomerEmail: stripe.String(userEmail),
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			ApplicationFeeAmount: stripe.Int64(fee),
+		},
+		Metadata: map[string]string{
+			"kind":         checkoutKindPostUnlock,
+			"post_id":      post.ID,
+			"creator_id":   creator.ID,
+			"buyer_id":     userID,
+			"platform_fee": strconv.FormatInt(fee, 10),
+		},
+	}
+	sessionParams.SetStripeAccount(creator.StripeAccountID)
+
+	createdSession, err := session.New(sessionParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur création session Stripe"})
+		logs.LogJSON("ERROR", "Error creating Stripe unlock session", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+			"postID": postID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": createdSession.URL})
+}
+
+// handlePostUnlockCompleted enregistre le déblocage d'un post payé via Stripe Checkout
+func handlePostUnlockCompleted(checkout stripe.CheckoutSession) error {
+	postID := checkout.Metadata["post_id"]
+	buyerID := checkout.Metadata["buyer_id"]
+	creatorID := checkout.Metadata["creator_id"]
+
+	if postID == "" || buyerID == "" || creatorID == "" {
+		logs.LogJSON("WARN", "Missing checkout session metadata", map[string]interface{}{
+			"sessionID": checkout.ID,
+		})
+		return nil
+	}
+
+	// Les moyens de paiement différés sont confirmés par checkout.session.async_payment_succeeded
+	if checkout.PaymentStatus != stripe.CheckoutSessionPaymentStatusPaid {
+		logs.LogJSON("INFO", "Post unlock payment pending", map[string]interface{}{
+			"sessionID": checkout.ID,
+			"userID":    buyerID,
+			"postID":    postID,
+		})
+		return nil
+	}
+
+	postUnlock := unlock.PostUnlock{
+		CreatedAt:       time.Now(),
+		PostID:          postID,
+		UserID:          buyerID,
+		StripeSessionID: checkout.ID,
+		Price:           float64(checkout.AmountTotal) / 100,
+	}
+	created := database.DB.
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "post_id"}, {Name: "user_id"}}, DoNothing: true}).
+		Create(&postUnlock)
+	if created.Error != nil {
+		return fmt.Errorf("enregistrement du déblocage : %w", created.Error)
+	}
+
+	fee, _ := strconv.ParseInt(checkout.Metadata["platform_fee"], 10, 64)
+	paymentID := checkout.ID
+	if checkout.PaymentIntent != nil && checkout.PaymentIntent.ID != "" {
+		paymentID = checkout.PaymentIntent.ID
+	}
+	if err := earnings.Record(earnings.Payment{
+		CreatedAt:      time.Now(),
+		StripeObjectID: paymentID,
+		Kind:           earnings.KindPostUnlock,
+		CreatorID:      creatorID,
+		PayerID:        buyerID,
+		Amount:         checkout.AmountTotal,
+		PlatformFee:    fee,
+		Currency:       string(checkout.Currency),
+		PaidAt:         time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	if created.RowsAffected == 0 {
+		return nil
+	}
+
+	logs.LogJSON("INFO", "Post unlocked", map[string]interface{}{
+		"userID": buyerID,
+		"postID": postID,
+	})
+	notification.Emit(notification.Event{
+		RecipientID: creatorID,
+		ActorID:     buyerID,
+		Type:        notification.TypeUnlock,
+		TargetID:    postID,
+	})
+	return nil
+}
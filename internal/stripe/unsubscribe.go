@@ -20,6 +20,7 @@ type Subscription struct {
 	Status               string
 	StripeSubscriptionID string
 	Price                float64
+	CancelledAt          *time.Time
 }
 
 func Unsubscribe(c *gin.Context) {
@@ -30,12 +31,18 @@ func Unsubscribe(c *gin.Context) {
 
 	var existing Subscription
 	if err := database.DB.
-		Where("subscriber_id = ? AND creator_id = ? AND status = ?", subscriberID, creatorID, "active").
+		Where("subscriber_id = ? AND creator_id = ? AND status <> ?", subscriberID, creatorID, "cancelled").
 		First(&existing).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Abonnement introuvable"})
 		return
 	}
 
+	// Un abonnement offert n'a pas d'abonnement Stripe : il prend fin seul à sa date d'expiration
+	if existing.Status == "gifted" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Un abonnement offert prend fin automatiquement à son échéance"})
+		return
+	}
+
 	if existing.StripeSubscriptionID == "" {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ID d’abonnement Stripe manquant"})
 		return
@@ -64,7 +71,9 @@ func Unsubscribe(c *gin.Context) {
 		return
 	}
 
+	now := time.Now()
 	existing.Status = "cancelled"
+	existing.CancelledAt = &now
 	if err := database.DB.Save(&existing).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour locale"})
 		return
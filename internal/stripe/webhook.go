@@ -11,13 +11,19 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v78"
 	"github.com/stripe/stripe-go/v78/webhook"
+	"gorm.io/gorm"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
 )
 
 func HandleStripeWebhook(c *gin.Context) {
+	route := c.FullPath()
+
 	const MaxBodyBytes = int64(65536)
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxBodyBytes)
 
@@ -35,83 +41,274 @@ func HandleStripeWebhook(c *gin.Context) {
 	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Signature Stripe invalide"})
+		logs.LogJSON("WARN", "Invalid Stripe signature", map[string]interface{}{
+			"route": route,
+		})
+		return
+	}
+
+	// Stripe peut livrer plusieurs fois le même événement : seul le premier est traité
+	claimed, err := claimEvent(event, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de l'événement"})
+		logs.LogJSON("ERROR", "Error recording Stripe event", map[string]interface{}{
+			"error":   err.Error(),
+			"route":   route,
+			"eventID": event.ID,
+			"type":    event.Type,
+		})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": true})
+		logs.LogJSON("INFO", "Duplicate Stripe event ignored", map[string]interface{}{
+			"route":   route,
+			"eventID": event.ID,
+			"type":    event.Type,
+		})
 		return
 	}
 
+	// En cas d'échec on répond en erreur pour que Stripe renvoie l'événement
+	if err := processEvent(event); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du traitement de l'événement"})
+		logs.LogJSON("ERROR", "Error handling Stripe event", map[string]interface{}{
+			"error":   err.Error(),
+			"route":   route,
+			"eventID": event.ID,
+			"type":    event.Type,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// handleEvent aiguille un événement Stripe vérifié vers son traitement
+func handleEvent(event stripe.Event) error {
 	switch event.Type {
 
-	case "checkout.session.completed":
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
 		var session stripe.CheckoutSession
-		if err := json.Unmarshal(event.Data.Raw, &session); err == nil {
-			handleCheckoutSessionCompleted(session)
+		if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
+			return fmt.Errorf("décodage de la session : %w", err)
 		}
+		return handleCheckoutSession(session)
+
+	case "customer.subscription.updated", "customer.subscription.deleted":
+		var sub stripe.Subscription
+		if err := json.Unmarshal(event.Data.Raw, &sub); err != nil {
+			return fmt.Errorf("décodage de l'abonnement : %w", err)
+		}
+		return handleSubscriptionChanged(sub)
+
+	case "invoice.paid":
+		var invoice stripe.Invoice
+		if err := json.Unmarshal(event.Data.Raw, &invoice); err != nil {
+			return fmt.Errorf("décodage de la facture : %w", err)
+		}
+		return handleInvoicePaid(invoice)
+
+	case "invoice.payment_failed":
+		var invoice stripe.Invoice
+		if err := json.Unmarshal(event.Data.Raw, &invoice); err != nil {
+			return fmt.Errorf("décodage de la facture : %w", err)
+		}
+		return handleInvoicePaymentFailed(invoice)
+
+	case "account.updated":
+		var acct stripe.Account
+		if err := json.Unmarshal(event.Data.Raw, &acct); err != nil {
+			return fmt.Errorf("décodage du compte : %w", err)
+		}
+		return handleAccountUpdated(acct)
 
 	default:
-		fmt.Printf("⚠️  Événement non géré : %s\n", event.Type)
+		logs.LogJSON("INFO", "Unhandled Stripe event", map[string]interface{}{
+			"eventID": event.ID,
+			"type":    event.Type,
+		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"received": true})
+	return nil
 }
 
-func handleCheckoutSessionCompleted(session stripe.CheckoutSession) {
-	fmt.Println("✅ Abonnement confirmé via Stripe !")
+// handleCheckoutSession aiguille une session Checkout payée selon le type d'achat indiqué dans ses metadata
+func handleCheckoutSession(session stripe.CheckoutSession) error {
+	switch session.Metadata["kind"] {
+	case checkoutKindPostUnlock:
+		return handlePostUnlockCompleted(session)
+	case checkoutKindTip:
+		return handleTipCompleted(session)
+	case checkoutKindMessageUnlock:
+		return handleMessageUnlockCompleted(session)
+	case checkoutKindGift:
+		return handleGiftCompleted(session)
+	}
+
+	// Les abonnements sont toujours réglés de façon synchrone
+	if session.Mode != stripe.CheckoutSessionModeSubscription {
+		return nil
+	}
+	return handleCheckoutSessionCompleted(session)
+}
 
+func handleCheckoutSessionCompleted(session stripe.CheckoutSession) error {
 	creatorID := session.Metadata["creator_id"]
 	subscriberID := session.Metadata["subscriber_id"]
 
 	if creatorID == "" || subscriberID == "" {
-		fmt.Println("❌ Metadata manquante")
-		return
+		logs.LogJSON("WARN", "Missing checkout session metadata", map[string]interface{}{
+			"sessionID": session.ID,
+		})
+		return nil
 	}
 
+	// Récupérer l'ID d'abonnement Stripe
+	if session.Subscription == nil || session.Subscription.ID == "" {
+		logs.LogJSON("WARN", "Missing Stripe subscription ID in checkout session", map[string]interface{}{
+			"sessionID": session.ID,
+			"userID":    subscriberID,
+			"creatorID": creatorID,
+		})
+		return nil
+	}
+	subscriptionID := session.Subscription.ID
+
 	var creator user.User
 	if err := database.DB.First(&creator, "id = ?", creatorID).Error; err != nil {
-		fmt.Println("❌ Erreur lors de la récupération des infos du créateur")
-		return
+		return fmt.Errorf("récupération du créateur %s : %w", creatorID, err)
 	}
 
-	// Récupérer l'ID d'abonnement Stripe
-	subscriptionID := session.Subscription.ID
-	if subscriptionID == "" {
-		fmt.Println("❌ ID d’abonnement Stripe manquant dans la session")
-		return
+	// Palier choisi au paiement, l'abonnement de base sinon
+	price := creator.SubscriptionPrice
+	var tierID *string
+	tierRank := 0
+	if id := session.Metadata["tier_id"]; id != "" {
+		tier, err := billing.FindTier(creatorID, id)
+		if err != nil {
+			return err
+		}
+		price = tier.Price
+		tierID = &tier.ID
+		tierRank = tier.Rank
+	}
+
+	// Offre groupée de plusieurs mois, renouvelée tous les intervalMonths mois
+	var bundleID *string
+	intervalMonths := 1
+	if id := session.Metadata["bundle_id"]; id != "" {
+		bundle, err := billing.FindBundle(creatorID, id)
+		if err != nil {
+			return err
+		}
+		price = bundle.Price
+		bundleID = &bundle.ID
+		intervalMonths = bundle.Months
+	}
+
+	// Statut initial : en essai gratuit si la session en comportait un. La date de renouvellement
+	// est estimée ici puis corrigée par les événements customer.subscription.updated.
+	now := time.Now()
+	status := subscription.StatusActive
+	renewsAt := now.AddDate(0, intervalMonths, 0)
+	billedFrom := now
+	if session.Metadata["trial"] == "true" {
+		status = subscription.StatusTrialing
+		renewsAt = now.AddDate(0, 0, creator.TrialDays)
+		billedFrom = renewsAt
+	}
+
+	// Utilisation du code promo, comptée une seule fois par session
+	if promoCodeID := session.Metadata["promo_code_id"]; promoCodeID != "" {
+		if err := billing.RecordRedemption(promoCodeID, creatorID, subscriberID, session.ID); err != nil {
+			return err
+		}
 	}
 
 	// Vérifie si déjà abonné
 	var existing subscription.Subscription
 	err := database.DB.Where("subscriber_id = ? AND creator_id = ?", subscriberID, creatorID).First(&existing).Error
 	if err == nil {
-		if existing.Status == "active" {
-			fmt.Println("ℹ️ Déjà abonné (actif) → aucune action")
-			return
+		// Un abonnement payant déjà actif n'est pas remplacé. Un abonnement offert, même en cours, l'est :
+		// l'abonnement Stripe qui vient d'être créé sera facturé et doit pouvoir être suivi et annulé.
+		if existing.Status != subscription.StatusGifted && subscription.GrantsAccess(existing.Status) {
+			logs.LogJSON("INFO", "Subscription already active", map[string]interface{}{
+				"userID":    subscriberID,
+				"creatorID": creatorID,
+			})
+			return nil
 		}
 
-		// Réactiver abonnement annulé
-		existing.Status = "active"
+		// Réactiver abonnement annulé. Un abonnement offert devient récurrent : sans effacer
+		// sa date de fin, l'abonnement Stripe serait ignoré par les contrôles d'accès puis expiré.
+		existing.Status = status
+		existing.GiftedByID = nil
+		existing.ExpiresAt = nil
 		existing.StripeSubscriptionID = subscriptionID
-		existing.Price = creator.SubscriptionPrice
-		if err := database.DB.Save(&existing).Error; err != nil {
-			fmt.Println("❌ Erreur lors de la réactivation :", err)
-			return
+		existing.Price = price
+		existing.TierID = tierID
+		existing.TierRank = tierRank
+		existing.BundleID = bundleID
+		existing.IntervalMonths = intervalMonths
+		existing.CurrentPeriodEnd = &renewsAt
+		existing.CancelledAt = nil
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&existing).Error; err != nil {
+				return fmt.Errorf("réactivation de l'abonnement : %w", err)
+			}
+			return subscription.OpenPeriod(tx, existing, now, billedFrom)
+		})
+		if err != nil {
+			return err
 		}
 
-		fmt.Println("✅ Abonnement réactivé")
-		return
+		logs.LogJSON("INFO", "Subscription reactivated", map[string]interface{}{
+			"userID":    subscriberID,
+			"creatorID": creatorID,
+		})
+		notification.Emit(notification.Event{
+			RecipientID: creatorID,
+			ActorID:     subscriberID,
+			Type:        notification.TypeSubscription,
+			TargetID:    creatorID,
+		})
+		return nil
 	}
 
 	// Crée l’abonnement
 	sub := subscription.Subscription{
-		CreatedAt:            time.Now(),
+		CreatedAt:            now,
 		SubscriberID:         subscriberID,
 		CreatorID:            creatorID,
-		Status:               "active",
+		Status:               status,
 		StripeSubscriptionID: subscriptionID,
-		Price:                creator.SubscriptionPrice,
+		Price:                price,
+		TierID:               tierID,
+		TierRank:             tierRank,
+		BundleID:             bundleID,
+		IntervalMonths:       intervalMonths,
+		CurrentPeriodEnd:     &renewsAt,
 	}
-	if err := database.DB.Create(&sub).Error; err != nil {
-		fmt.Println("❌ Erreur lors de la création de l'abonnement :", err)
-		return
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&sub).Error; err != nil {
+			return fmt.Errorf("création de l'abonnement : %w", err)
+		}
+		return subscription.OpenPeriod(tx, sub, now, billedFrom)
+	})
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("✅ Abonnement créé : %s → %s\n", subscriberID, creatorID)
+	logs.LogJSON("INFO", "Subscription created", map[string]interface{}{
+		"userID":    subscriberID,
+		"creatorID": creatorID,
+	})
+	notification.Emit(notification.Event{
+		RecipientID: creatorID,
+		ActorID:     subscriberID,
+		Type:        notification.TypeSubscription,
+		TargetID:    creatorID,
+	})
+	return nil
 }
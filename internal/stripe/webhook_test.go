@@ -0,0 +1,413 @@
+package stripe
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v78/webhook"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/notification"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// replayFixture envoie au webhook un événement de testdata signé avec le secret de test
+func replayFixture(t *testing.T, name string, secret string) *httptest.ResponseRecorder {
+	payload, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	assert.NoError(t, err)
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload: payload,
+		Secret:  secret,
+	})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/stripe/webhook", bytes.NewReader(payload))
+	c.Request.Header.Set("Stripe-Signature", signed.Header)
+
+	HandleStripeWebhook(c)
+	return w
+}
+
+// expectEventClaimed attend l'enregistrement d'un nouvel événement
+func expectEventClaimed(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "stripe_events".*ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectEventStatus attend l'enregistrement du résultat du traitement
+func expectEventStatus(mock sqlmock.Sqlmock, eventID, status string) {
+	// Colonnes mises à jour dans l'ordre alphabétique, puis l'ID de l'événement
+	args := []driver.Value{sqlmock.AnyArg(), status, sqlmock.AnyArg(), eventID}
+	if status == EventStatusProcessed {
+		args = []driver.Value{"", sqlmock.AnyArg(), status, sqlmock.AnyArg(), eventID}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "stripe_events" SET`).
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectPeriodOpened attend la fin de la période en cours puis le début d'une nouvelle période
+func expectPeriodOpened(mock sqlmock.Sqlmock, subscriberID string, monthlyPrice float64, replaced int64, continued bool) {
+	mock.ExpectExec(`UPDATE "subscription_periods" SET "ended_at"=\$1,"replaced"=\$2 WHERE subscriber_id = \$3 AND creator_id = \$4 AND ended_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), true, subscriberID, "creator1").
+		WillReturnResult(sqlmock.NewResult(0, replaced))
+	mock.ExpectQuery(`INSERT INTO "subscription_periods"`).
+		WithArgs(sqlmock.AnyArg(), subscriberID, "creator1", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, monthlyPrice, continued, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("period1"))
+}
+
+func TestHandleStripeWebhook(t *testing.T) {
+	t.Setenv("STRIPE_WEBHOOK_SECRET", testWebhookSecret)
+
+	tests := []struct {
+		name           string
+		fixture        string
+		secret         string
+		expectations   func(mock sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name:    "Abonnement passé en défaut de paiement",
+			fixture: "customer.subscription.updated",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET`).
+					WithArgs(sqlmock.AnyArg(), "past_due", "sub_1Test").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PsubUpdated", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Abonnement annulé depuis Stripe",
+			fixture: "customer.subscription.deleted",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cancelled", "sub_1Test").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscription_periods" SET "ended_at"=\$1 WHERE ended_at IS NULL AND \(subscriber_id, creator_id\) IN \(SELECT subscriber_id, creator_id FROM "subscriptions" WHERE stripe_subscription_id = \$2\)`).
+					WithArgs(sqlmock.AnyArg(), "sub_1Test").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PsubDeleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Facture payée",
+			fixture: "invoice.paid",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET "current_period_end"=\$1,"price"=\$2`).
+					WithArgs(sqlmock.AnyArg(), 5.0, "sub_1Test").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "subscriber_id", "creator_id", "stripe_subscription_id"}).
+						AddRow("s1", "fan1", "creator1", "sub_1Test"))
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "payments".*ON CONFLICT \("stripe_object_id"\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "in_1Test", "subscription", "creator1", "fan1", int64(500), int64(100), "eur", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("pay1"))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET "status"`).
+					WithArgs("active", "sub_1Test", "past_due", "unpaid", "incomplete").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PinvoicePaid", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Échec de paiement",
+			fixture: "invoice.payment_failed",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET "status"`).
+					WithArgs("past_due", "sub_1Test", "active", "trialing").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PinvoiceFailed", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Compte Connect activé",
+			fixture: "account.updated",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WithArgs("acct_1Creator", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "stripe_account_id", "is_creator"}).
+						AddRow("creator1", "acct_1Creator", false))
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "users" SET`).
+					WithArgs(true, 5.0, "creator1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PaccountUpdated", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Post débloqué à l'unité",
+			fixture: "checkout.session.completed.unlock",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "post_unlocks".*ON CONFLICT \("post_id","user_id"\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "post1", "fan1", "cs_1Unlock", 3.0).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("unlock1"))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "payments".*ON CONFLICT \("stripe_object_id"\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "pi_1Unlock", "post_unlock", "creator1", "fan1", int64(300), int64(60), "eur", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("pay1"))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PunlockCompleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Pourboire sur un post",
+			fixture: "checkout.session.completed.tip",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectQuery(`SELECT \* FROM "tips"`).
+					WithArgs("tip1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "tipper_id", "creator_id", "target_type", "target_id", "amount", "status"}).
+						AddRow("tip1", "fan1", "creator1", "post", "post1", 5.0, "pending"))
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "payments".*ON CONFLICT \("stripe_object_id"\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "pi_1Tip", "tip", "creator1", "fan1", int64(500), int64(100), "eur", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("pay1"))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "tips" SET "paid_at"=\$1,"status"=\$2,"updated_at"=\$3 WHERE id = \$4 AND status = \$5`).
+					WithArgs(sqlmock.AnyArg(), "paid", sqlmock.AnyArg(), "tip1", "pending").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PtipCompleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Abonnement offert",
+			fixture: "checkout.session.completed.gift",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectQuery(`SELECT \* FROM "subscription_gifts"`).
+					WithArgs("gift1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "buyer_id", "recipient_id", "creator_id", "months", "amount", "status"}).
+						AddRow("gift1", "fan1", "fan2", "creator1", 3, 15.0, "pending"))
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscription_gifts" SET "paid_at"=\$1,"status"=\$2,"updated_at"=\$3 WHERE id = \$4 AND status = \$5`).
+					WithArgs(sqlmock.AnyArg(), "paid", sqlmock.AnyArg(), "gift1", "pending").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// Aucun abonnement existant : un abonnement offert à durée fixe est créé
+				mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+					WithArgs("fan2", "creator1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectExec(`INSERT INTO "subscriptions"`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectPeriodOpened(mock, "fan2", 5.0, 0, false)
+				mock.ExpectQuery(`INSERT INTO "payments".*ON CONFLICT \("stripe_object_id"\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "pi_1Gift", "gift", "creator1", "fan1", int64(1500), int64(300), "eur", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("pay1"))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PgiftCompleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Abonnement offert à un abonné déjà facturé par Stripe",
+			fixture: "checkout.session.completed.gift",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectQuery(`SELECT \* FROM "subscription_gifts"`).
+					WithArgs("gift1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "buyer_id", "recipient_id", "creator_id", "months", "amount", "status"}).
+						AddRow("gift1", "fan1", "fan2", "creator1", 3, 15.0, "pending"))
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscription_gifts" SET "paid_at"=\$1,"status"=\$2,"updated_at"=\$3 WHERE id = \$4 AND status = \$5`).
+					WithArgs(sqlmock.AnyArg(), "paid", sqlmock.AnyArg(), "gift1", "pending").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+					WithArgs("fan2", "creator1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "subscriber_id", "creator_id", "status", "stripe_subscription_id"}).
+						AddRow("s1", "fan2", "creator1", "past_due", "sub_1Test"))
+				// Le cadeau est marqué à rembourser, sans revenu pour le créateur
+				mock.ExpectExec(`UPDATE "subscription_gifts" SET "status"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+					WithArgs("refund_pending", sqlmock.AnyArg(), "gift1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PgiftCompleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Abonnement payant après la fin d'un abonnement offert",
+			fixture: "checkout.session.completed.subscription",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WithArgs("creator1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_price"}).AddRow("creator1", 5.0))
+				mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+					WithArgs("fan2", "creator1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "subscriber_id", "creator_id", "status", "stripe_subscription_id", "gifted_by_id", "expires_at"}).
+						AddRow("sub1", "fan2", "creator1", "cancelled", "", "fan1", time.Now().Add(-24*time.Hour)))
+				// L'abonnement redevient récurrent : plus d'auteur du cadeau ni de date de fin
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET .*"gifted_by_id"=\$11,"expires_at"=\$12`).
+					WithArgs(sqlmock.AnyArg(), "fan2", "creator1", "active", "sub_1New", 5.0, nil, 0, nil, 1,
+						nil, nil, sqlmock.AnyArg(), nil, "sub1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectPeriodOpened(mock, "fan2", 5.0, 0, false)
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PsubscriptionCompleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Abonnement payant pendant un abonnement offert",
+			fixture: "checkout.session.completed.subscription",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WithArgs("creator1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_price"}).AddRow("creator1", 5.0))
+				mock.ExpectQuery(`SELECT \* FROM "subscriptions"`).
+					WithArgs("fan2", "creator1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "subscriber_id", "creator_id", "status", "stripe_subscription_id", "gifted_by_id", "expires_at"}).
+						AddRow("sub1", "fan2", "creator1", "gifted", "", "fan1", time.Now().Add(30*24*time.Hour)))
+				// L'abonnement Stripe facturé est enregistré à la place de l'abonnement offert
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET .*"gifted_by_id"=\$11,"expires_at"=\$12`).
+					WithArgs(sqlmock.AnyArg(), "fan2", "creator1", "active", "sub_1New", 5.0, nil, 0, nil, 1,
+						nil, nil, sqlmock.AnyArg(), nil, "sub1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// La période offerte se poursuit sans résiliation
+				expectPeriodOpened(mock, "fan2", 5.0, 1, true)
+				mock.ExpectCommit()
+				expectEventStatus(mock, "evt_1PsubscriptionCompleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Média de message débloqué",
+			fixture: "checkout.session.completed.message_unlock",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "payments".*ON CONFLICT \("stripe_object_id"\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "pi_1MessageUnlock", "message_unlock", "creator1", "fan1", int64(1000), int64(200), "eur", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("pay1"))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "messages" SET "unlocked_at"=\$1,"updated_at"=\$2 WHERE .*unlocked_at IS NULL`).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "msg1", "fan1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectQuery(`SELECT \* FROM "messages"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "conversation_id", "sender_id", "receiver_id", "media_url", "price"}).
+						AddRow("msg1", "conv1", "creator1", "fan1", "https://bucket/messages/msg1.jpg", 10.0))
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow("creator1", "creator"))
+				expectEventStatus(mock, "evt_1PmessageUnlockCompleted", EventStatusProcessed)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Événement déjà traité",
+			fixture: "customer.subscription.updated",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO "stripe_events".*ON CONFLICT DO NOTHING`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "stripe_events" SET`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Échec du traitement",
+			fixture: "customer.subscription.updated",
+			secret:  testWebhookSecret,
+			expectations: func(mock sqlmock.Sqlmock) {
+				expectEventClaimed(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "subscriptions" SET`).
+					WillReturnError(errors.New("connexion perdue"))
+				mock.ExpectRollback()
+				expectEventStatus(mock, "evt_1PsubUpdated", EventStatusFailed)
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Signature invalide",
+			fixture:        "customer.subscription.updated",
+			secret:         "whsec_other",
+			expectations:   func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := testutil.SetupMockDB(t)
+			previous := notification.SetEmitter(&notification.Recorder{})
+			t.Cleanup(func() { notification.SetEmitter(previous) })
+			tt.expectations(mock)
+
+			w := replayFixture(t, tt.fixture, tt.secret)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
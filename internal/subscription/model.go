@@ -1,6 +1,25 @@
 package subscription
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuts locaux d'un abonnement
+const (
+	StatusActive     = "active"
+	StatusTrialing   = "trialing"
+	StatusPastDue    = "past_due"
+	StatusUnpaid     = "unpaid"
+	StatusIncomplete = "incomplete"
+	StatusPaused     = "paused"
+	StatusCancelled  = "cancelled"
+	StatusGifted     = "gifted" // offert par un autre utilisateur, sans abonnement Stripe récurrent
+)
+
+// AccessStatuses liste les statuts qui donnent accès au contenu payant du créateur
+var AccessStatuses = []string{StatusActive, StatusTrialing, StatusGifted}
 
 type Subscription struct {
 	ID                   string `gorm:"primaryKey"`
@@ -10,4 +29,63 @@ type Subscription struct {
 	Status               string
 	StripeSubscriptionID string
 	Price                float64
+	TierID               *string    // palier souscrit, nil pour l'abonnement de base
+	TierRank             int        // rang du palier, 0 pour l'abonnement de base
+	BundleID             *string    // offre groupée souscrite, nil pour un abonnement mensuel
+	IntervalMonths       int        // nombre de mois entre deux renouvellements
+	GiftedByID           *string    // utilisateur ayant offert l'abonnement
+	ExpiresAt            *time.Time // fin d'un abonnement offert, nil pour un abonnement récurrent
+	CurrentPeriodEnd     *time.Time
+	CancelledAt          *time.Time
+}
+
+// GrantsAccess indique si un abonnement dans ce statut donne accès au contenu payant
+func GrantsAccess(status string) bool {
+	for _, s := range AccessStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Recurring indique si l'abonnement est encore lié à un abonnement Stripe non annulé, même impayé ou en pause :
+// Stripe continue alors de le facturer et il ne peut pas être remplacé par un abonnement offert
+func (s Subscription) Recurring() bool {
+	return s.StripeSubscriptionID != "" && s.Status != StatusCancelled
+}
+
+// Expired indique si un abonnement à durée fixe est arrivé à son terme
+func Expired(expiresAt *time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(time.Now())
+}
+
+// ActiveFor est un scope GORM qui restreint une requête sur "subscriptions" aux abonnements de l'utilisateur
+// qui donnent accès au contenu : statut d'accès, et date de fin non atteinte pour un abonnement offert
+func ActiveFor(subscriberID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("subscriber_id = ? AND status IN ? AND (expires_at IS NULL OR expires_at > NOW())", subscriberID, AccessStatuses)
+	}
+}
+
+// StatusFromStripe convertit un statut d'abonnement Stripe en statut local
+func StatusFromStripe(stripeStatus string) string {
+	switch stripeStatus {
+	case "active":
+		return StatusActive
+	case "trialing":
+		return StatusTrialing
+	case "past_due":
+		return StatusPastDue
+	case "unpaid":
+		return StatusUnpaid
+	case "incomplete":
+		return StatusIncomplete
+	case "paused":
+		return StatusPaused
+	case "canceled", "incomplete_expired":
+		return StatusCancelled
+	default:
+		return stripeStatus
+	}
 }
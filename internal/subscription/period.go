@@ -0,0 +1,83 @@
+package subscription
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Period est une période d'abonnement continue, de la souscription à la résiliation.
+// Contrairement à la ligne de l'abonnement, une période terminée n'est jamais modifiée :
+// une réactivation ou un nouveau cadeau ouvre une nouvelle période.
+type Period struct {
+	ID           string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt    time.Time
+	SubscriberID string
+	CreatorID    string
+	StartedAt    time.Time
+	BilledFrom   time.Time // début de la facturation, après l'essai gratuit
+	EndedAt      *time.Time
+	MonthlyPrice float64 // prix ramené à un mois pour les offres groupées
+	Continued    bool    // succède sans interruption à la période précédente
+	Replaced     bool    // terminée par la période suivante, sans résiliation
+}
+
+func (Period) TableName() string {
+	return "subscription_periods"
+}
+
+// OpenPeriod commence une période pour l'abonnement. Une période encore en cours, par exemple
+// un abonnement offert devenu payant, est terminée sans compter comme une résiliation.
+func OpenPeriod(tx *gorm.DB, sub Subscription, startedAt, billedFrom time.Time) error {
+	closed := tx.Model(&Period{}).
+		Where("subscriber_id = ? AND creator_id = ? AND ended_at IS NULL", sub.SubscriberID, sub.CreatorID).
+		Updates(map[string]interface{}{
+			"ended_at": startedAt,
+			"replaced": true,
+		})
+	if closed.Error != nil {
+		return fmt.Errorf("fin de la période d'abonnement en cours : %w", closed.Error)
+	}
+
+	months := sub.IntervalMonths
+	if months < 1 {
+		months = 1
+	}
+	period := Period{
+		CreatedAt:    time.Now(),
+		SubscriberID: sub.SubscriberID,
+		CreatorID:    sub.CreatorID,
+		StartedAt:    startedAt,
+		BilledFrom:   billedFrom,
+		MonthlyPrice: sub.Price / float64(months),
+		Continued:    closed.RowsAffected > 0,
+	}
+	if err := tx.Create(&period).Error; err != nil {
+		return fmt.Errorf("début de la période d'abonnement : %w", err)
+	}
+	return nil
+}
+
+// ClosePeriod termine la période en cours d'un abonné à un créateur
+func ClosePeriod(tx *gorm.DB, subscriberID, creatorID string, endedAt time.Time) error {
+	if err := tx.Model(&Period{}).
+		Where("subscriber_id = ? AND creator_id = ? AND ended_at IS NULL", subscriberID, creatorID).
+		Update("ended_at", endedAt).Error; err != nil {
+		return fmt.Errorf("fin de la période d'abonnement : %w", err)
+	}
+	return nil
+}
+
+// CloseStripePeriod termine la période en cours de l'abonnement local lié à un abonnement Stripe
+func CloseStripePeriod(tx *gorm.DB, stripeSubscriptionID string, endedAt time.Time) error {
+	if err := tx.Model(&Period{}).
+		Where("ended_at IS NULL AND (subscriber_id, creator_id) IN (?)",
+			tx.Session(&gorm.Session{NewDB: true}).Model(&Subscription{}).
+				Select("subscriber_id, creator_id").
+				Where("stripe_subscription_id = ?", stripeSubscriptionID)).
+		Update("ended_at", endedAt).Error; err != nil {
+		return fmt.Errorf("fin de la période de l'abonnement %s : %w", stripeSubscriptionID, err)
+	}
+	return nil
+}
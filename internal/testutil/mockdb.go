@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+)
+
+// SetupMockDB remplace database.DB par une base sqlmock le temps du test
+func SetupMockDB(tb testing.TB) sqlmock.Sqlmock {
+	tb.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(tb, err)
+
+	dialector := postgres.New(postgres.Config{
+		Conn:                 mockDB,
+		DriverName:           "postgres",
+		PreferSimpleProtocol: true,
+	})
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	assert.NoError(tb, err)
+
+	originalDB := database.DB
+	database.DB = db
+	tb.Cleanup(func() {
+		database.DB = originalDB
+		mockDB.Close()
+	})
+
+	return mock
+}
@@ -0,0 +1,58 @@
+package tip
+
+import "time"
+
+// Cibles possibles d'un pourboire
+const (
+	TargetPost         = "post"
+	TargetProfile      = "profile"
+	TargetConversation = "conversation"
+)
+
+// Statuts d'un pourboire : il n'est confirmé qu'à la réception du webhook Stripe
+const (
+	StatusPending = "pending"
+	StatusPaid    = "paid"
+)
+
+// Bornes du montant d'un pourboire, en euros
+const (
+	MinAmount = 1.0
+	MaxAmount = 500.0
+)
+
+// MaxMessageLength est la longueur maximale du mot accompagnant un pourboire
+const MaxMessageLength = 280
+
+// Tip est un pourboire d'un fan à un créateur, sur un post, son profil ou une conversation
+type Tip struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	TipperID        string     `json:"tipper_id" gorm:"index"`
+	CreatorID       string     `json:"creator_id" gorm:"index"`
+	TargetType      string     `json:"target_type"`
+	TargetID        string     `json:"target_id"`
+	Amount          float64    `json:"amount"`
+	Message         string     `json:"message"`
+	Status          string     `json:"status"`
+	StripeSessionID string     `json:"-"`
+	PaidAt          *time.Time `json:"paid_at"`
+}
+
+// CreateTipInput est le corps de la requête de création d'un pourboire
+type CreateTipInput struct {
+	TargetType string  `json:"target_type" binding:"required"`
+	TargetID   string  `json:"target_id" binding:"required"`
+	Amount     float64 `json:"amount" binding:"required"`
+	Message    string  `json:"message"`
+}
+
+// IsValidTarget indique si le type de cible est pris en charge
+func IsValidTarget(targetType string) bool {
+	switch targetType {
+	case TargetPost, TargetProfile, TargetConversation:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package unlock
+
+import (
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+)
+
+// PostUnlock donne à un utilisateur un accès permanent à un post payant acheté à l'unité
+type PostUnlock struct {
+	ID              string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt       time.Time `json:"created_at"`
+	PostID          string    `json:"post_id" gorm:"index"`
+	UserID          string    `json:"user_id" gorm:"index"`
+	StripeSessionID string    `json:"-"`
+	Price           float64   `json:"price"`
+}
+
+// HasUnlocked indique si l'utilisateur a acheté le post
+func HasUnlocked(userID, postID string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&PostUnlock{}).
+		Where("user_id = ? AND post_id = ?", userID, postID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UnlockedPostIDs retourne, parmi les posts donnés, ceux achetés par l'utilisateur
+func UnlockedPostIDs(userID string, postIDs []string) (map[string]bool, error) {
+	unlocked := make(map[string]bool)
+	if userID == "" || len(postIDs) == 0 {
+		return unlocked, nil
+	}
+
+	var ids []string
+	if err := database.DB.Model(&PostUnlock{}).
+		Where("user_id = ? AND post_id IN ?", userID, postIDs).
+		Pluck("post_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		unlocked[id] = true
+	}
+	return unlocked, nil
+}
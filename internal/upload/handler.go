@@ -0,0 +1,343 @@
+package upload
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/post"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/user"
+)
+
+// PartRequest est l'URL signée d'une partie d'un envoi en plusieurs parties
+type PartRequest struct {
+	PartNumber int32 `json:"part_number"`
+	storage.PresignedRequest
+}
+
+// FinalizeUploadInput liste les parties envoyées, requises pour un envoi en plusieurs parties
+type FinalizeUploadInput struct {
+	Parts []storage.CompletedPart `json:"parts"`
+}
+
+// CreateUpload POST /api/uploads
+// Délivre une URL signée pour envoyer un fichier directement sur S3, ou une URL par partie pour
+// les fichiers volumineux. Le fichier doit ensuite être confirmé par FinalizeUpload.
+func CreateUpload(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+
+	var input CreateUploadInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+		return
+	}
+	if message := validateInput(input); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		logs.LogJSON("WARN", "Invalid upload request", map[string]interface{}{
+			"route":  route,
+			"userID": userID,
+			"extra":  message,
+		})
+		return
+	}
+
+	// Un média de carrousel ne peut viser qu'un post de l'utilisateur qui a encore de la place
+	if input.Purpose == PurposePost {
+		var count int64
+		if err := database.DB.Model(&post.Post{}).Where("id = ? AND user_id = ?", input.TargetID, userID).Count(&count).Error; err != nil || count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+			return
+		}
+		if err := database.DB.Model(&post.Media{}).Where("post_id = ?", input.TargetID).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la vérification du post"})
+			logs.LogJSON("ERROR", "Error counting post media", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+				"postID": input.TargetID,
+			})
+			return
+		}
+		if count >= post.MaxMediaPerPost {
+			c.JSON(http.StatusBadRequest, gin.H{"error": post.ErrTooManyMedia.Error()})
+			return
+		}
+	} else {
+		input.TargetID = ""
+		input.IsPreview = false
+	}
+
+	rule := purposeRules[input.Purpose]
+	upload := Upload{
+		UserID:      userID,
+		Purpose:     input.Purpose,
+		TargetID:    input.TargetID,
+		Key:         storage.Key(rule.folder, "upload_"+uuid.New().String()+contentTypeExtensions[input.ContentType]),
+		ContentType: input.ContentType,
+		Size:        input.Size,
+		IsPreview:   input.IsPreview,
+		Status:      StatusPending,
+		ExpiresAt:   time.Now().Add(uploadTTL),
+	}
+
+	response := gin.H{}
+	if input.Size >= multipartThreshold {
+		uploadID, err := storage.CreateMultipartUpload(upload.Key, upload.ContentType)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Erreur lors de la préparation de l'envoi"})
+			logs.LogJSON("ERROR", "Error creating multipart upload", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+			})
+			return
+		}
+		upload.MultipartUploadID = uploadID
+
+		parts := make([]PartRequest, 0, partCount(input.Size))
+		for number := int32(1); number <= partCount(input.Size); number++ {
+			req, err := storage.PresignUploadPart(upload.Key, uploadID, number, presignTTL)
+			if err != nil {
+				_ = storage.AbortMultipartUpload(upload.Key, uploadID)
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Erreur lors de la préparation de l'envoi"})
+				logs.LogJSON("ERROR", "Error presigning upload part", map[string]interface{}{
+					"error":  err.Error(),
+					"route":  route,
+					"userID": userID,
+				})
+				return
+			}
+			parts = append(parts, PartRequest{PartNumber: number, PresignedRequest: req})
+		}
+		response["part_size"] = partSize
+		response["parts"] = parts
+	} else {
+		req, err := storage.PresignPut(upload.Key, upload.ContentType, upload.Size, presignTTL)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Erreur lors de la préparation de l'envoi"})
+			logs.LogJSON("ERROR", "Error presigning upload", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  route,
+				"userID": userID,
+			})
+			return
+		}
+		response["request"] = req
+	}
+
+	if err := database.DB.Create(&upload).Error; err != nil {
+		if upload.Multipart() {
+			_ = storage.AbortMultipartUpload(upload.Key, upload.MultipartUploadID)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la préparation de l'envoi"})
+		logs.LogJSON("ERROR", "Error creating upload", map[string]interface{}{
+			"error":  err.Error(),
+			"route":  route,
+			"userID": userID,
+		})
+		return
+	}
+
+	response["upload"] = upload
+	c.JSON(http.StatusCreated, response)
+}
+
+// FinalizeUpload POST /api/uploads/:id/finalize
+// Vérifie que le fichier est bien arrivé sur S3 avec le type et la taille annoncés, puis le rattache
+// au post ou à l'avatar. Un fichier de message reste prêt jusqu'à l'envoi du message.
+func FinalizeUpload(c *gin.Context) {
+	route := c.FullPath()
+	userID := c.GetString("user_id")
+	uploadID := c.Param("id")
+
+	var input FinalizeUploadInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides"})
+			return
+		}
+	}
+
+	var upload Upload
+	if err := database.DB.First(&upload, "id = ? AND user_id = ?", uploadID, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Envoi non trouvé"})
+		return
+	}
+	if upload.Status != StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cet envoi a déjà été finalisé"})
+		return
+	}
+	if !upload.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "Cet envoi a expiré"})
+		return
+	}
+
+	// Un envoi en plusieurs parties n'existe sur S3 qu'une fois assemblé. Si l'objet est déjà là,
+	// une finalisation précédente l'a assemblé avant d'échouer : l'assembler à nouveau serait refusé par S3.
+	info, err := storage.HeadObject(upload.Key)
+	if upload.Multipart() && errors.Is(err, storage.ErrObjectNotFound) {
+		if len(input.Parts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Les parties envoyées sont requises"})
+			return
+		}
+		if err := storage.CompleteMultipartUpload(upload.Key, upload.MultipartUploadID, input.Parts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Impossible d'assembler les parties envoyées"})
+			logs.LogJSON("WARN", "Error completing multipart upload", map[string]interface{}{
+				"error":    err.Error(),
+				"route":    route,
+				"userID":   userID,
+				"uploadID": upload.ID,
+			})
+			return
+		}
+		info, err = storage.HeadObject(upload.Key)
+	}
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le fichier n'a pas encore été envoyé"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Erreur lors de la vérification du fichier"})
+		logs.LogJSON("ERROR", "Error reading upload object", map[string]interface{}{
+			"error":    err.Error(),
+			"route":    route,
+			"userID":   userID,
+			"uploadID": upload.ID,
+		})
+		return
+	}
+
+	// Le fichier doit correspondre à ce qui a été déclaré et autorisé, sinon il est supprimé
+	if info.Size != upload.Size || info.ContentType != upload.ContentType {
+		_ = storage.DeleteFromS3(upload.Key)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le fichier envoyé ne correspond pas au type ou à la taille annoncés"})
+		logs.LogJSON("WARN", "Upload does not match declaration", map[string]interface{}{
+			"route":    route,
+			"userID":   userID,
+			"uploadID": upload.ID,
+			"extra":    info.ContentType,
+		})
+		return
+	}
+
+	if upload.Purpose == PurposeMessage {
+		if !finalizeStatus(c, upload, StatusReady) {
+			return
+		}
+		upload.Status = StatusReady
+		c.JSON(http.StatusOK, gin.H{"upload": upload})
+		return
+	}
+
+	// Le statut est réservé avant le rattachement pour qu'une seconde finalisation ou la purge n'y touche pas
+	if !finalizeStatus(c, upload, StatusAttached) {
+		return
+	}
+	upload.Status = StatusAttached
+
+	response := gin.H{"upload": upload}
+	switch upload.Purpose {
+	case PurposePost:
+		updated, err := post.AttachMedia(upload.TargetID, userID, upload.Key, upload.IsPreview)
+		if err != nil {
+			releaseUpload(upload)
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Post non trouvé"})
+			case errors.Is(err, post.ErrTooManyMedia):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'ajout du média au post"})
+				logs.LogJSON("ERROR", "Error attaching upload to post", map[string]interface{}{
+					"error":    err.Error(),
+					"route":    route,
+					"userID":   userID,
+					"uploadID": upload.ID,
+					"postID":   upload.TargetID,
+				})
+			}
+			return
+		}
+		response["post"] = post.SignPost(updated)
+
+	case PurposeAvatar:
+		avatarURL, err := replaceAvatar(userID, upload)
+		if err != nil {
+			releaseUpload(upload)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour de l'avatar"})
+			logs.LogJSON("ERROR", "Error attaching upload to avatar", map[string]interface{}{
+				"error":    err.Error(),
+				"route":    route,
+				"userID":   userID,
+				"uploadID": upload.ID,
+			})
+			return
+		}
+		response["avatar_url"] = avatarURL
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// finalizeStatus fait passer l'envoi du statut en attente au statut donné, et répond en cas d'échec
+func finalizeStatus(c *gin.Context, upload Upload, status string) bool {
+	err := transition(upload, StatusPending, status)
+	if errors.Is(err, ErrUploadNotReady) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cet envoi a déjà été finalisé ou a expiré"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la finalisation de l'envoi"})
+		logs.LogJSON("ERROR", "Error updating upload status", map[string]interface{}{
+			"error":    err.Error(),
+			"route":    c.FullPath(),
+			"userID":   c.GetString("user_id"),
+			"uploadID": upload.ID,
+		})
+		return false
+	}
+	return true
+}
+
+// releaseUpload remet en attente un envoi dont le rattachement a échoué : le client peut réessayer,
+// et à défaut la purge supprimera le fichier
+func releaseUpload(upload Upload) {
+	if err := transition(upload, StatusAttached, StatusPending); err != nil {
+		logs.LogJSON("WARN", "Error releasing upload", map[string]interface{}{
+			"error":    err.Error(),
+			"uploadID": upload.ID,
+		})
+	}
+}
+
+// replaceAvatar remplace l'avatar de l'utilisateur par le fichier envoyé et supprime l'ancien
+func replaceAvatar(userID string, upload Upload) (string, error) {
+	var u user.User
+	if err := database.DB.First(&u, "id = ?", userID).Error; err != nil {
+		return "", err
+	}
+
+	avatarURL := storage.PublicURL(upload.Key)
+	if err := database.DB.Model(&user.User{}).Where("id = ?", userID).Update("avatar_url", avatarURL).Error; err != nil {
+		return "", err
+	}
+
+	if u.AvatarURL != "" {
+		if err := storage.DeleteFromS3("avatars/" + filepath.Base(u.AvatarURL)); err != nil {
+			logs.LogJSON("WARN", "Error deleting previous avatar", map[string]interface{}{
+				"error":  err.Error(),
+				"userID": userID,
+			})
+		}
+	}
+	return avatarURL, nil
+}
@@ -0,0 +1,114 @@
+package upload
+
+import "time"
+
+// Usages d'un envoi direct : le fichier est rattaché à un post, à l'avatar ou à un futur message
+const (
+	PurposePost    = "post"
+	PurposeAvatar  = "avatar"
+	PurposeMessage = "message"
+)
+
+// Statuts d'un envoi direct
+const (
+	StatusPending  = "pending"  // URL signée délivrée, fichier pas encore confirmé
+	StatusReady    = "ready"    // fichier vérifié, en attente d'être joint à un message
+	StatusAttached = "attached" // fichier rattaché à sa cible
+	StatusExpired  = "expired"  // envoi abandonné, fichier supprimé
+)
+
+// Upload est un fichier envoyé directement sur S3 par le client, hors du serveur
+type Upload struct {
+	ID                string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UserID            string     `json:"-"`
+	Purpose           string     `json:"purpose"`
+	TargetID          string     `json:"target_id,omitempty"` // post visé pour un média de carrousel
+	Key               string     `json:"key"`
+	ContentType       string     `json:"content_type"`
+	Size              int64      `json:"size"`
+	MultipartUploadID string     `json:"-"` // identifiant S3 d'un envoi en plusieurs parties
+	IsPreview         bool       `json:"is_preview"`
+	Status            string     `json:"status"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+}
+
+// Multipart indique si le fichier est envoyé en plusieurs parties
+func (u Upload) Multipart() bool {
+	return u.MultipartUploadID != ""
+}
+
+// CreateUploadInput décrit le fichier que le client s'apprête à envoyer
+type CreateUploadInput struct {
+	Purpose     string `json:"purpose" binding:"required"`
+	TargetID    string `json:"target_id"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+	IsPreview   bool   `json:"is_preview"`
+}
+
+// contentTypeExtensions associe les types de fichier acceptés à l'extension de leur clé S3
+var contentTypeExtensions = map[string]string{
+	"image/jpeg":         ".jpg",
+	"image/png":          ".png",
+	"image/gif":          ".gif",
+	"image/webp":         ".webp",
+	"image/heic":         ".heic",
+	"video/mp4":          ".mp4",
+	"video/quicktime":    ".mov",
+	"video/x-msvideo":    ".avi",
+	"video/x-matroska":   ".mkv",
+	"audio/mpeg":         ".mp3",
+	"audio/wav":          ".wav",
+	"audio/aac":          ".aac",
+	"audio/mp4":          ".m4a",
+	"application/pdf":    ".pdf",
+	"application/msword": ".doc",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	"text/plain":      ".txt",
+	"application/zip": ".zip",
+}
+
+// purposeRule fixe les types acceptés, la taille maximale et le dossier S3 d'un usage
+type purposeRule struct {
+	folder       string
+	maxSize      int64
+	contentTypes map[string]bool
+}
+
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/heic"}
+
+var purposeRules = map[string]purposeRule{
+	PurposePost: {
+		folder:       "posts",
+		maxSize:      2 << 30,
+		contentTypes: set(append(imageTypes, "video/mp4", "video/quicktime", "video/x-msvideo")...),
+	},
+	PurposeAvatar: {
+		folder:       "avatars",
+		maxSize:      10 << 20,
+		contentTypes: set(imageTypes...),
+	},
+	PurposeMessage: {
+		folder:       "messages",
+		maxSize:      500 << 20,
+		contentTypes: set(keys(contentTypeExtensions)...),
+	},
+}
+
+func set(values ...string) map[string]bool {
+	result := make(map[string]bool, len(values))
+	for _, value := range values {
+		result[value] = true
+	}
+	return result
+}
+
+func keys(m map[string]string) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
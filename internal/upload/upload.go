@@ -0,0 +1,142 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
+)
+
+const (
+	// uploadTTL est le délai laissé au client pour envoyer et finaliser un fichier avant sa suppression
+	uploadTTL = 24 * time.Hour
+	// presignTTL est la durée de validité des URL signées
+	presignTTL = 6 * time.Hour
+	// multipartThreshold est la taille à partir de laquelle le fichier est envoyé en plusieurs parties
+	multipartThreshold = 100 << 20
+	// partSize est la taille de chaque partie, sauf la dernière
+	partSize = 64 << 20
+	// purgeBatchSize limite le nombre d'envois expirés traités par exécution
+	purgeBatchSize = 500
+)
+
+// ErrUploadNotReady est retournée quand l'envoi n'existe pas, n'est pas finalisé ou a déjà été utilisé
+var ErrUploadNotReady = errors.New("envoi introuvable ou non finalisé")
+
+// validateInput vérifie l'usage, le type et la taille déclarés, et retourne un message d'erreur si invalides
+func validateInput(input CreateUploadInput) string {
+	rule, ok := purposeRules[input.Purpose]
+	if !ok {
+		return "Usage d'envoi invalide"
+	}
+	if input.Purpose == PurposePost && input.TargetID == "" {
+		return "Le post visé est requis"
+	}
+	if !rule.contentTypes[input.ContentType] {
+		return "Type de fichier non autorisé"
+	}
+	if input.Size <= 0 || input.Size > rule.maxSize {
+		return fmt.Sprintf("La taille du fichier doit être comprise entre 1 et %d Mo", rule.maxSize>>20)
+	}
+	return ""
+}
+
+// partCount retourne le nombre de parties nécessaires pour envoyer un fichier de cette taille
+func partCount(size int64) int32 {
+	return int32((size + partSize - 1) / partSize)
+}
+
+// FindReady retourne un fichier finalisé de l'utilisateur qui n'a pas encore été joint à sa cible
+func FindReady(id, userID, purpose string) (Upload, error) {
+	var upload Upload
+	err := database.DB.
+		Where("id = ? AND user_id = ? AND purpose = ? AND status = ? AND expires_at > ?",
+			id, userID, purpose, StatusReady, time.Now()).
+		First(&upload).Error
+	if err != nil {
+		return upload, ErrUploadNotReady
+	}
+	return upload, nil
+}
+
+// Attach marque un fichier finalisé comme rattaché à sa cible, il n'est alors plus supprimé à expiration
+func Attach(upload Upload) error {
+	return transition(upload, StatusReady, StatusAttached)
+}
+
+// transition change le statut d'un envoi s'il est toujours dans le statut attendu et n'a pas expiré,
+// ce qui empêche deux finalisations concurrentes ou une finalisation pendant la purge
+func transition(upload Upload, from, to string) error {
+	updates := map[string]interface{}{"status": to}
+	if from == StatusPending {
+		updates["completed_at"] = time.Now()
+	}
+
+	result := database.DB.Model(&Upload{}).
+		Where("id = ? AND status = ? AND expires_at > ?", upload.ID, from, time.Now()).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadNotReady
+	}
+	return nil
+}
+
+// PurgeExpiredUploads supprime de S3 les fichiers envoyés mais jamais rattachés avant leur expiration
+func PurgeExpiredUploads() error {
+	var expired []Upload
+	if err := database.DB.
+		Where("status IN ? AND expires_at <= ?", []string{StatusPending, StatusReady}, time.Now()).
+		Order("expires_at").
+		Limit(purgeBatchSize).
+		Find(&expired).Error; err != nil {
+		return fmt.Errorf("récupération des envois expirés : %w", err)
+	}
+
+	purged := 0
+	for _, upload := range expired {
+		// Le statut est changé avant la suppression : une finalisation concurrente ne peut plus aboutir
+		result := database.DB.Model(&Upload{}).
+			Where("id = ? AND status = ?", upload.ID, upload.Status).
+			Update("status", StatusExpired)
+		if result.Error != nil {
+			return fmt.Errorf("expiration de l'envoi %s : %w", upload.ID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		deleteObject(upload)
+		purged++
+	}
+
+	if purged > 0 {
+		logs.LogJSON("INFO", "Expired uploads purged", map[string]interface{}{
+			"count": purged,
+		})
+	}
+	return nil
+}
+
+// deleteObject supprime le fichier d'un envoi et les parties déjà envoyées, sans bloquer en cas d'échec
+func deleteObject(upload Upload) {
+	if upload.Multipart() && upload.Status == StatusPending {
+		if err := storage.AbortMultipartUpload(upload.Key, upload.MultipartUploadID); err != nil {
+			logs.LogJSON("WARN", "Error aborting multipart upload", map[string]interface{}{
+				"error":    err.Error(),
+				"uploadID": upload.ID,
+			})
+		}
+	}
+	if err := storage.DeleteFromS3(upload.Key); err != nil {
+		logs.LogJSON("WARN", "Error deleting upload object", map[string]interface{}{
+			"error":    err.Error(),
+			"uploadID": upload.ID,
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package upload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/testutil"
+)
+
+func TestValidateInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     CreateUploadInput
+		wantError bool
+	}{
+		{name: "Post video", input: CreateUploadInput{Purpose: PurposePost, TargetID: "post1", ContentType: "video/mp4", Size: 1 << 30}},
+		{name: "Avatar image", input: CreateUploadInput{Purpose: PurposeAvatar, ContentType: "image/png", Size: 2 << 20}},
+		{name: "Message document", input: CreateUploadInput{Purpose: PurposeMessage, ContentType: "application/pdf", Size: 1 << 20}},
+		{name: "Unknown purpose", input: CreateUploadInput{Purpose: "banner", ContentType: "image/png", Size: 1}, wantError: true},
+		{name: "Post without target", input: CreateUploadInput{Purpose: PurposePost, ContentType: "image/png", Size: 1}, wantError: true},
+		{name: "Video avatar", input: CreateUploadInput{Purpose: PurposeAvatar, ContentType: "video/mp4", Size: 1 << 20}, wantError: true},
+		{name: "Document on a post", input: CreateUploadInput{Purpose: PurposePost, TargetID: "post1", ContentType: "application/pdf", Size: 1}, wantError: true},
+		{name: "Avatar too large", input: CreateUploadInput{Purpose: PurposeAvatar, ContentType: "image/jpeg", Size: 11 << 20}, wantError: true},
+		{name: "Empty file", input: CreateUploadInput{Purpose: PurposeMessage, ContentType: "text/plain"}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validateInput(tt.input)
+			if tt.wantError {
+				assert.NotEmpty(t, message)
+			} else {
+				assert.Empty(t, message)
+			}
+		})
+	}
+}
+
+func TestPartCount(t *testing.T) {
+	assert.Equal(t, int32(2), partCount(multipartThreshold))
+	assert.Equal(t, int32(16), partCount(1<<30))
+	assert.Equal(t, int32(17), partCount(1<<30+1))
+}
+
+func TestAttachRequiresReadyUpload(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "uploads" SET "status"=\$1 WHERE id = \$2 AND status = \$3 AND expires_at > \$4`).
+		WithArgs(StatusAttached, "upload1", StatusReady, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := Attach(Upload{ID: "upload1", Status: StatusReady})
+	assert.ErrorIs(t, err, ErrUploadNotReady)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeExpiredUploadsSkipsFinalizedUploads(t *testing.T) {
+	mock := testutil.SetupMockDB(t)
+
+	mock.ExpectQuery(`SELECT \* FROM "uploads" WHERE status IN \(\$1,\$2\) AND expires_at <= \$3 ORDER BY expires_at LIMIT \$4`).
+		WithArgs(StatusPending, StatusReady, sqlmock.AnyArg(), purgeBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "key", "expires_at"}).
+			AddRow("upload1", StatusPending, "posts/upload_1.mp4", time.Now().Add(-time.Hour)))
+
+	// Finalisé entre la lecture et l'expiration : le fichier est conservé
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "uploads" SET "status"=\$1 WHERE id = \$2 AND status = \$3`).
+		WithArgs(StatusExpired, "upload1", StatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, PurgeExpiredUploads())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
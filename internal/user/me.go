@@ -9,7 +9,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/storage"
 )
 
@@ -42,6 +44,7 @@ func GetMe(c *gin.Context) {
 	}
 	if user.IsCreator {
 		response["subscription_price"] = user.SubscriptionPrice
+		response["trial_days"] = user.TrialDays
 	}
 
 	c.JSON(http.StatusOK, gin.H{"user": response})
@@ -64,6 +67,13 @@ func UpdateMe(c *gin.Context) {
 	language := c.PostForm("language")
 	theme := c.PostForm("theme")
 	subscriptionPrice := c.PostForm("subscription_price")
+	trialDays := c.PostForm("trial_days")
+	priceChangeMode := c.DefaultPostForm("price_change_mode", billing.PriceChangeGrandfather)
+
+	if !billing.IsValidPriceChangeMode(priceChangeMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode de changement de prix invalide"})
+		return
+	}
 
 	if username != "" {
 		user.Username = username
@@ -83,12 +93,24 @@ func UpdateMe(c *gin.Context) {
 	if theme != "" && (theme == "light" || theme == "dark" || theme == "system") {
 		user.Theme = theme
 	}
+	previousPrice := user.SubscriptionPrice
+	priceChanged := false
 	if subscriptionPrice != "" && user.IsCreator == true {
 		if subPrice, err := strconv.ParseFloat(subscriptionPrice, 64); err == nil && subPrice > 0 {
+			priceChanged = billing.ToCents(subPrice) != billing.ToCents(user.SubscriptionPrice)
 			user.SubscriptionPrice = subPrice
 		}
 	}
 
+	if trialDays != "" && user.IsCreator {
+		days, err := strconv.Atoi(trialDays)
+		if err != nil || days < 0 || days > billing.MaxTrialDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("La durée d'essai doit être comprise entre 0 et %d jours", billing.MaxTrialDays)})
+			return
+		}
+		user.TrialDays = days
+	}
+
 	// Vérification et remplacement de la photo
 	file, header, err := c.Request.FormFile("profile_picture")
 	if err == nil {
@@ -114,20 +136,45 @@ func UpdateMe(c *gin.Context) {
 		}
 
 		// Uploader nouvelle image
-		url, err := storage.UploadToS3(file, filename, contentType, "avatars")
+		key, err := storage.UploadToS3(file, filename, contentType, "avatars")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur upload S3", "details": err.Error()})
 			return
 		}
-		user.AvatarURL = url
+		user.AvatarURL = storage.PublicURL(key)
 	}
 
-	// Sauvegarde finale
+	// Sauvegarde du profil avant tout effet de bord sur Stripe
 	if err := database.DB.Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur mise à jour utilisateur"})
 		return
 	}
 
+	// Nouveau tarif Stripe uniquement si le prix change réellement, l'ancien est archivé.
+	// Les abonnés actuels gardent leur prix ou sont prévenus du changement selon le mode choisi.
+	// En cas d'échec, l'ancien prix est rétabli pour rester cohérent avec le tarif Stripe actif.
+	var priceChange *billing.PriceChange
+	if priceChanged && user.StripeAccountID != "" {
+		priceChange, err = billing.UpdateCreatorPrice(user.BillingInfo(), previousPrice, priceChangeMode)
+		if err != nil {
+			if rollbackErr := database.DB.Model(&User{}).Where("id = ?", userID).
+				Update("subscription_price", previousPrice).Error; rollbackErr != nil {
+				logs.LogJSON("ERROR", "Error restoring previous subscription price", map[string]interface{}{
+					"error":  rollbackErr.Error(),
+					"route":  c.FullPath(),
+					"userID": userID,
+				})
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Erreur mise à jour du tarif Stripe", "details": err.Error()})
+			logs.LogJSON("ERROR", "Error updating creator Stripe price", map[string]interface{}{
+				"error":  err.Error(),
+				"route":  c.FullPath(),
+				"userID": userID,
+			})
+			return
+		}
+	}
+
 	// Construction de la réponse avec condition sur isAdmin
 	response := gin.H{
 		"id":         user.ID,
@@ -147,6 +194,10 @@ func UpdateMe(c *gin.Context) {
 	}
 	if user.IsCreator {
 		response["subscription_price"] = user.SubscriptionPrice
+		response["trial_days"] = user.TrialDays
+	}
+	if priceChange != nil {
+		response["price_change"] = priceChange
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Profil mis à jour", "user": response})
@@ -1,6 +1,10 @@
 package user
 
-import "time"
+import (
+	"time"
+
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/billing"
+)
 
 type User struct {
 	ID                string `gorm:"primaryKey"` // UUID venant de auth.users
@@ -17,5 +21,17 @@ type User struct {
 	StripeAccountID   string
 	IsCreator         bool
 	SubscriptionPrice float64
+	TrialDays         int // jours d'essai gratuit offerts aux nouveaux abonnés
 	_Deleted          bool
 }
+
+// BillingInfo retourne les informations utilisées pour la facturation Stripe du créateur
+func (u User) BillingInfo() billing.Creator {
+	return billing.Creator{
+		ID:                u.ID,
+		Username:          u.Username,
+		StripeAccountID:   u.StripeAccountID,
+		SubscriptionPrice: u.SubscriptionPrice,
+		TrialDays:         u.TrialDays,
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/access"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/logs"
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/utils"
@@ -97,14 +98,15 @@ func GetUserByUsername(c *gin.Context) {
 		} else {
 			dataUser["subscription_price"] = user.SubscriptionPrice
 		}
+		dataUser["trial_days"] = user.TrialDays
 	}
 
 	var followersCount, subscribersCount, totalPosts, paidPosts int64
 
 	database.DB.Model(&utils.Follow{}).Where("creator_id = ?", user.ID).Count(&followersCount)
 	database.DB.Model(&utils.Subscription{}).Where("creator_id = ?", user.ID).Count(&subscribersCount)
-	database.DB.Table("posts").Where("user_id = ?", user.ID).Count(&totalPosts)
-	database.DB.Table("posts").Where("user_id = ? AND is_paid = TRUE", user.ID).Count(&paidPosts)
+	database.DB.Table("posts").Where("user_id = ? AND status = ?", user.ID, access.StatusPublished).Count(&totalPosts)
+	database.DB.Table("posts").Where("user_id = ? AND is_paid = TRUE AND status = ?", user.ID, access.StatusPublished).Count(&paidPosts)
 
 	stats := dataUser["stats"].(gin.H)
 	stats["followers_count"] = followersCount
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/database"
+	"github.com/ArthurDelaporte/OnlyFeed-Back/internal/subscription"
 )
 
 type Subscription struct {
@@ -16,20 +17,39 @@ type Subscription struct {
 	Status               string
 	StripeSubscriptionID string
 	Price                float64
+	TierRank             int
 }
 
 func IsSubscriberAndPrice(subscriberID, creatorID string) (bool, *float64, error) {
-	var subscription Subscription
-	err := database.DB.
-		Where("subscriber_id = ? AND creator_id = ?", subscriberID, creatorID).
-		First(&subscription).Error
+	sub, err := activeSubscription(subscriberID, creatorID)
+	if err != nil || sub == nil {
+		return false, nil, err // Pas d'abonnement donnant accès, ou une erreur s'est produite
+	}
+	return true, &sub.Price, nil // L'utilisateur est abonné
+}
+
+// SubscriptionTierRank indique si l'utilisateur a un abonnement actif au créateur, et le rang de son palier
+func SubscriptionTierRank(subscriberID, creatorID string) (bool, int, error) {
+	sub, err := activeSubscription(subscriberID, creatorID)
+	if err != nil || sub == nil {
+		return false, 0, err
+	}
+	return true, sub.TierRank, nil
+}
 
+// activeSubscription retourne l'abonnement de l'utilisateur au créateur s'il donne accès au contenu, nil sinon.
+// Un abonnement offert échu ne donne plus accès, même avant le passage de la tâche d'expiration.
+func activeSubscription(subscriberID, creatorID string) (*Subscription, error) {
+	var sub Subscription
+	err := database.DB.
+		Scopes(subscription.ActiveFor(subscriberID)).
+		Where("creator_id = ?", creatorID).
+		First(&sub).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, nil, nil // L'utilisateur ne suit pas
+			return nil, nil
 		}
-		return false, nil, err // Une erreur s'est produite
+		return nil, err
 	}
-
-	return subscription.Status == "active", &subscription.Price, nil // L'utilisateur suit
+	return &sub, nil
 }
@@ -0,0 +1,31 @@
+-- Notifications (likes, commentaires, follows, abonnements, messages)
+-- Les notifications non lues de même destinataire, type et cible sont regroupées.
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    user_id     uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type        text NOT NULL,
+    target_id   uuid NOT NULL,
+    actor_id    uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_count integer NOT NULL DEFAULT 1,
+    is_read     boolean NOT NULL DEFAULT false,
+    read_at     timestamptz
+);
+
+-- Liste paginée par (updated_at, id)
+CREATE INDEX IF NOT EXISTS idx_notifications_user_updated
+    ON notifications (user_id, updated_at DESC, id DESC);
+
+-- Une seule notification non lue par regroupement
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_unread_group
+    ON notifications (user_id, type, target_id)
+    WHERE is_read = false;
+
+CREATE TABLE IF NOT EXISTS notification_actors (
+    notification_id uuid NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    actor_id        uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (notification_id, actor_id)
+);
@@ -0,0 +1,8 @@
+-- Cycle de vie des abonnements synchronisé par les webhooks Stripe
+-- Statuts : active, trialing, past_due, unpaid, incomplete, paused, cancelled
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS current_period_end timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_stripe_subscription_id
+    ON subscriptions (stripe_subscription_id);
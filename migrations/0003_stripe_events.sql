@@ -0,0 +1,17 @@
+-- Journal des événements Stripe reçus par le webhook (idempotence et reprise)
+
+CREATE TABLE IF NOT EXISTS stripe_events (
+    id           text PRIMARY KEY, -- ID de l'événement Stripe (evt_...)
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    updated_at   timestamptz NOT NULL DEFAULT now(),
+    type         text NOT NULL,
+    account      text NOT NULL DEFAULT '',
+    payload      jsonb NOT NULL,
+    status       text NOT NULL, -- processing, processed, failed
+    attempts     integer NOT NULL DEFAULT 0,
+    last_error   text NOT NULL DEFAULT '',
+    processed_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_stripe_events_status_created
+    ON stripe_events (status, created_at DESC, id DESC);
@@ -0,0 +1,26 @@
+-- Produit et tarifs Stripe des créateurs, réutilisés d'un paiement à l'autre
+
+CREATE TABLE IF NOT EXISTS creator_products (
+    creator_id        uuid PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    stripe_account_id text NOT NULL,
+    stripe_product_id text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS creator_prices (
+    id              uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    creator_id      uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stripe_price_id text NOT NULL,
+    unit_amount     bigint NOT NULL, -- en centimes
+    currency        text NOT NULL DEFAULT 'eur',
+    active          boolean NOT NULL DEFAULT true,
+    archived_at     timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_creator_prices_creator_id ON creator_prices (creator_id);
+
+-- Un seul tarif actif par créateur
+CREATE UNIQUE INDEX IF NOT EXISTS idx_creator_prices_active
+    ON creator_prices (creator_id)
+    WHERE active = true;
@@ -0,0 +1,20 @@
+-- Historique des changements de prix des créateurs
+-- mode : grandfather (les abonnés gardent leur prix) ou migrate (nouveau prix au renouvellement)
+
+CREATE TABLE IF NOT EXISTS price_changes (
+    id                  uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at          timestamptz NOT NULL DEFAULT now(),
+    creator_id          uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_amount          bigint NOT NULL, -- en centimes
+    new_amount          bigint NOT NULL, -- en centimes
+    old_stripe_price_id text NOT NULL DEFAULT '',
+    new_stripe_price_id text NOT NULL,
+    mode                text NOT NULL,
+    status              text NOT NULL, -- pending, applied, superseded
+    effective_at        timestamptz NOT NULL,
+    applied_at          timestamptz,
+    affected_count      integer NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_changes_creator_id ON price_changes (creator_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_price_changes_pending ON price_changes (effective_at) WHERE status = 'pending';
@@ -0,0 +1,20 @@
+-- Paiements encaissés par les créateurs (factures d'abonnement, etc.) pour le tableau de bord des revenus
+
+CREATE TABLE IF NOT EXISTS payments (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    stripe_object_id text NOT NULL UNIQUE, -- facture ou paiement Stripe
+    kind             text NOT NULL,
+    creator_id       uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payer_id         uuid REFERENCES users(id) ON DELETE SET NULL,
+    amount           bigint NOT NULL,       -- montant brut en centimes
+    platform_fee     bigint NOT NULL DEFAULT 0,
+    currency         text NOT NULL DEFAULT 'eur',
+    paid_at          timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_creator_paid_at ON payments (creator_id, paid_at);
+
+-- Date de fin des abonnements, pour le churn
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS cancelled_at timestamptz;
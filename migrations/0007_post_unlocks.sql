@@ -0,0 +1,16 @@
+-- Achat à l'unité des posts payants (pay-per-view)
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS unlock_price numeric(10, 2); -- NULL : post réservé aux abonnés
+
+CREATE TABLE IF NOT EXISTS post_unlocks (
+    id                uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    post_id           uuid NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id           uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stripe_session_id text NOT NULL,
+    price             numeric(10, 2) NOT NULL,
+    UNIQUE (post_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_unlocks_user ON post_unlocks (user_id);
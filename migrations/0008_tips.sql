@@ -0,0 +1,22 @@
+-- Pourboires des fans aux créateurs, confirmés par le webhook Stripe
+
+CREATE TABLE IF NOT EXISTS tips (
+    id                uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    updated_at        timestamptz NOT NULL DEFAULT now(),
+    tipper_id         uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    creator_id        uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type       text NOT NULL, -- post, profile ou conversation
+    target_id         uuid NOT NULL,
+    amount            numeric(10, 2) NOT NULL,
+    message           text NOT NULL DEFAULT '',
+    status            text NOT NULL DEFAULT 'pending',
+    stripe_session_id text,
+    paid_at           timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_tips_creator ON tips (creator_id, status);
+
+-- Montant affiché dans les messages de type tip
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS tip_amount numeric(10, 2);
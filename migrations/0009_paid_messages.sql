@@ -0,0 +1,5 @@
+-- Médias payants en message privé : le média reste masqué au destinataire jusqu'à l'achat
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS price       numeric(10, 2), -- NULL : média gratuit
+    ADD COLUMN IF NOT EXISTS unlocked_at timestamptz;
@@ -0,0 +1,25 @@
+-- Paliers d'abonnement par créateur (Basic, VIP, Ultimate...). L'abonnement de base au prix
+-- subscription_price du créateur a le rang 0, les paliers ont des rangs à partir de 1.
+
+CREATE TABLE IF NOT EXISTS creator_tiers (
+    id              uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    updated_at      timestamptz NOT NULL DEFAULT now(),
+    creator_id      uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name            text NOT NULL,
+    description     text NOT NULL DEFAULT '',
+    rank            integer NOT NULL,
+    price           numeric(10, 2) NOT NULL,
+    stripe_price_id text NOT NULL,
+    active          boolean NOT NULL DEFAULT true,
+    archived_at     timestamptz,
+    UNIQUE (creator_id, rank)
+);
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS tier_id   uuid REFERENCES creator_tiers(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS tier_rank integer NOT NULL DEFAULT 0;
+
+-- Rang du palier minimum pour voir un post payant, 0 pour tout abonné
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS min_tier_rank integer NOT NULL DEFAULT 0;
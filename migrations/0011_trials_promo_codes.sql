@@ -0,0 +1,31 @@
+-- Essai gratuit proposé par le créateur aux nouveaux abonnés, 0 pour aucun essai
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS trial_days integer NOT NULL DEFAULT 0;
+
+-- Codes promo des créateurs, appliqués au premier paiement via un coupon Stripe du compte connecté
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    creator_id       uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code             text NOT NULL,
+    discount_type    text NOT NULL,
+    percent_off      numeric(5, 2) NOT NULL DEFAULT 0,
+    amount_off       bigint NOT NULL DEFAULT 0,
+    expires_at       timestamptz,
+    max_redemptions  integer,
+    redemption_count integer NOT NULL DEFAULT 0,
+    active           boolean NOT NULL DEFAULT true,
+    stripe_coupon_id text NOT NULL,
+    UNIQUE (creator_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id                uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    promo_code_id     uuid NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    creator_id        uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subscriber_id     uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stripe_session_id text NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_promo_code_id ON promo_redemptions (promo_code_id);
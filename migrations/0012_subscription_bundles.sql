@@ -0,0 +1,21 @@
+-- Offres groupées de plusieurs mois de l'abonnement de base (3, 6 ou 12 mois), payées en une fois
+-- via un tarif Stripe récurrent avec interval_count.
+
+CREATE TABLE IF NOT EXISTS creator_bundles (
+    id              uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    creator_id      uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    months          integer NOT NULL CHECK (months IN (3, 6, 12)),
+    price           numeric(10, 2) NOT NULL,
+    stripe_price_id text NOT NULL,
+    active          boolean NOT NULL DEFAULT true,
+    archived_at     timestamptz
+);
+
+-- Une seule offre active par durée et par créateur
+CREATE UNIQUE INDEX IF NOT EXISTS idx_creator_bundles_active_months
+    ON creator_bundles (creator_id, months) WHERE active;
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS bundle_id       uuid REFERENCES creator_bundles(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS interval_months integer NOT NULL DEFAULT 1;
@@ -0,0 +1,27 @@
+-- Abonnements offerts : un utilisateur paie en une fois N mois d'abonnement à un créateur pour un autre.
+-- L'abonnement du bénéficiaire a le statut gifted, une date de fin fixe et pas d'abonnement Stripe récurrent.
+
+CREATE TABLE IF NOT EXISTS subscription_gifts (
+    id                uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    updated_at        timestamptz NOT NULL DEFAULT now(),
+    buyer_id          uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id      uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    creator_id        uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    months            integer NOT NULL CHECK (months BETWEEN 1 AND 12),
+    amount            numeric(10, 2) NOT NULL,
+    message           text NOT NULL DEFAULT '',
+    status            text NOT NULL DEFAULT 'pending',
+    stripe_session_id text NOT NULL DEFAULT '',
+    paid_at           timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_gifts_buyer_id ON subscription_gifts (buyer_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_gifts_recipient_id ON subscription_gifts (recipient_id);
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS gifted_by_id uuid REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS expires_at   timestamptz;
+
+-- Recherche des abonnements offerts arrivés à échéance par la tâche d'expiration
+CREATE INDEX IF NOT EXISTS idx_subscriptions_gift_expiry ON subscriptions (expires_at) WHERE status = 'gifted';
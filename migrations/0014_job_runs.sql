@@ -0,0 +1,20 @@
+-- Historique des exécutions des tâches planifiées. Une exécution planifiée est identifiée par sa tâche
+-- et son échéance : une instance qui obtient le verrou après une autre ne la rejoue pas.
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_name      text NOT NULL,
+    trigger       text NOT NULL,
+    scheduled_for timestamptz NOT NULL,
+    started_at    timestamptz NOT NULL DEFAULT now(),
+    finished_at   timestamptz,
+    status        text NOT NULL,
+    error         text NOT NULL DEFAULT '',
+    instance      text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_name_started_at ON job_runs (job_name, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_name_scheduled_for ON job_runs (job_name, scheduled_for);
+
+-- Purge des messages supprimés depuis plus de 30 jours
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at) WHERE is_deleted;
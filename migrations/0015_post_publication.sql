@@ -0,0 +1,11 @@
+-- Brouillons et publication programmée des posts. Les posts existants sont publiés,
+-- publish_at conserve la date de publication prévue ou effective.
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS status     text NOT NULL DEFAULT 'published',
+    ADD COLUMN IF NOT EXISTS publish_at timestamptz;
+
+UPDATE posts SET publish_at = created_at WHERE publish_at IS NULL AND status = 'published';
+
+-- Recherche des posts programmés arrivés à échéance par la tâche de publication
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled_publish_at ON posts (publish_at) WHERE status = 'scheduled';
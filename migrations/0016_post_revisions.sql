@@ -0,0 +1,17 @@
+-- Historique des modifications des posts : chaque révision conserve l'état du post avant
+-- la modification, la liste des champs modifiés et l'utilisateur qui l'a faite.
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    post_id          uuid NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    editor_id        uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title            text NOT NULL,
+    description      text NOT NULL DEFAULT '',
+    media_url        text NOT NULL DEFAULT '',
+    is_paid          boolean NOT NULL DEFAULT false,
+    diff             jsonb NOT NULL,
+    restored_from_id uuid REFERENCES post_revisions(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_revisions_post_id_created_at ON post_revisions (post_id, created_at DESC);
@@ -0,0 +1,38 @@
+-- Posts en carrousel : jusqu'à 20 médias par post, dans l'ordre d'affichage. posts.media_url
+-- reste celui du premier média. Un média payant n'est visible que des utilisateurs ayant accès
+-- au post, un aperçu est visible par tous.
+
+CREATE TABLE IF NOT EXISTS post_media (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    post_id    uuid NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    position   integer NOT NULL CHECK (position BETWEEN 0 AND 19),
+    type       text NOT NULL,
+    url        text NOT NULL,
+    width      integer,
+    height     integer,
+    is_paid    boolean NOT NULL DEFAULT false,
+    is_preview boolean NOT NULL DEFAULT false,
+    UNIQUE (post_id, position)
+);
+
+-- Les posts existants ont un seul média
+INSERT INTO post_media (post_id, position, type, url, is_paid)
+SELECT id, 0,
+       CASE WHEN media_url ~* '\.(mp4|mov|avi)$' THEN 'video' ELSE 'image' END,
+       media_url, is_paid
+FROM posts
+WHERE media_url <> ''
+ON CONFLICT (post_id, position) DO NOTHING;
+
+-- Les révisions conservent la liste des médias du post avant modification
+ALTER TABLE post_revisions
+    ADD COLUMN IF NOT EXISTS media jsonb NOT NULL DEFAULT '[]';
+
+UPDATE post_revisions
+SET media = jsonb_build_array(jsonb_build_object(
+        'position', 0,
+        'type', CASE WHEN media_url ~* '\.(mp4|mov|avi)$' THEN 'video' ELSE 'image' END,
+        'url', media_url,
+        'is_paid', is_paid,
+        'is_preview', false))
+WHERE media = '[]' AND media_url <> '';
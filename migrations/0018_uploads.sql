@@ -0,0 +1,21 @@
+-- Envois directs sur S3 : le client reçoit une URL signée, envoie le fichier puis le finalise.
+-- Les envois jamais finalisés ou jamais utilisés sont supprimés de S3 après expiration.
+
+CREATE TABLE IF NOT EXISTS uploads (
+    id                  uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at          timestamptz NOT NULL DEFAULT now(),
+    user_id             uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose             text NOT NULL,
+    target_id           text NOT NULL DEFAULT '',
+    key                 text NOT NULL UNIQUE,
+    content_type        text NOT NULL,
+    size                bigint NOT NULL,
+    multipart_upload_id text NOT NULL DEFAULT '',
+    is_preview          boolean NOT NULL DEFAULT false,
+    status              text NOT NULL DEFAULT 'pending',
+    expires_at          timestamptz NOT NULL,
+    completed_at        timestamptz
+);
+
+-- Purge des envois expirés
+CREATE INDEX IF NOT EXISTS idx_uploads_expires_at ON uploads (expires_at) WHERE status IN ('pending', 'ready');
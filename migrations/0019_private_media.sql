@@ -0,0 +1,29 @@
+-- Médias privés : le bucket n'est plus lisible publiquement, sauf le dossier avatars/.
+-- Les colonnes de médias des posts et des messages contiennent désormais la clé S3 de l'objet,
+-- l'API délivre des URL signées temporaires après vérification de l'accès.
+-- Les avatars restent des URL publiques.
+
+UPDATE posts
+SET media_url = regexp_replace(media_url, '^https://[^/]+\.amazonaws\.com/', '')
+WHERE media_url LIKE 'https://%';
+
+UPDATE post_media
+SET url = regexp_replace(url, '^https://[^/]+\.amazonaws\.com/', '')
+WHERE url LIKE 'https://%';
+
+UPDATE post_revisions
+SET media_url = regexp_replace(media_url, '^https://[^/]+\.amazonaws\.com/', '')
+WHERE media_url LIKE 'https://%';
+
+UPDATE post_revisions
+SET media = (
+    SELECT COALESCE(jsonb_agg(
+               jsonb_set(item, '{url}', to_jsonb(regexp_replace(item->>'url', '^https://[^/]+\.amazonaws\.com/', '')))
+               ORDER BY ord), '[]')
+    FROM jsonb_array_elements(media) WITH ORDINALITY AS t(item, ord)
+)
+WHERE media::text LIKE '%amazonaws.com/%';
+
+UPDATE messages
+SET media_url = regexp_replace(media_url, '^https://[^/]+\.amazonaws\.com/', '')
+WHERE media_url LIKE 'https://%';
@@ -0,0 +1,34 @@
+-- Historique des périodes d'abonnement, pour les abonnés, le MRR et le churn des créateurs.
+-- La ligne de subscriptions est réécrite à chaque réactivation ou nouveau cadeau : ses dates ne
+-- suffisent pas à retrouver les résiliations passées. Une période n'est jamais rouverte.
+
+CREATE TABLE IF NOT EXISTS subscription_periods (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    subscriber_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    creator_id    uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    started_at    timestamptz NOT NULL,
+    billed_from   timestamptz NOT NULL,          -- début de la facturation, après l'essai gratuit
+    ended_at      timestamptz,
+    monthly_price numeric(10, 2) NOT NULL,       -- prix ramené à un mois pour les offres groupées
+    continued     boolean NOT NULL DEFAULT false, -- succède sans interruption à la période précédente
+    replaced      boolean NOT NULL DEFAULT false  -- terminée par la période suivante, sans résiliation
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_periods_creator_started_at ON subscription_periods (creator_id, started_at);
+
+-- Une seule période en cours par abonné et créateur
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_periods_open
+    ON subscription_periods (subscriber_id, creator_id)
+    WHERE ended_at IS NULL;
+
+-- Reprise des abonnements existants : une période par abonnement, depuis sa date de création
+INSERT INTO subscription_periods (subscriber_id, creator_id, started_at, billed_from, ended_at, monthly_price)
+SELECT subscriber_id,
+       creator_id,
+       created_at,
+       CASE WHEN status = 'trialing' THEN COALESCE(current_period_end, created_at) ELSE created_at END,
+       CASE WHEN status = 'cancelled' THEN COALESCE(cancelled_at, created_at) END,
+       price / GREATEST(interval_months, 1)
+FROM subscriptions
+WHERE NOT EXISTS (SELECT 1 FROM subscription_periods);
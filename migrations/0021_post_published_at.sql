@@ -0,0 +1,13 @@
+-- Date de mise en ligne effective des posts. created_at reste la date de création du post,
+-- publish_at la date de publication prévue ; les fils sont triés par published_at.
+
+ALTER TABLE posts
+    ADD COLUMN IF NOT EXISTS published_at timestamptz;
+
+-- Les publications précédentes réécrivaient created_at avec la date de mise en ligne
+UPDATE posts
+SET published_at = COALESCE(publish_at, created_at)
+WHERE published_at IS NULL AND status IN ('published', 'archived');
+
+-- Pagination des fils par (published_at, id)
+CREATE INDEX IF NOT EXISTS idx_posts_published_at ON posts (published_at DESC, id DESC) WHERE status = 'published';